@@ -0,0 +1,7 @@
+package main
+
+import "iam/internal/authzserver"
+
+func main() {
+	authzserver.NewApp("iam-authz-server").Run()
+}
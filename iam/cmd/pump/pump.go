@@ -0,0 +1,7 @@
+package main
+
+import "iam/internal/pump"
+
+func main() {
+	pump.NewApp("iam-pump").Run()
+}
@@ -21,6 +21,45 @@ USE `iam`;
 /*!40101 SET @OLD_SQL_MODE=@@SQL_MODE, SQL_MODE='NO_AUTO_VALUE_ON_ZERO' */;
 /*!40111 SET @OLD_SQL_NOTES=@@SQL_NOTES, SQL_NOTES=0 */;
 
+--
+-- Table structure for table `oauth_client`
+--
+
+DROP TABLE IF EXISTS `oauth_client`;
+/*!40101 SET @saved_cs_client     = @@character_set_client */;
+/*!40101 SET character_set_client = utf8 */;
+CREATE TABLE `oauth_client` (
+  `id` bigint(20) unsigned NOT NULL AUTO_INCREMENT,
+  `instanceID` varchar(32) DEFAULT NULL,
+  `name` varchar(45) NOT NULL,
+  `username` varchar(255) NOT NULL,
+  `clientID` varchar(36) NOT NULL,
+  `clientSecret` varchar(255) NOT NULL DEFAULT '',
+  `public` tinyint(1) NOT NULL DEFAULT 0,
+  `redirectURIs` text NOT NULL,
+  `grantTypes` varchar(255) NOT NULL DEFAULT '',
+  `scopes` varchar(255) NOT NULL DEFAULT '',
+  `description` varchar(255) NOT NULL,
+  `extendShadow` longtext DEFAULT NULL,
+  `createdAt` timestamp NOT NULL DEFAULT current_timestamp(),
+  `updatedAt` timestamp NOT NULL DEFAULT current_timestamp() ON UPDATE current_timestamp(),
+  PRIMARY KEY (`id`),
+  UNIQUE KEY `instanceID_UNIQUE` (`instanceID`),
+  UNIQUE KEY `clientID_UNIQUE` (`clientID`),
+  UNIQUE KEY `username_name_UNIQUE` (`username`,`name`),
+  CONSTRAINT `fk_oauth_client_user` FOREIGN KEY (`username`) REFERENCES `user` (`name`) ON DELETE NO ACTION ON UPDATE NO ACTION
+) ENGINE=InnoDB DEFAULT CHARSET=utf8;
+/*!40101 SET character_set_client = @saved_cs_client */;
+
+--
+-- Dumping data for table `oauth_client`
+--
+
+LOCK TABLES `oauth_client` WRITE;
+/*!40000 ALTER TABLE `oauth_client` DISABLE KEYS */;
+/*!40000 ALTER TABLE `oauth_client` ENABLE KEYS */;
+UNLOCK TABLES;
+
 --
 -- Table structure for table `policy`
 --
@@ -186,6 +225,8 @@ DELIMITER ;;
 BEGIN
 	delete from secret where username = old.name;
     delete from policy where username = old.name;
+    delete from oauth_client where username = old.name;
+    delete from user_identity where username = old.name;
 END */;;
 DELIMITER ;
 /*!50003 SET sql_mode              = @saved_sql_mode */ ;
@@ -193,6 +234,35 @@ DELIMITER ;
 /*!50003 SET character_set_results = @saved_cs_results */ ;
 /*!50003 SET collation_connection  = @saved_col_connection */ ;
 
+--
+-- Table structure for table `user_identity`
+--
+
+DROP TABLE IF EXISTS `user_identity`;
+/*!40101 SET @saved_cs_client     = @@character_set_client */;
+/*!40101 SET character_set_client = utf8 */;
+CREATE TABLE `user_identity` (
+  `id` bigint(20) unsigned NOT NULL AUTO_INCREMENT,
+  `username` varchar(255) NOT NULL,
+  `issuer` varchar(255) NOT NULL,
+  `subject` varchar(255) NOT NULL,
+  `createdAt` timestamp NOT NULL DEFAULT current_timestamp(),
+  PRIMARY KEY (`id`),
+  UNIQUE KEY `issuer_subject_UNIQUE` (`issuer`,`subject`),
+  KEY `fk_user_identity_user_idx` (`username`),
+  CONSTRAINT `fk_user_identity_user` FOREIGN KEY (`username`) REFERENCES `user` (`name`) ON DELETE NO ACTION ON UPDATE NO ACTION
+) ENGINE=InnoDB DEFAULT CHARSET=utf8;
+/*!40101 SET character_set_client = @saved_cs_client */;
+
+--
+-- Dumping data for table `user_identity`
+--
+
+LOCK TABLES `user_identity` WRITE;
+/*!40000 ALTER TABLE `user_identity` DISABLE KEYS */;
+/*!40000 ALTER TABLE `user_identity` ENABLE KEYS */;
+UNLOCK TABLES;
+
 --
 -- Dumping events for database 'iam'
 --
@@ -6,10 +6,11 @@ import (
 	"iam/internal/apiserver/store"
 	"iam/internal/pkg/middleware"
 	"iam/internal/pkg/middleware/auth"
+	"iam/pkg/jwks"
 )
 
-func newAuto() middleware.AuthStrategy {
-	return auth.NewAutoStrategy(newBasic(), newJwt())
+func newAuto(keys *jwks.KeySet, revocation *auth.Revocation) middleware.AuthStrategy {
+	return auth.NewAutoStrategy(newBasic(), newJwt(keys, revocation))
 }
 
 func newBasic() middleware.AuthStrategy {
@@ -32,6 +33,6 @@ func newBasic() middleware.AuthStrategy {
 	})
 }
 
-func newJwt() middleware.AuthStrategy {
-	return auth.NewJWTStrategy(auth.NewGinGwt())
+func newJwt(keys *jwks.KeySet, revocation *auth.Revocation) middleware.AuthStrategy {
+	return auth.NewJWTStrategy(keys, revocation)
 }
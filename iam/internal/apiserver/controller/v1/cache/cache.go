@@ -0,0 +1,103 @@
+package cache
+
+import (
+	"context"
+	"fmt"
+	"github.com/sirupsen/logrus"
+	"iam/internal/apiserver/store"
+	pb "iam/internal/pkg/proto/apiserver/v1"
+	metav1 "iam/pkg/api/meta/v1"
+	"sync"
+)
+
+// Cache 实现 pb.CacheServer 接口, 提供给 authz 服务同步全部的密钥和策略
+type Cache struct {
+	store store.Factory
+}
+
+var (
+	cacheServer *Cache
+	once        sync.Once
+)
+
+// GetCacheInsOr 根据 store 初始化 cache grpc 服务, store 为空时返回已经初始化的实例
+func GetCacheInsOr(store store.Factory) (*Cache, error) {
+	if store != nil {
+		once.Do(func() {
+			cacheServer = &Cache{store}
+		})
+	}
+
+	if cacheServer == nil {
+		return nil, fmt.Errorf("got nil cache server")
+	}
+
+	return cacheServer, nil
+}
+
+// ListSecrets 分页获取全部用户的密钥, offset/limit 为空时获取全部
+func (c *Cache) ListSecrets(ctx context.Context, r *pb.ListSecretsRequest) (*pb.ListSecretsResponse, error) {
+	logrus.Debugf("list secrets function called, offset:%d limit:%d", r.GetOffset(), r.GetLimit())
+
+	opts := metav1.ListOptions{
+		Offset: int(r.GetOffset()),
+		Limit:  int(r.GetLimit()),
+	}
+
+	secrets, err := c.store.Secrets().List(ctx, "", opts)
+	if err != nil {
+		logrus.Errorf("list secrets err:%v", err)
+		return nil, err
+	}
+
+	items := make([]*pb.SecretInfo, 0, len(secrets.Items))
+	for _, secret := range secrets.Items {
+		items = append(items, &pb.SecretInfo{
+			Name:        secret.Name,
+			SecretId:    secret.SecretID,
+			Username:    secret.Username,
+			SecretKey:   secret.SecretKey,
+			Expires:     secret.Expires,
+			Description: secret.Description,
+			CreatedAt:   secret.CreatedAt.Format("2006-01-02 15:04:05"),
+			UpdatedAt:   secret.UpdatedAt.Format("2006-01-02 15:04:05"),
+		})
+	}
+
+	return &pb.ListSecretsResponse{
+		TotalCount: int64(secrets.Count),
+		Items:      items,
+	}, nil
+}
+
+// ListPolicies 分页获取全部用户的策略, offset/limit 为空时获取全部
+func (c *Cache) ListPolicies(ctx context.Context, r *pb.ListPoliciesRequest) (*pb.ListPoliciesResponse, error) {
+	logrus.Debugf("list policies function called, offset:%d limit:%d", r.GetOffset(), r.GetLimit())
+
+	opts := metav1.ListOptions{
+		Offset: int(r.GetOffset()),
+		Limit:  int(r.GetLimit()),
+	}
+
+	policies, err := c.store.Policies().List(ctx, "", opts)
+	if err != nil {
+		logrus.Errorf("list policies err:%v", err)
+		return nil, err
+	}
+
+	items := make([]*pb.PolicyInfo, 0, len(policies.Items))
+	for _, pol := range policies.Items {
+		items = append(items, &pb.PolicyInfo{
+			Name:         pol.Name,
+			Username:     pol.Username,
+			PolicyStr:    pol.Policy.String(),
+			PolicyShadow: pol.PolicyShadow,
+			CreatedAt:    pol.CreatedAt.Format("2006-01-02 15:04:05"),
+		})
+	}
+
+	return &pb.ListPoliciesResponse{
+		TotalCount: int64(policies.Count),
+		Items:      items,
+	}, nil
+}
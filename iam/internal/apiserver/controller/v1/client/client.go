@@ -0,0 +1,14 @@
+package client
+
+import (
+	svcv1 "iam/internal/apiserver/service/v1"
+	"iam/internal/apiserver/store"
+)
+
+type ClientController struct {
+	svc svcv1.Service
+}
+
+func NewClientCtl(factory store.Factory) *ClientController {
+	return &ClientController{svc: svcv1.NewSvc(factory)}
+}
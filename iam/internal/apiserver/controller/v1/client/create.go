@@ -0,0 +1,53 @@
+package client
+
+import (
+	"context"
+	"github.com/gin-gonic/gin"
+	"github.com/sirupsen/logrus"
+	"iam/internal/pkg/code"
+	"iam/internal/pkg/middleware"
+	"iam/pkg/api/oauth"
+	"iam/pkg/core"
+	"iam/pkg/errors"
+	"time"
+)
+
+// Create 为当前登录用户注册 OIDC 客户端 POST /v1/clients , 仅在此时返回明文的 clientSecret
+func (ctl *ClientController) Create(c *gin.Context) {
+
+	var (
+		err   error
+		cInfo = new(oauth.Client)
+	)
+
+	username := c.GetString(middleware.UsernameKey)
+	if username == "" {
+		core.WriteResponse(c, errors.WithCode(code.ErrTokenInvalid, "user is not logged in"), nil)
+		return
+	}
+
+	if err = c.ShouldBindJSON(cInfo); err != nil {
+		logrus.Errorf("should bind client err:%v", err)
+		core.WriteResponse(c, errors.WithCode(code.ErrBind, "%s", err.Error()), nil)
+		return
+	}
+
+	cInfo.Username = username
+	cInfo.Default()
+	if fields := cInfo.Validate(); len(fields) > 0 {
+		core.WriteResponse(c, errors.WithCode(code.ErrValidation, "%s", fields.ToAggregate().Error()), nil)
+		return
+	}
+
+	timeCtx, cFunc := context.WithTimeout(context.Background(), time.Second*5)
+	defer cFunc()
+
+	secret, err := ctl.svc.Clients().Create(timeCtx, cInfo)
+	if err != nil {
+		core.WriteResponse(c, err, nil)
+		return
+	}
+
+	cInfo.ClientSecret = secret
+	core.WriteResponse(c, nil, cInfo)
+}
@@ -0,0 +1,31 @@
+package client
+
+import (
+	"context"
+	"github.com/gin-gonic/gin"
+	"iam/internal/pkg/code"
+	"iam/internal/pkg/middleware"
+	"iam/pkg/core"
+	"iam/pkg/errors"
+	"time"
+)
+
+// Delete 删除当前登录用户的客户端 DELETE /v1/clients/:name
+func (ctl *ClientController) Delete(c *gin.Context) {
+
+	username := c.GetString(middleware.UsernameKey)
+	if username == "" {
+		core.WriteResponse(c, errors.WithCode(code.ErrTokenInvalid, "user is not logged in"), nil)
+		return
+	}
+
+	timeCtx, cFunc := context.WithTimeout(context.Background(), time.Second*5)
+	defer cFunc()
+
+	if err := ctl.svc.Clients().Delete(timeCtx, username, c.Param("name")); err != nil {
+		core.WriteResponse(c, err, nil)
+		return
+	}
+
+	core.WriteResponse(c, nil, "ok")
+}
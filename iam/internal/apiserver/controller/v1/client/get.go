@@ -0,0 +1,33 @@
+package client
+
+import (
+	"context"
+	"github.com/gin-gonic/gin"
+	"iam/internal/pkg/code"
+	"iam/internal/pkg/middleware"
+	"iam/pkg/core"
+	"iam/pkg/errors"
+	"time"
+)
+
+// Get 获取当前登录用户的某个客户端 GET /v1/clients/:name
+func (ctl *ClientController) Get(c *gin.Context) {
+
+	username := c.GetString(middleware.UsernameKey)
+	if username == "" {
+		core.WriteResponse(c, errors.WithCode(code.ErrTokenInvalid, "user is not logged in"), nil)
+		return
+	}
+
+	timeCtx, cFunc := context.WithTimeout(context.Background(), time.Second*5)
+	defer cFunc()
+
+	cInfo, err := ctl.svc.Clients().Get(timeCtx, username, c.Param("name"))
+	if err != nil {
+		core.WriteResponse(c, err, nil)
+		return
+	}
+
+	cInfo.ClientSecret = "" // 不返回 hash 后的密钥
+	core.WriteResponse(c, nil, cInfo)
+}
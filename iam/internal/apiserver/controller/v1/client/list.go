@@ -0,0 +1,45 @@
+package client
+
+import (
+	"context"
+	"github.com/gin-gonic/gin"
+	"github.com/sirupsen/logrus"
+	"iam/internal/pkg/code"
+	"iam/internal/pkg/middleware"
+	metav1 "iam/pkg/api/meta/v1"
+	"iam/pkg/core"
+	"iam/pkg/errors"
+	"time"
+)
+
+// List 分页获取当前登录用户的客户端 GET /v1/clients?offset=0&limit=10
+func (ctl *ClientController) List(c *gin.Context) {
+
+	username := c.GetString(middleware.UsernameKey)
+	if username == "" {
+		core.WriteResponse(c, errors.WithCode(code.ErrTokenInvalid, "user is not logged in"), nil)
+		return
+	}
+
+	var opts metav1.ListOptions
+	if err := c.ShouldBindQuery(&opts); err != nil {
+		logrus.Errorf("should bind list options err:%v", err)
+		core.WriteResponse(c, errors.WithCode(code.ErrBind, "%s", err.Error()), nil)
+		return
+	}
+
+	timeCtx, cFunc := context.WithTimeout(context.Background(), time.Second*5)
+	defer cFunc()
+
+	clients, err := ctl.svc.Clients().List(timeCtx, username, opts)
+	if err != nil {
+		core.WriteResponse(c, err, nil)
+		return
+	}
+
+	for _, item := range clients.Items {
+		item.ClientSecret = ""
+	}
+
+	core.WriteResponse(c, nil, clients)
+}
@@ -0,0 +1,65 @@
+package client
+
+import (
+	"context"
+	"github.com/gin-gonic/gin"
+	"github.com/sirupsen/logrus"
+	"iam/internal/pkg/code"
+	"iam/internal/pkg/middleware"
+	"iam/pkg/api/oauth"
+	"iam/pkg/core"
+	"iam/pkg/errors"
+	"time"
+)
+
+// Update 更新当前登录用户的客户端 PUT /v1/clients/:name , 仅允许修改 redirectURIs, grantTypes, scopes, description, extend
+func (ctl *ClientController) Update(c *gin.Context) {
+
+	var (
+		err   error
+		cInfo = new(oauth.Client)
+	)
+
+	username := c.GetString(middleware.UsernameKey)
+	if username == "" {
+		core.WriteResponse(c, errors.WithCode(code.ErrTokenInvalid, "user is not logged in"), nil)
+		return
+	}
+
+	if err = c.ShouldBindJSON(cInfo); err != nil {
+		logrus.Errorf("should bind client err:%v", err)
+		core.WriteResponse(c, errors.WithCode(code.ErrBind, "%s", err.Error()), nil)
+		return
+	}
+
+	timeCtx, cFunc := context.WithTimeout(context.Background(), time.Second*5)
+	defer cFunc()
+
+	oldInfo, err := ctl.svc.Clients().Get(timeCtx, username, c.Param("name"))
+	if err != nil {
+		core.WriteResponse(c, err, nil)
+		return
+	}
+
+	oldInfo.RedirectURIs = cInfo.RedirectURIs
+	oldInfo.GrantTypes = cInfo.GrantTypes
+	oldInfo.Scopes = cInfo.Scopes
+	oldInfo.Description = cInfo.Description
+	if cInfo.Extend != nil {
+		oldInfo.Extend = cInfo.Extend
+	}
+
+	oldInfo.Default()
+	if fields := oldInfo.Validate(); len(fields) > 0 {
+		core.WriteResponse(c, errors.WithCode(code.ErrValidation, "%s", fields.ToAggregate().Error()), nil)
+		return
+	}
+
+	if err = ctl.svc.Clients().Update(timeCtx, oldInfo); err != nil {
+		core.WriteResponse(c, err, nil)
+		return
+	}
+
+	oldInfo.ClientSecret = ""
+	core.WriteResponse(c, nil, oldInfo)
+}
@@ -0,0 +1,50 @@
+package policy
+
+import (
+	"context"
+	"github.com/gin-gonic/gin"
+	"github.com/sirupsen/logrus"
+	"iam/internal/pkg/code"
+	"iam/internal/pkg/middleware"
+	"iam/pkg/api/policy"
+	"iam/pkg/core"
+	"iam/pkg/errors"
+	"time"
+)
+
+// Create 为当前登录用户创建策略 POST /v1/policies
+func (ctl *PolicyController) Create(c *gin.Context) {
+
+	var (
+		err   error
+		pInfo = new(policy.Policy)
+	)
+
+	username := c.GetString(middleware.UsernameKey)
+	if username == "" {
+		core.WriteResponse(c, errors.WithCode(code.ErrTokenInvalid, "user is not logged in"), nil)
+		return
+	}
+
+	if err = c.ShouldBindJSON(pInfo); err != nil {
+		logrus.Errorf("should bind policy err:%v", err)
+		core.WriteResponse(c, errors.WithCode(code.ErrBind, "%s", err.Error()), nil)
+		return
+	}
+
+	pInfo.Username = username
+	if fields := pInfo.Validate(); len(fields) > 0 {
+		core.WriteResponse(c, errors.WithCode(code.ErrValidation, "%s", fields.ToAggregate().Error()), nil)
+		return
+	}
+
+	timeCtx, cFunc := context.WithTimeout(context.Background(), time.Second*5)
+	defer cFunc()
+
+	if err = ctl.svc.Policies().Create(timeCtx, pInfo); err != nil {
+		core.WriteResponse(c, err, nil)
+		return
+	}
+
+	core.WriteResponse(c, nil, pInfo)
+}
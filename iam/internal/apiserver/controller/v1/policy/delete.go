@@ -0,0 +1,31 @@
+package policy
+
+import (
+	"context"
+	"github.com/gin-gonic/gin"
+	"iam/internal/pkg/code"
+	"iam/internal/pkg/middleware"
+	"iam/pkg/core"
+	"iam/pkg/errors"
+	"time"
+)
+
+// Delete 删除当前登录用户的策略 DELETE /v1/policies/:name
+func (ctl *PolicyController) Delete(c *gin.Context) {
+
+	username := c.GetString(middleware.UsernameKey)
+	if username == "" {
+		core.WriteResponse(c, errors.WithCode(code.ErrTokenInvalid, "user is not logged in"), nil)
+		return
+	}
+
+	timeCtx, cFunc := context.WithTimeout(context.Background(), time.Second*5)
+	defer cFunc()
+
+	if err := ctl.svc.Policies().Delete(timeCtx, username, c.Param("name")); err != nil {
+		core.WriteResponse(c, err, nil)
+		return
+	}
+
+	core.WriteResponse(c, nil, "ok")
+}
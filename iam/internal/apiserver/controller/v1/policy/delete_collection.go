@@ -0,0 +1,37 @@
+package policy
+
+import (
+	"context"
+	"github.com/gin-gonic/gin"
+	"iam/internal/pkg/code"
+	"iam/internal/pkg/middleware"
+	"iam/pkg/core"
+	"iam/pkg/errors"
+	"time"
+)
+
+// DeleteCollection 批量删除当前登录用户的策略 DELETE /v1/policies?name=a&name=b
+func (ctl *PolicyController) DeleteCollection(c *gin.Context) {
+
+	username := c.GetString(middleware.UsernameKey)
+	if username == "" {
+		core.WriteResponse(c, errors.WithCode(code.ErrTokenInvalid, "user is not logged in"), nil)
+		return
+	}
+
+	names := c.QueryArray("name")
+	if len(names) == 0 {
+		core.WriteResponse(c, errors.WithCode(code.ErrValidation, "query parameter name is required"), nil)
+		return
+	}
+
+	timeCtx, cFunc := context.WithTimeout(context.Background(), time.Second*5)
+	defer cFunc()
+
+	if err := ctl.svc.Policies().DeleteCollection(timeCtx, username, names); err != nil {
+		core.WriteResponse(c, err, nil)
+		return
+	}
+
+	core.WriteResponse(c, nil, "ok")
+}
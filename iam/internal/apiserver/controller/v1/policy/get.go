@@ -0,0 +1,32 @@
+package policy
+
+import (
+	"context"
+	"github.com/gin-gonic/gin"
+	"iam/internal/pkg/code"
+	"iam/internal/pkg/middleware"
+	"iam/pkg/core"
+	"iam/pkg/errors"
+	"time"
+)
+
+// Get 获取当前登录用户的某个策略 GET /v1/policies/:name
+func (ctl *PolicyController) Get(c *gin.Context) {
+
+	username := c.GetString(middleware.UsernameKey)
+	if username == "" {
+		core.WriteResponse(c, errors.WithCode(code.ErrTokenInvalid, "user is not logged in"), nil)
+		return
+	}
+
+	timeCtx, cFunc := context.WithTimeout(context.Background(), time.Second*5)
+	defer cFunc()
+
+	pInfo, err := ctl.svc.Policies().Get(timeCtx, username, c.Param("name"))
+	if err != nil {
+		core.WriteResponse(c, err, nil)
+		return
+	}
+
+	core.WriteResponse(c, nil, pInfo)
+}
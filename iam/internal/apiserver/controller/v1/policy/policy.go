@@ -0,0 +1,14 @@
+package policy
+
+import (
+	svcv1 "iam/internal/apiserver/service/v1"
+	"iam/internal/apiserver/store"
+)
+
+type PolicyController struct {
+	svc svcv1.Service
+}
+
+func NewPolicyCtl(factory store.Factory) *PolicyController {
+	return &PolicyController{svc: svcv1.NewSvc(factory)}
+}
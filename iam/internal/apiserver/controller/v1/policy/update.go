@@ -0,0 +1,60 @@
+package policy
+
+import (
+	"context"
+	"github.com/gin-gonic/gin"
+	"github.com/sirupsen/logrus"
+	"iam/internal/pkg/code"
+	"iam/internal/pkg/middleware"
+	"iam/pkg/api/policy"
+	"iam/pkg/core"
+	"iam/pkg/errors"
+	"time"
+)
+
+// Update 更新当前登录用户的策略 PUT /v1/policies/:name , 仅允许修改 policy, extend
+func (ctl *PolicyController) Update(c *gin.Context) {
+
+	var (
+		err   error
+		pInfo = new(policy.Policy)
+	)
+
+	username := c.GetString(middleware.UsernameKey)
+	if username == "" {
+		core.WriteResponse(c, errors.WithCode(code.ErrTokenInvalid, "user is not logged in"), nil)
+		return
+	}
+
+	if err = c.ShouldBindJSON(pInfo); err != nil {
+		logrus.Errorf("should bind policy err:%v", err)
+		core.WriteResponse(c, errors.WithCode(code.ErrBind, "%s", err.Error()), nil)
+		return
+	}
+
+	timeCtx, cFunc := context.WithTimeout(context.Background(), time.Second*5)
+	defer cFunc()
+
+	oldInfo, err := ctl.svc.Policies().Get(timeCtx, username, c.Param("name"))
+	if err != nil {
+		core.WriteResponse(c, err, nil)
+		return
+	}
+
+	oldInfo.Policy = pInfo.Policy
+	if pInfo.Extend != nil {
+		oldInfo.Extend = pInfo.Extend
+	}
+
+	if fields := oldInfo.Validate(); len(fields) > 0 {
+		core.WriteResponse(c, errors.WithCode(code.ErrValidation, "%s", fields.ToAggregate().Error()), nil)
+		return
+	}
+
+	if err = ctl.svc.Policies().Update(timeCtx, oldInfo); err != nil {
+		core.WriteResponse(c, err, nil)
+		return
+	}
+
+	core.WriteResponse(c, nil, oldInfo)
+}
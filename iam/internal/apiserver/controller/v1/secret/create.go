@@ -0,0 +1,50 @@
+package secret
+
+import (
+	"context"
+	"github.com/gin-gonic/gin"
+	"github.com/sirupsen/logrus"
+	"iam/internal/pkg/code"
+	"iam/internal/pkg/middleware"
+	"iam/pkg/api/secret"
+	"iam/pkg/core"
+	"iam/pkg/errors"
+	"time"
+)
+
+// Create 为当前登录用户创建密钥 POST /v1/secrets
+func (ctl *SecretController) Create(c *gin.Context) {
+
+	var (
+		err   error
+		sInfo = new(secret.Secret)
+	)
+
+	username := c.GetString(middleware.UsernameKey)
+	if username == "" {
+		core.WriteResponse(c, errors.WithCode(code.ErrTokenInvalid, "user is not logged in"), nil)
+		return
+	}
+
+	if err = c.ShouldBindJSON(sInfo); err != nil {
+		logrus.Errorf("should bind secret err:%v", err)
+		core.WriteResponse(c, errors.WithCode(code.ErrBind, "%s", err.Error()), nil)
+		return
+	}
+
+	sInfo.Username = username
+	if fields := sInfo.Validate(); len(fields) > 0 {
+		core.WriteResponse(c, errors.WithCode(code.ErrValidation, "%s", fields.ToAggregate().Error()), nil)
+		return
+	}
+
+	timeCtx, cFunc := context.WithTimeout(context.Background(), time.Second*5)
+	defer cFunc()
+
+	if err = ctl.svc.Secrets().Create(timeCtx, sInfo); err != nil {
+		core.WriteResponse(c, err, nil)
+		return
+	}
+
+	core.WriteResponse(c, nil, sInfo)
+}
@@ -0,0 +1,14 @@
+package secret
+
+import (
+	svcv1 "iam/internal/apiserver/service/v1"
+	"iam/internal/apiserver/store"
+)
+
+type SecretController struct {
+	svc svcv1.Service
+}
+
+func NewSecretCtl(factory store.Factory) *SecretController {
+	return &SecretController{svc: svcv1.NewSvc(factory)}
+}
@@ -0,0 +1,72 @@
+package secret
+
+import (
+	"context"
+	"github.com/gin-gonic/gin"
+	"github.com/sirupsen/logrus"
+	"iam/internal/pkg/code"
+	"iam/internal/pkg/middleware"
+	metav1 "iam/pkg/api/meta/v1"
+	"iam/pkg/core"
+	"iam/pkg/errors"
+	"time"
+)
+
+// UpdateSecretRequest 更新密钥所需参数, 未传的字段保持原值
+type UpdateSecretRequest struct {
+	Expires     *int64        `json:"expires"`
+	Description *string       `json:"description"`
+	Extend      metav1.Extend `json:"extend"`
+}
+
+// Update 更新当前登录用户的密钥 PUT /v1/secrets/:name , 仅允许修改 expires, description, extend
+func (ctl *SecretController) Update(c *gin.Context) {
+
+	var (
+		err error
+		req UpdateSecretRequest
+	)
+
+	username := c.GetString(middleware.UsernameKey)
+	if username == "" {
+		core.WriteResponse(c, errors.WithCode(code.ErrTokenInvalid, "user is not logged in"), nil)
+		return
+	}
+
+	if err = c.ShouldBindJSON(&req); err != nil {
+		logrus.Errorf("should bind secret err:%v", err)
+		core.WriteResponse(c, errors.WithCode(code.ErrBind, "%s", err.Error()), nil)
+		return
+	}
+
+	timeCtx, cFunc := context.WithTimeout(context.Background(), time.Second*5)
+	defer cFunc()
+
+	oldInfo, err := ctl.svc.Secrets().Get(timeCtx, username, c.Param("name"))
+	if err != nil {
+		core.WriteResponse(c, err, nil)
+		return
+	}
+
+	if req.Expires != nil {
+		oldInfo.Expires = *req.Expires
+	}
+	if req.Description != nil {
+		oldInfo.Description = *req.Description
+	}
+	if req.Extend != nil {
+		oldInfo.Extend = req.Extend
+	}
+
+	if fields := oldInfo.Validate(); len(fields) > 0 {
+		core.WriteResponse(c, errors.WithCode(code.ErrValidation, "%s", fields.ToAggregate().Error()), nil)
+		return
+	}
+
+	if err = ctl.svc.Secrets().Update(timeCtx, oldInfo); err != nil {
+		core.WriteResponse(c, err, nil)
+		return
+	}
+
+	core.WriteResponse(c, nil, oldInfo)
+}
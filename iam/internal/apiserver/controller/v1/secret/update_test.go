@@ -0,0 +1,80 @@
+package secret
+
+import (
+	"context"
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"iam/internal/apiserver/store"
+	"iam/internal/pkg/middleware"
+	metav1 "iam/pkg/api/meta/v1"
+	"iam/pkg/api/secret"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+type fakeFactory struct {
+	store.Factory
+	secrets *fakeSecrets
+}
+
+func (f *fakeFactory) Secrets() store.SecretStore { return f.secrets }
+
+type fakeSecrets struct {
+	store.SecretStore
+	item *secret.Secret
+}
+
+func (s *fakeSecrets) Get(_ context.Context, _, _ string) (*secret.Secret, error) {
+	copied := *s.item
+	return &copied, nil
+}
+
+func (s *fakeSecrets) Update(_ context.Context, sec *secret.Secret) error {
+	s.item = sec
+	return nil
+}
+
+func TestSecretController_Update(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	expires := time.Now().Add(time.Hour).Unix()
+	tests := []struct {
+		name        string
+		body        string
+		expires     int64
+		description string
+	}{
+		{"only description", `{"description":"new"}`, expires, "new"},
+		{"only expires", `{"expires":0}`, 0, "old"},
+		{"both", `{"expires":0,"description":""}`, 0, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			secrets := &fakeSecrets{item: &secret.Secret{
+				ObjectMeta:  metav1.ObjectMeta{Name: "s1"},
+				Username:    "colin",
+				Expires:     expires,
+				Description: "old",
+			}}
+			ctl := NewSecretCtl(&fakeFactory{secrets: secrets})
+
+			g := gin.New()
+			g.PUT("/v1/secrets/:name", func(c *gin.Context) {
+				c.Set(middleware.UsernameKey, "colin")
+			}, ctl.Update)
+
+			req := httptest.NewRequest(http.MethodPut, "/v1/secrets/s1", strings.NewReader(tt.body))
+			req.Header.Set("Content-Type", "application/json")
+			w := httptest.NewRecorder()
+			g.ServeHTTP(w, req)
+
+			// 未传的字段保持原值
+			assert.Equal(t, http.StatusOK, w.Code, w.Body.String())
+			assert.Equal(t, tt.expires, secrets.item.Expires)
+			assert.Equal(t, tt.description, secrets.item.Description)
+		})
+	}
+}
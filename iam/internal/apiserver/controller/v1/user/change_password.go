@@ -0,0 +1,77 @@
+package user
+
+import (
+	"context"
+	"github.com/gin-gonic/gin"
+	"github.com/sirupsen/logrus"
+	"iam/internal/pkg/code"
+	"iam/pkg/api/user"
+	"iam/pkg/core"
+	"iam/pkg/errors"
+	"iam/pkg/validation"
+	"time"
+)
+
+// ChangePasswordRequest 修改密码所需参数
+type ChangePasswordRequest struct {
+	// Old password.
+	OldPassword string `json:"oldPassword" binding:"required"`
+
+	// New password.
+	NewPassword string `json:"newPassword" binding:"required"`
+}
+
+// ChangePassword 修改用户密码 PUT /v1/users/:name/change-password , 仅允许本人或管理员操作, 需验证旧密码
+func (ctl *UserController) ChangePassword(c *gin.Context) {
+
+	if !ctl.ownerOrAdmin(c) {
+		return
+	}
+
+	var req ChangePasswordRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		logrus.Errorf("should bind change password err:%v", err)
+		core.WriteResponse(c, errors.WithCode(code.ErrBind, "%s", err.Error()), nil)
+		return
+	}
+
+	timeCtx, cFunc := context.WithTimeout(context.Background(), time.Second*5)
+	defer cFunc()
+
+	uInfo, err := ctl.svc.User().GetUserByName(timeCtx, c.Param("name"))
+	if err != nil {
+		core.WriteResponse(c, err, nil)
+		return
+	}
+
+	// 验证旧密码
+	if err = uInfo.Compare(req.OldPassword); err != nil {
+		core.WriteResponse(c, errors.WithCode(code.ErrPasswordIncorrect, "old password is incorrect"), nil)
+		return
+	}
+
+	if err = validation.IsValidPassword(req.NewPassword); err != nil {
+		core.WriteResponse(c, errors.WithCode(code.ErrValidation, "invalid new password: %v", err), nil)
+		return
+	}
+
+	uInfo.Password, err = user.GenerateHashPwd(req.NewPassword)
+	if err != nil {
+		logrus.Errorf("hash pwd err:%v", err)
+		core.WriteResponse(c, errors.WrapC(err, code.ErrEncrypt, "hash password failed"), nil)
+		return
+	}
+
+	if err = ctl.svc.User().UpdateUser(timeCtx, uInfo); err != nil {
+		core.WriteResponse(c, err, nil)
+		return
+	}
+
+	// 修改密码后之前签发的 token 全部失效
+	if err = ctl.revokeTokens(uInfo.Name); err != nil {
+		core.WriteResponse(c, errors.WrapC(err, code.ErrUnknown, "password changed, but revoke tokens failed"), nil)
+		return
+	}
+
+	core.WriteResponse(c, nil, "ok")
+}
@@ -2,12 +2,12 @@ package user
 
 import (
 	"context"
-	"fmt"
 	"github.com/gin-gonic/gin"
 	"github.com/sirupsen/logrus"
+	"iam/internal/pkg/code"
 	"iam/pkg/api/user"
 	"iam/pkg/core"
-	"net/http"
+	"iam/pkg/errors"
 	"time"
 )
 
@@ -21,7 +21,7 @@ func (ctl *UserController) Create(c *gin.Context) {
 	err = c.ShouldBind(uInfo)
 	if err != nil {
 		logrus.Errorf("should bind user err:%v", err)
-		core.WriteResponse(c, http.StatusBadRequest, nil, fmt.Sprintf("err:%v", err))
+		core.WriteResponse(c, errors.WithCode(code.ErrBind, "%s", err.Error()), nil)
 		return
 	}
 
@@ -34,14 +34,14 @@ func (ctl *UserController) Create(c *gin.Context) {
 	if len(fields) > 0 {
 		// 说明此时存在问题,例如密码长度不符合等操作
 		logrus.Errorf("hash pwd err:%v", err)
-		core.WriteResponse(c, http.StatusBadRequest, nil, fmt.Sprintf("用户参数存在问题:%v", fields))
+		core.WriteResponse(c, errors.WithCode(code.ErrValidation, "%s", fields.ToAggregate().Error()), nil)
 		return
 	}
 
 	uInfo.Password, err = user.GenerateHashPwd(uInfo.Password)
 	if err != nil {
 		logrus.Errorf("hash pwd err:%v", err)
-		core.WriteResponse(c, http.StatusInternalServerError, nil, "创建失败")
+		core.WriteResponse(c, errors.WrapC(err, code.ErrEncrypt, "hash password failed"), nil)
 		return
 	}
 
@@ -52,11 +52,11 @@ func (ctl *UserController) Create(c *gin.Context) {
 
 	err = ctl.svc.User().CreateUser(timeCtx, uInfo)
 	if err != nil {
-		core.WriteResponse(c, http.StatusInternalServerError, nil, fmt.Sprintf("operate db err:%v", err))
+		core.WriteResponse(c, err, nil)
 		return
 	}
 
-	core.WriteResponse(c, http.StatusOK, nil, "ok")
+	core.WriteResponse(c, nil, "ok")
 
 	return
 }
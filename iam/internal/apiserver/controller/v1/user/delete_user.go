@@ -0,0 +1,44 @@
+package user
+
+import (
+	"context"
+	"github.com/gin-gonic/gin"
+	"iam/internal/pkg/code"
+	"iam/pkg/core"
+	"iam/pkg/errors"
+	"time"
+)
+
+// Delete 删除用户 DELETE /v1/users/:name , 仅允许本人或管理员操作, 用户不存在时同样返回成功
+func (ctl *UserController) Delete(c *gin.Context) {
+
+	if !ctl.ownerOrAdmin(c) {
+		return
+	}
+
+	timeCtx, cFunc := context.WithTimeout(context.Background(), time.Second*5)
+	defer cFunc()
+
+	uInfo, err := ctl.svc.User().GetUserByName(timeCtx, c.Param("name"))
+	if err != nil {
+		if errors.IsCode(err, code.ErrUserNotFound) {
+			core.WriteResponse(c, nil, "ok")
+			return
+		}
+		core.WriteResponse(c, err, nil)
+		return
+	}
+
+	if err = ctl.svc.User().DeleteUser(timeCtx, uInfo.ID); err != nil {
+		core.WriteResponse(c, err, nil)
+		return
+	}
+
+	// 已删除用户的 token 全部失效
+	if err = ctl.revokeTokens(uInfo.Name); err != nil {
+		core.WriteResponse(c, errors.WrapC(err, code.ErrUnknown, "user deleted, but revoke tokens failed"), nil)
+		return
+	}
+
+	core.WriteResponse(c, nil, "ok")
+}
@@ -0,0 +1,30 @@
+package user
+
+import (
+	"context"
+	"github.com/gin-gonic/gin"
+	"github.com/sirupsen/logrus"
+	"iam/pkg/core"
+	"time"
+)
+
+// Get 根据用户名获取用户信息 GET /v1/users/:name , 仅允许本人或管理员操作
+func (ctl *UserController) Get(c *gin.Context) {
+
+	if !ctl.ownerOrAdmin(c) {
+		return
+	}
+
+	timeCtx, cFunc := context.WithTimeout(context.Background(), time.Second*5)
+	defer cFunc()
+
+	uInfo, err := ctl.svc.User().GetUserByName(timeCtx, c.Param("name"))
+	if err != nil {
+		logrus.Errorf("get user:%s err:%v", c.Param("name"), err)
+		core.WriteResponse(c, err, nil)
+		return
+	}
+
+	uInfo.Password = ""
+	core.WriteResponse(c, nil, uInfo)
+}
@@ -0,0 +1,39 @@
+package user
+
+import (
+	"context"
+	"github.com/gin-gonic/gin"
+	"github.com/sirupsen/logrus"
+	"iam/internal/pkg/code"
+	metav1 "iam/pkg/api/meta/v1"
+	"iam/pkg/core"
+	"iam/pkg/errors"
+	"time"
+)
+
+// List 分页获取用户列表 GET /v1/users?offset=0&limit=10 , 仅允许管理员操作
+func (ctl *UserController) List(c *gin.Context) {
+
+	if !ctl.adminOnly(c, "only admin can list users") {
+		return
+	}
+
+	var opts metav1.ListOptions
+	if err := c.ShouldBindQuery(&opts); err != nil {
+		logrus.Errorf("should bind list options err:%v", err)
+		core.WriteResponse(c, errors.WithCode(code.ErrBind, "%s", err.Error()), nil)
+		return
+	}
+
+	timeCtx, cFunc := context.WithTimeout(context.Background(), time.Second*5)
+	defer cFunc()
+
+	users, err := ctl.svc.User().List(timeCtx, opts)
+	if err != nil {
+		logrus.Errorf("list users err:%v", err)
+		core.WriteResponse(c, err, nil)
+		return
+	}
+
+	core.WriteResponse(c, nil, users)
+}
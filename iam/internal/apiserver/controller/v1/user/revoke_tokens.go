@@ -0,0 +1,24 @@
+package user
+
+import (
+	"github.com/gin-gonic/gin"
+	"iam/internal/pkg/code"
+	"iam/pkg/core"
+	"iam/pkg/errors"
+)
+
+// RevokeTokens 吊销用户已签发的所有 token POST /v1/users/:name/revoke-tokens , 仅允许本人或管理员操作
+func (ctl *UserController) RevokeTokens(c *gin.Context) {
+
+	if !ctl.ownerOrAdmin(c) {
+		return
+	}
+
+	name := c.Param("name")
+	if err := ctl.revokeTokens(name); err != nil {
+		core.WriteResponse(c, errors.WrapC(err, code.ErrUnknown, "revoke tokens of user %s failed", name), nil)
+		return
+	}
+
+	core.WriteResponse(c, nil, "ok")
+}
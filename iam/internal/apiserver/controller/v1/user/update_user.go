@@ -0,0 +1,63 @@
+package user
+
+import (
+	"context"
+	"github.com/gin-gonic/gin"
+	"github.com/sirupsen/logrus"
+	"iam/internal/pkg/code"
+	"iam/pkg/api/user"
+	"iam/pkg/core"
+	"iam/pkg/errors"
+	"time"
+)
+
+// Update 更新用户信息 PUT /v1/users/:name , 仅允许本人或管理员修改 nickname, email, extend
+func (ctl *UserController) Update(c *gin.Context) {
+
+	if !ctl.ownerOrAdmin(c) {
+		return
+	}
+
+	var (
+		err   error
+		uInfo = new(user.User)
+	)
+
+	if err = c.ShouldBindJSON(uInfo); err != nil {
+		logrus.Errorf("should bind user err:%v", err)
+		core.WriteResponse(c, errors.WithCode(code.ErrBind, "%s", err.Error()), nil)
+		return
+	}
+
+	timeCtx, cFunc := context.WithTimeout(context.Background(), time.Second*5)
+	defer cFunc()
+
+	oldInfo, err := ctl.svc.User().GetUserByName(timeCtx, c.Param("name"))
+	if err != nil {
+		core.WriteResponse(c, err, nil)
+		return
+	}
+
+	if uInfo.NickName != "" {
+		oldInfo.NickName = uInfo.NickName
+	}
+	if uInfo.Email != "" {
+		oldInfo.Email = uInfo.Email
+	}
+	if uInfo.Extend != nil {
+		oldInfo.Extend = uInfo.Extend
+	}
+
+	if fields := oldInfo.ValidateUpdate(); len(fields) > 0 {
+		core.WriteResponse(c, errors.WithCode(code.ErrValidation, "%s", fields.ToAggregate().Error()), nil)
+		return
+	}
+
+	if err = ctl.svc.User().UpdateUser(timeCtx, oldInfo); err != nil {
+		core.WriteResponse(c, err, nil)
+		return
+	}
+
+	oldInfo.Password = ""
+	core.WriteResponse(c, nil, oldInfo)
+}
@@ -1,14 +1,63 @@
 package user
 
 import (
+	"context"
+	"github.com/gin-gonic/gin"
 	svcv1 "iam/internal/apiserver/service/v1"
 	"iam/internal/apiserver/store"
+	"iam/internal/pkg/code"
+	"iam/internal/pkg/middleware"
+	"iam/pkg/core"
+	"iam/pkg/errors"
+	"time"
 )
 
+// TokenRevoker 吊销用户已签发的所有 token
+type TokenRevoker interface {
+	RevokeUser(username string, at time.Time) error
+}
+
 type UserController struct {
-	svc svcv1.Service
+	svc     svcv1.Service
+	revoker TokenRevoker // 为空时修改密码和删除用户不吊销 token
+}
+
+func NewUserCtl(factory store.Factory, revoker TokenRevoker) *UserController {
+	return &UserController{svc: svcv1.NewSvc(factory), revoker: revoker}
+}
+
+// revokeTokens 吊销用户当前时间及之前签发的 token
+func (ctl *UserController) revokeTokens(username string) error {
+	if ctl.revoker == nil {
+		return nil
+	}
+
+	return ctl.revoker.RevokeUser(username, time.Now())
+}
+
+// ownerOrAdmin 仅允许本人或管理员操作 :name 对应的用户, 不允许时写入错误并返回 false
+func (ctl *UserController) ownerOrAdmin(c *gin.Context) bool {
+	if c.GetString(middleware.UsernameKey) == c.Param("name") {
+		return true
+	}
+
+	return ctl.adminOnly(c, "only admin can operate other users")
 }
 
-func NewUserCtl(factory store.Factory) *UserController {
-	return &UserController{svc: svcv1.NewSvc(factory)}
+// adminOnly 仅允许管理员操作, 不允许时写入错误并返回 false
+func (ctl *UserController) adminOnly(c *gin.Context, msg string) bool {
+	timeCtx, cFunc := context.WithTimeout(context.Background(), time.Second*5)
+	defer cFunc()
+
+	opInfo, err := ctl.svc.User().GetUserByName(timeCtx, c.GetString(middleware.UsernameKey))
+	if err != nil {
+		core.WriteResponse(c, err, nil)
+		return false
+	}
+	if opInfo.IsAdmin != 1 {
+		core.WriteResponse(c, errors.WithCode(code.ErrPermissionDenied, "%s", msg), nil)
+		return false
+	}
+
+	return true
 }
@@ -0,0 +1,131 @@
+package user
+
+import (
+	"context"
+	"encoding/json"
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"iam/internal/apiserver/store"
+	"iam/internal/pkg/code"
+	"iam/internal/pkg/middleware"
+	metav1 "iam/pkg/api/meta/v1"
+	"iam/pkg/api/user"
+	"iam/pkg/core"
+	"iam/pkg/errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+type fakeFactory struct {
+	store.Factory
+	users *fakeUsers
+}
+
+func (f *fakeFactory) User() store.UserStore { return f.users }
+
+type fakeUsers struct {
+	store.UserStore
+	items   map[string]*user.User
+	deleted []uint64
+}
+
+func (s *fakeUsers) GetUserByName(_ context.Context, username string) (*user.User, error) {
+	if u, ok := s.items[username]; ok {
+		copied := *u
+		return &copied, nil
+	}
+	return nil, errors.WithCode(code.ErrUserNotFound, "user %s not found", username)
+}
+
+func (s *fakeUsers) List(_ context.Context, _ metav1.ListOptions) (*user.UserList, error) {
+	list := &user.UserList{}
+	for _, u := range s.items {
+		list.Items = append(list.Items, u)
+	}
+	return list, nil
+}
+
+func (s *fakeUsers) UpdateUser(_ context.Context, u *user.User) error {
+	s.items[u.Name] = u
+	return nil
+}
+
+func (s *fakeUsers) DeleteUser(_ context.Context, id uint64) error {
+	s.deleted = append(s.deleted, id)
+	return nil
+}
+
+type fakeRevoker []string
+
+func (r *fakeRevoker) RevokeUser(username string, _ time.Time) error {
+	*r = append(*r, username)
+	return nil
+}
+
+func TestOwnerOrAdmin(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	users := &fakeUsers{items: map[string]*user.User{
+		"colin": {ObjectMeta: metav1.ObjectMeta{ID: 1, Name: "colin"}, Email: "colin@example.com"},
+		"tom":   {ObjectMeta: metav1.ObjectMeta{ID: 2, Name: "tom"}, Email: "tom@example.com"},
+		"admin": {ObjectMeta: metav1.ObjectMeta{ID: 3, Name: "admin"}, Email: "admin@example.com", IsAdmin: 1},
+	}}
+	revoker := &fakeRevoker{}
+	ctl := NewUserCtl(&fakeFactory{users: users}, revoker)
+
+	g := gin.New()
+	group := g.Group("/v1/users", func(c *gin.Context) {
+		c.Set(middleware.UsernameKey, c.GetHeader("X-Username"))
+	})
+	group.GET("", ctl.List)
+	group.GET("/:name", ctl.Get)
+	group.PUT("/:name", ctl.Update)
+	group.DELETE("/:name", ctl.Delete)
+	group.PUT("/:name/change-password", ctl.ChangePassword)
+	group.POST("/:name/revoke-tokens", ctl.RevokeTokens)
+
+	tests := []struct {
+		name     string
+		operator string
+		method   string
+		path     string
+		body     string
+		status   int
+	}{
+		{"list", "colin", http.MethodGet, "/v1/users", "", http.StatusForbidden},
+		{"admin list", "admin", http.MethodGet, "/v1/users", "", http.StatusOK},
+		{"get self", "colin", http.MethodGet, "/v1/users/colin", "", http.StatusOK},
+		{"get other", "colin", http.MethodGet, "/v1/users/tom", "", http.StatusForbidden},
+		{"admin get other", "admin", http.MethodGet, "/v1/users/tom", "", http.StatusOK},
+		{"update other", "colin", http.MethodPut, "/v1/users/tom", `{"nickname":"x"}`, http.StatusForbidden},
+		{"delete other", "colin", http.MethodDelete, "/v1/users/tom", "", http.StatusForbidden},
+		{"change password of other", "colin", http.MethodPut, "/v1/users/tom/change-password",
+			`{"oldPassword":"a","newPassword":"b"}`, http.StatusForbidden},
+		{"revoke tokens of other", "colin", http.MethodPost, "/v1/users/tom/revoke-tokens", "", http.StatusForbidden},
+		{"admin delete other", "admin", http.MethodDelete, "/v1/users/tom", "", http.StatusOK},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(tt.method, tt.path, strings.NewReader(tt.body))
+			req.Header.Set("Content-Type", "application/json")
+			req.Header.Set("X-Username", tt.operator)
+			w := httptest.NewRecorder()
+			g.ServeHTTP(w, req)
+
+			assert.Equal(t, tt.status, w.Code, w.Body.String())
+			if tt.status == http.StatusForbidden {
+				var rsp core.ErrResponse
+				assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &rsp))
+				assert.Equal(t, code.ErrPermissionDenied, rsp.Code)
+			}
+		})
+	}
+
+	// 仅管理员删除成功, 被拒绝的操作没有修改数据
+	assert.Equal(t, []uint64{2}, users.deleted)
+	assert.Equal(t, []string{"tom"}, []string(*revoker))
+	assert.Empty(t, users.items["tom"].NickName)
+}
@@ -0,0 +1,283 @@
+package federation
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"github.com/golang-jwt/jwt/v4"
+	"github.com/sirupsen/logrus"
+	"iam/internal/apiserver/store"
+	"iam/internal/pkg/code"
+	"iam/internal/pkg/options"
+	metav1 "iam/pkg/api/meta/v1"
+	"iam/pkg/api/user"
+	"iam/pkg/errors"
+	"iam/pkg/jwks"
+	"iam/pkg/util/idutil"
+	"io"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Federation 使用上游 OIDC IdP 签发的 id token 登录, 按 iss 选择上游, 验证后映射为本地用户.
+// 上游用户通过 issuer + subject 关联本地用户, 不会按用户名关联已有的本地用户, 避免上游用户冒用本地账号
+type Federation struct {
+	upstreams map[string]*upstream
+	factory   store.Factory
+}
+
+type upstream struct {
+	opts            options.UpstreamProviderOptions
+	client          *http.Client
+	refreshInterval time.Duration // 重新获取 jwks 的间隔
+
+	mu   sync.Mutex
+	keys *jwks.Remote // 首次使用时通过 discovery 获取 jwks_uri, 上游不可用时不影响 apiserver 启动
+}
+
+func New(opts *options.FederationOptions, factory store.Factory) *Federation {
+	f := &Federation{upstreams: make(map[string]*upstream, len(opts.Providers)), factory: factory}
+
+	client := &http.Client{Timeout: opts.Timeout}
+	for _, p := range opts.Providers {
+		p.Complete()
+		f.upstreams[p.Issuer] = &upstream{opts: p, client: client, refreshInterval: opts.JWKSRefreshInterval}
+	}
+
+	return f
+}
+
+// Authenticate 验证 id token 的签名, iss, aud 和有效期, 返回关联的本地用户, 开启自动创建时为首次登录的用户创建本地用户
+func (f *Federation) Authenticate(ctx context.Context, idToken string) (*user.User, error) {
+	unverified, _, err := jwt.NewParser().ParseUnverified(idToken, jwt.MapClaims{})
+	if err != nil {
+		return nil, errors.WithCode(code.ErrTokenInvalid, "invalid id token: %s", err.Error())
+	}
+
+	issuer, _ := unverified.Claims.(jwt.MapClaims)["iss"].(string)
+	up, ok := f.upstreams[issuer]
+	if !ok {
+		return nil, errors.WithCode(code.ErrTokenInvalid, "unknown issuer %q", issuer)
+	}
+
+	token, err := jwt.Parse(idToken, up.keyfunc)
+	if err != nil || !token.Valid {
+		return nil, errors.WithCode(code.ErrTokenInvalid, "verify id token of %s failed: %v", up.opts.Name, err)
+	}
+
+	// jwt.Parse 只在 claim 存在时验证, 没有 exp 的 id token 永久有效, 必须拒绝
+	claims, _ := token.Claims.(jwt.MapClaims)
+	now := time.Now().Unix()
+	if !claims.VerifyExpiresAt(now, true) || !claims.VerifyIssuedAt(now, true) {
+		return nil, errors.WithCode(code.ErrTokenInvalid, "id token of %s must have exp and iat", up.opts.Name)
+	}
+	if !claims.VerifyAudience(up.opts.ClientID, true) {
+		return nil, errors.WithCode(code.ErrTokenInvalid, "id token of %s is not issued to %s", up.opts.Name, up.opts.ClientID)
+	}
+	if azp, ok := claims["azp"].(string); ok && azp != up.opts.ClientID {
+		return nil, errors.WithCode(code.ErrTokenInvalid, "id token of %s is authorized to %s", up.opts.Name, azp)
+	}
+
+	subject, _ := claims["sub"].(string)
+	if subject == "" {
+		return nil, errors.WithCode(code.ErrTokenInvalid, "id token of %s has no sub", up.opts.Name)
+	}
+
+	groups := stringList(claims[up.opts.Claims.Groups])
+	if len(up.opts.AllowedGroups) > 0 && !intersects(groups, up.opts.AllowedGroups) {
+		return nil, errors.WithCode(code.ErrPermissionDenied, "user %s of %s is not in the allowed groups", subject, up.opts.Name)
+	}
+
+	return f.localUser(ctx, up, subject, claims, groups)
+}
+
+// localUser 获取关联的本地用户并同步上游的信息, 未关联时按配置自动创建
+func (f *Federation) localUser(ctx context.Context, up *upstream, subject string, claims jwt.MapClaims,
+	groups []string) (*user.User, error) {
+	identity, err := f.factory.Identities().Get(ctx, up.opts.Issuer, subject)
+	if err == nil {
+		// 本地用户被禁用时查询不到, 登录失败
+		u, err := f.factory.User().GetUserByName(ctx, identity.Username)
+		if err != nil {
+			return nil, err
+		}
+
+		if up.apply(u, claims, groups) {
+			if err = f.factory.User().UpdateUser(ctx, u); err != nil {
+				return nil, err
+			}
+		}
+
+		return u, nil
+	}
+	if !errors.IsCode(err, code.ErrIdentityNotFound) {
+		return nil, err
+	}
+
+	if !up.opts.AutoProvision {
+		return nil, errors.WithCode(code.ErrPermissionDenied, "user %s of %s is not linked to a local user", subject, up.opts.Name)
+	}
+
+	return f.provision(ctx, up, subject, claims, groups)
+}
+
+// provision 创建本地用户及关联, 本地密码随机生成, 只能通过上游登录; 同名的本地用户已存在时失败
+func (f *Federation) provision(ctx context.Context, up *upstream, subject string, claims jwt.MapClaims,
+	groups []string) (*user.User, error) {
+	username, _ := claims[up.opts.Claims.Username].(string)
+	if username == "" {
+		return nil, errors.WithCode(code.ErrValidation, "id token of %s has no %s claim", up.opts.Name, up.opts.Claims.Username)
+	}
+
+	u := &user.User{
+		ObjectMeta: metav1.ObjectMeta{Name: up.opts.UsernamePrefix + username},
+		Status:     1,
+		Password:   idutil.NewSecretKey()[:12] + "aA1!", // 满足密码长度和复杂度
+	}
+	up.apply(u, claims, groups)
+
+	if fields := u.Validate(); len(fields) > 0 {
+		return nil, errors.WithCode(code.ErrValidation, "%s", fields.ToAggregate().Error())
+	}
+
+	hashed, err := user.GenerateHashPwd(u.Password)
+	if err != nil {
+		return nil, errors.WrapC(err, code.ErrEncrypt, "hash password failed")
+	}
+	u.Password = hashed
+
+	if err = f.factory.User().CreateUser(ctx, u); err != nil {
+		return nil, err
+	}
+
+	identity := &user.Identity{Username: u.Name, Issuer: up.opts.Issuer, Subject: subject}
+	if err = f.factory.Identities().Create(ctx, identity); err != nil {
+		// 关联失败时删除刚创建的用户, 否则该用户名无法再次自动创建
+		if delErr := f.factory.User().DeleteUser(ctx, u.ID); delErr != nil {
+			logrus.Errorf("delete provisioned user %s failed: %v", u.Name, delErr)
+		}
+		return nil, err
+	}
+
+	logrus.Infof("provisioned user %s for %s of %s", u.Name, subject, up.opts.Name)
+
+	return u, nil
+}
+
+// apply 将 claim 映射到用户字段, 返回是否有变化
+func (up *upstream) apply(u *user.User, claims jwt.MapClaims, groups []string) bool {
+	changed := false
+
+	if nickname, _ := claims[up.opts.Claims.Nickname].(string); nickname != "" && nickname != u.NickName {
+		u.NickName, changed = nickname, true
+	}
+	if email, _ := claims[up.opts.Claims.Email].(string); email != "" && email != u.Email {
+		u.Email, changed = email, true
+	}
+
+	// 配置了管理员组时以上游为准, 移出组后取消管理员
+	if len(up.opts.AdminGroups) > 0 {
+		isAdmin := 0
+		if intersects(groups, up.opts.AdminGroups) {
+			isAdmin = 1
+		}
+		if isAdmin != u.IsAdmin {
+			u.IsAdmin, changed = isAdmin, true
+		}
+	}
+
+	return changed
+}
+
+func (up *upstream) keyfunc(token *jwt.Token) (interface{}, error) {
+	keys, err := up.remote()
+	if err != nil {
+		return nil, err
+	}
+
+	return keys.Keyfunc(token)
+}
+
+// remote 获取上游的 jwks, 未配置 jwks-uri 时通过 discovery 获取, 失败时下次登录重试
+func (up *upstream) remote() (*jwks.Remote, error) {
+	up.mu.Lock()
+	defer up.mu.Unlock()
+
+	if up.keys != nil {
+		return up.keys, nil
+	}
+
+	uri := up.opts.JWKSURI
+	if uri == "" {
+		var err error
+		if uri, err = up.discover(); err != nil {
+			return nil, err
+		}
+	}
+	up.keys = jwks.NewRemote(uri, up.client, up.refreshInterval)
+
+	return up.keys, nil
+}
+
+// discover 获取上游的 openid-configuration, 其中的 issuer 必须与配置一致
+func (up *upstream) discover() (string, error) {
+	url := strings.TrimRight(up.opts.Issuer, "/") + "/.well-known/openid-configuration"
+
+	rsp, err := up.client.Get(url)
+	if err != nil {
+		return "", fmt.Errorf("fetch %s: %w", url, err)
+	}
+	defer rsp.Body.Close()
+
+	if rsp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("fetch %s: unexpected status %d", url, rsp.StatusCode)
+	}
+
+	var doc struct {
+		Issuer  string `json:"issuer"`
+		JWKSURI string `json:"jwks_uri"`
+	}
+	if err = json.NewDecoder(io.LimitReader(rsp.Body, 1<<20)).Decode(&doc); err != nil {
+		return "", fmt.Errorf("decode %s: %w", url, err)
+	}
+	if doc.Issuer != up.opts.Issuer {
+		return "", fmt.Errorf("issuer %q in %s does not match %q", doc.Issuer, url, up.opts.Issuer)
+	}
+	if doc.JWKSURI == "" {
+		return "", fmt.Errorf("no jwks_uri in %s", url)
+	}
+
+	return doc.JWKSURI, nil
+}
+
+// stringList 组信息可能是字符串数组, 也可能是单个字符串
+func stringList(v interface{}) []string {
+	switch value := v.(type) {
+	case string:
+		return []string{value}
+	case []interface{}:
+		items := make([]string, 0, len(value))
+		for _, item := range value {
+			if s, ok := item.(string); ok {
+				items = append(items, s)
+			}
+		}
+		return items
+	}
+
+	return nil
+}
+
+func intersects(a, b []string) bool {
+	for _, x := range a {
+		for _, y := range b {
+			if x == y {
+				return true
+			}
+		}
+	}
+
+	return false
+}
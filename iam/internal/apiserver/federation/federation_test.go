@@ -0,0 +1,311 @@
+package federation
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"github.com/gin-gonic/gin"
+	"github.com/golang-jwt/jwt/v4"
+	"github.com/spf13/viper"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/crypto/bcrypt"
+	"iam/internal/apiserver/store"
+	"iam/internal/pkg/code"
+	"iam/internal/pkg/middleware/auth"
+	"iam/internal/pkg/options"
+	metav1 "iam/pkg/api/meta/v1"
+	"iam/pkg/api/user"
+	"iam/pkg/errors"
+	"iam/pkg/jwks"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+)
+
+type fakeFactory struct {
+	store.Factory
+	users      *fakeUsers
+	identities *fakeIdentities
+}
+
+func (f *fakeFactory) User() store.UserStore           { return f.users }
+func (f *fakeFactory) Identities() store.IdentityStore { return f.identities }
+
+type fakeUsers struct {
+	store.UserStore
+	mu    sync.Mutex
+	items map[string]*user.User
+}
+
+func (s *fakeUsers) CreateUser(_ context.Context, u *user.User) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.items[u.Name]; ok {
+		return errors.WithCode(code.ErrUserAlreadyExist, "user %s already exist", u.Name)
+	}
+	u.ID = uint64(len(s.items) + 1)
+	s.items[u.Name] = u
+	return nil
+}
+
+func (s *fakeUsers) UpdateUser(_ context.Context, u *user.User) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.items[u.Name] = u
+	return nil
+}
+
+func (s *fakeUsers) GetUserByName(_ context.Context, username string) (*user.User, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if u, ok := s.items[username]; ok {
+		copied := *u
+		return &copied, nil
+	}
+	return nil, errors.WithCode(code.ErrUserNotFound, "user %s not found", username)
+}
+
+type fakeIdentities struct {
+	mu    sync.Mutex
+	items map[string]*user.Identity
+}
+
+func (s *fakeIdentities) Create(_ context.Context, identity *user.Identity) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.items[identity.Issuer+"|"+identity.Subject] = identity
+	return nil
+}
+
+func (s *fakeIdentities) Get(_ context.Context, issuer, subject string) (*user.Identity, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if identity, ok := s.items[issuer+"|"+subject]; ok {
+		return identity, nil
+	}
+	return nil, errors.WithCode(code.ErrIdentityNotFound, "identity not found")
+}
+
+func newFakeFactory(t *testing.T) *fakeFactory {
+	password, err := bcrypt.GenerateFromPassword([]byte("Colin@2024"), bcrypt.MinCost)
+	require.NoError(t, err)
+
+	return &fakeFactory{
+		users: &fakeUsers{items: map[string]*user.User{
+			"colin":      {ObjectMeta: metav1.ObjectMeta{Name: "colin"}, Email: "colin@example.com", Password: string(password)},
+			"corp-admin": {ObjectMeta: metav1.ObjectMeta{Name: "corp-admin"}, Email: "admin@example.com", IsAdmin: 1},
+		}},
+		identities: &fakeIdentities{items: map[string]*user.Identity{}},
+	}
+}
+
+// testIdP 本地的上游 IdP, 提供 discovery 和 jwks
+type testIdP struct {
+	server *httptest.Server
+	keys   *jwks.KeySet
+}
+
+func newTestIdP(t *testing.T) *testIdP {
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+	key, err := jwks.NewKey("corp-1", rsaKey)
+	require.NoError(t, err)
+	keys, err := jwks.New("corp-1", key)
+	require.NoError(t, err)
+
+	idp := &testIdP{keys: keys}
+	mux := http.NewServeMux()
+	mux.HandleFunc("/.well-known/openid-configuration", func(w http.ResponseWriter, r *http.Request) {
+		_ = json.NewEncoder(w).Encode(map[string]string{"issuer": idp.server.URL, "jwks_uri": idp.server.URL + "/keys"})
+	})
+	mux.HandleFunc("/keys", func(w http.ResponseWriter, r *http.Request) {
+		_ = json.NewEncoder(w).Encode(idp.keys.JWKS())
+	})
+	idp.server = httptest.NewServer(mux)
+	t.Cleanup(idp.server.Close)
+
+	return idp
+}
+
+func (idp *testIdP) idToken(t *testing.T, claims jwt.MapClaims) string {
+	defaults := jwt.MapClaims{
+		"iss":                idp.server.URL,
+		"aud":                []string{"iam"},
+		"sub":                "10001",
+		"preferred_username": "alice",
+		"name":               "Alice",
+		"email":              "alice@corp.example.com",
+		"groups":             []string{"staff", "iam-admins"},
+		"iat":                time.Now().Unix(),
+		"exp":                time.Now().Add(time.Hour).Unix(),
+	}
+	for key, value := range claims {
+		if value == nil {
+			delete(defaults, key)
+			continue
+		}
+		defaults[key] = value
+	}
+
+	token, err := idp.keys.Sign(defaults)
+	require.NoError(t, err)
+	return token
+}
+
+func (idp *testIdP) provider(autoProvision bool) options.UpstreamProviderOptions {
+	return options.UpstreamProviderOptions{
+		Name:           "corp",
+		Issuer:         idp.server.URL,
+		ClientID:       "iam",
+		AutoProvision:  autoProvision,
+		UsernamePrefix: "corp-",
+		AdminGroups:    []string{"iam-admins"},
+		AllowedGroups:  []string{"staff"},
+	}
+}
+
+func newFederation(factory store.Factory, providers ...options.UpstreamProviderOptions) *Federation {
+	opts := options.NewFederationOptions()
+	opts.Providers = providers
+
+	return New(opts, factory)
+}
+
+func TestFederatedLogin(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	viper.Set("jwt.timeout", time.Hour)
+	viper.Set("jwt.max-refresh", time.Hour)
+
+	idp := newTestIdP(t)
+	factory := newFakeFactory(t)
+	store.SetFactory(factory) // 登录成功后更新登录时间
+
+	iamKeys, err := jwks.NewHMAC([]byte("iam-key"))
+	require.NoError(t, err)
+	strategy := auth.NewJWTStrategy(iamKeys, nil)
+	strategy.SetFederation(newFederation(factory, idp.provider(true)))
+
+	g := gin.New()
+	g.POST("/login", strategy.LoginHandler)
+
+	login := func(body interface{}, header string) (int, string) {
+		data, _ := json.Marshal(body)
+		req := httptest.NewRequest(http.MethodPost, "/login", bytes.NewReader(data))
+		if header != "" {
+			req.Header.Set("Authorization", header)
+		}
+		w := httptest.NewRecorder()
+		g.ServeHTTP(w, req)
+
+		var rsp struct {
+			Token string `json:"token"`
+		}
+		_ = json.Unmarshal(w.Body.Bytes(), &rsp)
+		return w.Code, rsp.Token
+	}
+	subject := func(token string) string {
+		parsed, err := jwt.Parse(token, iamKeys.Keyfunc)
+		require.NoError(t, err)
+		return parsed.Claims.(jwt.MapClaims)["sub"].(string)
+	}
+
+	// 首次登录自动创建本地用户
+	status, token := login(map[string]string{"idToken": idp.idToken(t, nil)}, "")
+	require.Equal(t, http.StatusOK, status)
+	assert.Equal(t, "corp-alice", subject(token))
+
+	alice := factory.users.items["corp-alice"]
+	require.NotNil(t, alice)
+	assert.Equal(t, "Alice", alice.NickName)
+	assert.Equal(t, "alice@corp.example.com", alice.Email)
+	assert.Equal(t, 1, alice.IsAdmin)
+	assert.NotNil(t, alice.LoginedAt)
+
+	// 再次登录时同步上游的信息, 上游修改用户名后仍然关联到同一个本地用户
+	status, token = login(map[string]string{"idToken": idp.idToken(t, jwt.MapClaims{
+		"preferred_username": "alice2", "name": "Alice Liu", "groups": []string{"staff"},
+	})}, "")
+	require.Equal(t, http.StatusOK, status)
+	assert.Equal(t, "corp-alice", subject(token))
+	assert.Equal(t, "Alice Liu", factory.users.items["corp-alice"].NickName)
+	assert.Equal(t, 0, factory.users.items["corp-alice"].IsAdmin)
+	assert.Len(t, factory.identities.items, 1)
+
+	// 开启上游登录后用户名密码登录不受影响, 携带 Authorization 时忽略 body 中的 idToken
+	status, token = login(map[string]string{"idToken": idp.idToken(t, nil)},
+		"Basic "+base64.StdEncoding.EncodeToString([]byte("colin:Colin@2024")))
+	require.Equal(t, http.StatusOK, status)
+	assert.Equal(t, "colin", subject(token))
+
+	status, _ = login(map[string]string{"idToken": "not-a-jwt"}, "")
+	assert.Equal(t, http.StatusUnauthorized, status)
+}
+
+func TestAuthenticateRejects(t *testing.T) {
+	idp := newTestIdP(t)
+
+	otherKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+	forgedKey, _ := jwks.NewKey("corp-1", otherKey)
+	forgedKeys, _ := jwks.New("corp-1", forgedKey)
+	forged, err := forgedKeys.Sign(jwt.MapClaims{"iss": idp.server.URL, "aud": "iam", "sub": "10001",
+		"preferred_username": "alice", "email": "a@b.com", "groups": "staff", "exp": time.Now().Add(time.Hour).Unix()})
+	require.NoError(t, err)
+
+	tests := []struct {
+		name          string
+		token         string
+		autoProvision bool
+		want          int
+	}{
+		{"wrong audience", idp.idToken(t, jwt.MapClaims{"aud": "other-app"}), true, code.ErrTokenInvalid},
+		{"other azp", idp.idToken(t, jwt.MapClaims{"azp": "other-app"}), true, code.ErrTokenInvalid},
+		{"expired", idp.idToken(t, jwt.MapClaims{"exp": time.Now().Add(-time.Minute).Unix()}), true, code.ErrTokenInvalid},
+		{"missing exp", idp.idToken(t, jwt.MapClaims{"exp": nil}), true, code.ErrTokenInvalid},
+		{"missing iat", idp.idToken(t, jwt.MapClaims{"iat": nil}), true, code.ErrTokenInvalid},
+		{"unknown issuer", idp.idToken(t, jwt.MapClaims{"iss": "https://evil.example.com"}), true, code.ErrTokenInvalid},
+		{"forged signature", forged, true, code.ErrTokenInvalid},
+		{"missing sub", idp.idToken(t, jwt.MapClaims{"sub": nil}), true, code.ErrTokenInvalid},
+		{"not in allowed groups", idp.idToken(t, jwt.MapClaims{"groups": []string{"contractor"}}), true, code.ErrPermissionDenied},
+		{"auto provision disabled", idp.idToken(t, nil), false, code.ErrPermissionDenied},
+		// 不会关联同名的本地用户
+		{"local user exists", idp.idToken(t, jwt.MapClaims{"preferred_username": "admin"}), true, code.ErrUserAlreadyExist},
+		{"missing email", idp.idToken(t, jwt.MapClaims{"email": nil}), true, code.ErrValidation},
+		{"invalid username", idp.idToken(t, jwt.MapClaims{"preferred_username": "alice smith"}), true, code.ErrValidation},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			factory := newFakeFactory(t)
+			f := newFederation(factory, idp.provider(tt.autoProvision))
+
+			_, err := f.Authenticate(context.Background(), tt.token)
+			require.Error(t, err)
+			assert.Equal(t, tt.want, errors.ParseCoder(err).Code(), err.Error())
+			assert.Empty(t, factory.identities.items)
+		})
+	}
+}
+
+func TestAuthenticateDiscoveryIssuerMismatch(t *testing.T) {
+	idp := newTestIdP(t)
+
+	// discovery 中的 issuer 与配置不一致时不使用其中的 jwks_uri
+	provider := idp.provider(true)
+	provider.Issuer = idp.server.URL + "/"
+	f := newFederation(newFakeFactory(t), provider)
+
+	_, err := f.Authenticate(context.Background(), idp.idToken(t, jwt.MapClaims{"iss": provider.Issuer}))
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "does not match")
+}
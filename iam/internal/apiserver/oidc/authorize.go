@@ -0,0 +1,327 @@
+package oidc
+
+import (
+	"crypto/subtle"
+	"github.com/gin-gonic/gin"
+	"github.com/golang-jwt/jwt/v4"
+	"html/template"
+	"iam/internal/pkg/middleware/auth"
+	"iam/pkg/api/oauth"
+	"iam/pkg/util/idutil"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strings"
+	"time"
+)
+
+const (
+	sessionCookie = "iam_session"
+	csrfCookie    = "iam_csrf"
+	csrfField     = "csrf_token"
+	sessionType   = "session"
+
+	codeChallengeS256 = "S256"
+)
+
+// code_challenge 为 32 字节 sha256 的 base64url 编码
+var codeChallengeRegexp = regexp.MustCompile(`^[A-Za-z0-9_-]{43}$`)
+
+// authorizeRequest 授权请求参数, 登录表单提交时以隐藏字段原样带回
+type authorizeRequest struct {
+	ResponseType        string `form:"response_type"`
+	ClientID            string `form:"client_id"`
+	RedirectURI         string `form:"redirect_uri"`
+	Scope               string `form:"scope"`
+	State               string `form:"state"`
+	Nonce               string `form:"nonce"`
+	CodeChallenge       string `form:"code_challenge"`
+	CodeChallengeMethod string `form:"code_challenge_method"`
+	Prompt              string `form:"prompt"`
+}
+
+func (r *authorizeRequest) params() map[string]string {
+	return map[string]string{
+		"response_type":         r.ResponseType,
+		"client_id":             r.ClientID,
+		"redirect_uri":          r.RedirectURI,
+		"scope":                 r.Scope,
+		"state":                 r.State,
+		"nonce":                 r.Nonce,
+		"code_challenge":        r.CodeChallenge,
+		"code_challenge_method": r.CodeChallengeMethod,
+	}
+}
+
+// validate 校验可以通过重定向返回给客户端的错误
+func (r *authorizeRequest) validate(client *oauth.Client) *oauthError {
+	if r.ResponseType != "code" {
+		return newError(http.StatusBadRequest, errUnsupportedResponseType, "only response_type=code is supported")
+	}
+	if !client.HasGrantType(oauth.GrantAuthorizationCode) {
+		return newError(http.StatusBadRequest, errUnauthorizedClient, "client is not allowed to use authorization_code")
+	}
+
+	for _, scope := range strings.Fields(r.Scope) {
+		if !client.AllowScope(scope) {
+			return newError(http.StatusBadRequest, errInvalidScope, "scope %s is not allowed", scope)
+		}
+	}
+
+	if r.CodeChallenge == "" {
+		return newError(http.StatusBadRequest, errInvalidRequest, "code_challenge is required")
+	}
+	if r.CodeChallengeMethod != codeChallengeS256 {
+		return newError(http.StatusBadRequest, errInvalidRequest, "code_challenge_method must be S256")
+	}
+	if !codeChallengeRegexp.MatchString(r.CodeChallenge) {
+		return newError(http.StatusBadRequest, errInvalidRequest, "invalid code_challenge")
+	}
+
+	return nil
+}
+
+// Authorize 授权端点 GET /oauth2/authorize , 已登录时直接签发授权码, 否则显示登录页面
+func (p *Provider) Authorize(c *gin.Context) {
+	req, client, ok := p.parseAuthorize(c)
+	if !ok {
+		return
+	}
+
+	if username, authTime := p.session(c); username != "" && req.Prompt != "login" {
+		p.redirectCode(c, http.StatusFound, req, username, authTime)
+		return
+	}
+
+	if req.Prompt == "none" {
+		redirectError(c, req, newError(http.StatusBadRequest, errLoginRequired, "user is not logged in"))
+		return
+	}
+
+	p.renderLogin(c, http.StatusOK, req, client, "")
+}
+
+// Login 登录表单提交 POST /oauth2/authorize , 登录成功后写入会话并签发授权码
+func (p *Provider) Login(c *gin.Context) {
+	req, client, ok := p.parseAuthorize(c)
+	if !ok {
+		return
+	}
+
+	// 双重提交 cookie, 防止第三方页面伪造登录
+	cookie, err := c.Cookie(csrfCookie)
+	if err != nil || cookie == "" || subtle.ConstantTimeCompare([]byte(cookie), []byte(c.PostForm(csrfField))) != 1 {
+		p.renderLogin(c, http.StatusForbidden, req, client, "登录页面已失效, 请重试")
+		return
+	}
+
+	username := c.PostForm("username")
+	u, err := p.getUser(username)
+	if err != nil {
+		p.renderError(c, serverError(err, "get user failed"))
+		return
+	}
+	if u == nil || u.Compare(c.PostForm("password")) != nil {
+		p.renderLogin(c, http.StatusUnauthorized, req, client, "用户名或密码错误")
+		return
+	}
+
+	authTime := time.Now()
+	session, err := p.keys.Sign(jwt.MapClaims{
+		"iss": p.issuer,
+		"sub": u.Name,
+		"aud": p.issuer,
+		"typ": sessionType,
+		"jti": idutil.GetUUID36(""),
+		"iat": auth.NumericDate(authTime),
+		"exp": authTime.Add(p.opts.SessionTTL).Unix(),
+	})
+	if err != nil {
+		p.renderError(c, serverError(err, "sign session failed"))
+		return
+	}
+
+	p.setCookie(c, sessionCookie, session, int(p.opts.SessionTTL.Seconds()), http.SameSiteLaxMode)
+	p.setCookie(c, csrfCookie, "", -1, http.SameSiteStrictMode)
+
+	// POST 之后使用 303 让浏览器以 GET 访问回调地址
+	p.redirectCode(c, http.StatusSeeOther, req, u.Name, auth.NumericDate(authTime))
+}
+
+// parseAuthorize 解析授权请求, client_id 或 redirect_uri 无效时不能重定向, 直接显示错误页面
+func (p *Provider) parseAuthorize(c *gin.Context) (*authorizeRequest, *oauth.Client, bool) {
+	req := &authorizeRequest{}
+	if err := c.ShouldBind(req); err != nil {
+		p.renderError(c, newError(http.StatusBadRequest, errInvalidRequest, "%s", err.Error()))
+		return nil, nil, false
+	}
+
+	client, err := p.getClient(req.ClientID)
+	if err != nil {
+		p.renderError(c, serverError(err, "get client failed"))
+		return nil, nil, false
+	}
+	if client == nil {
+		p.renderError(c, newError(http.StatusBadRequest, errInvalidClient, "unknown client_id"))
+		return nil, nil, false
+	}
+	if !client.HasRedirectURI(req.RedirectURI) {
+		p.renderError(c, newError(http.StatusBadRequest, errInvalidRequest, "redirect_uri is not registered"))
+		return nil, nil, false
+	}
+
+	if oerr := req.validate(client); oerr != nil {
+		redirectError(c, req, oerr)
+		return nil, nil, false
+	}
+
+	return req, client, true
+}
+
+// session 从会话 cookie 中获取已登录的用户, 会话无效或已被吊销时返回空
+func (p *Provider) session(c *gin.Context) (string, float64) {
+	value, err := c.Cookie(sessionCookie)
+	if err != nil || value == "" {
+		return "", 0
+	}
+
+	token, err := jwt.Parse(value, p.keys.Keyfunc)
+	if err != nil || !token.Valid {
+		return "", 0
+	}
+
+	claims, _ := token.Claims.(jwt.MapClaims)
+	username, _ := claims["sub"].(string)
+	if claims["typ"] != sessionType || claims["iss"] != p.issuer || username == "" {
+		return "", 0
+	}
+
+	iat, _ := claims["iat"].(float64)
+	jti, _ := claims["jti"].(string)
+	if p.revocation.IsRevoked(jti, username, iat) {
+		return "", 0
+	}
+
+	return username, iat
+}
+
+// redirectCode 签发授权码并重定向到客户端
+func (p *Provider) redirectCode(c *gin.Context, status int, req *authorizeRequest, username string, authTime float64) {
+	code, err := p.saveGrant(codeKeyPrefix, &grant{
+		ClientID:      req.ClientID,
+		Username:      username,
+		Scope:         req.Scope,
+		AuthTime:      authTime,
+		RedirectURI:   req.RedirectURI,
+		Nonce:         req.Nonce,
+		CodeChallenge: req.CodeChallenge,
+	}, p.opts.CodeTTL)
+	if err != nil {
+		redirectError(c, req, serverError(err, "save authorization code failed"))
+		return
+	}
+
+	redirect(c, status, req, url.Values{"code": {code}})
+}
+
+func redirectError(c *gin.Context, req *authorizeRequest, err *oauthError) {
+	redirect(c, http.StatusFound, req, url.Values{"error": {err.Code}, "error_description": {err.Description}})
+}
+
+// redirect 在注册的回调地址上追加参数, 保留原有的 query
+func redirect(c *gin.Context, status int, req *authorizeRequest, values url.Values) {
+	u, _ := url.Parse(req.RedirectURI) // 与注册的地址一致, 注册时已校验
+	query := u.Query()
+	for key, vs := range values {
+		query[key] = vs
+	}
+	if req.State != "" {
+		query.Set("state", req.State)
+	}
+	u.RawQuery = query.Encode()
+
+	c.Redirect(status, u.String())
+}
+
+func (p *Provider) setCookie(c *gin.Context, name, value string, maxAge int, sameSite http.SameSite) {
+	http.SetCookie(c.Writer, &http.Cookie{
+		Name:     name,
+		Value:    value,
+		Path:     AuthorizePath,
+		MaxAge:   maxAge,
+		Secure:   strings.HasPrefix(p.issuer, "https://"),
+		HttpOnly: true,
+		SameSite: sameSite,
+	})
+}
+
+var pageTemplate = template.Must(template.New("page").Parse(`<!DOCTYPE html>
+<html>
+<head><meta charset="utf-8"><title>IAM 登录</title></head>
+<body>
+{{- if .Error}}
+<p class="error">{{.Error}}</p>
+{{- end}}
+{{- if .Client}}
+<form method="post" action="{{.Action}}">
+<p>{{.Client}} 请求使用您的 IAM 账号登录</p>
+{{- range $name, $value := .Params}}
+<input type="hidden" name="{{$name}}" value="{{$value}}">
+{{- end}}
+<input type="hidden" name="csrf_token" value="{{.CSRF}}">
+<label>用户名 <input type="text" name="username" value="{{.Username}}" autofocus></label>
+<label>密码 <input type="password" name="password"></label>
+<button type="submit">登录</button>
+</form>
+{{- end}}
+</body>
+</html>
+`))
+
+type page struct {
+	Action   string
+	Client   string
+	Params   map[string]string
+	CSRF     string
+	Username string
+	Error    string
+}
+
+// renderLogin 显示登录页面, 同时写入 csrf cookie
+func (p *Provider) renderLogin(c *gin.Context, status int, req *authorizeRequest, client *oauth.Client, msg string) {
+	csrf, err := c.Cookie(csrfCookie)
+	if err != nil || csrf == "" {
+		csrf = idutil.NewSecretKey()
+		p.setCookie(c, csrfCookie, csrf, int(p.opts.SessionTTL.Seconds()), http.SameSiteStrictMode)
+	}
+
+	name := client.Name
+	if client.Description != "" {
+		name = client.Description
+	}
+
+	p.render(c, status, page{
+		Action:   p.issuer + AuthorizePath,
+		Client:   name,
+		Params:   req.params(),
+		CSRF:     csrf,
+		Username: c.PostForm("username"),
+		Error:    msg,
+	})
+}
+
+func (p *Provider) renderError(c *gin.Context, err *oauthError) {
+	p.render(c, err.status, page{Error: err.Error()})
+}
+
+func (p *Provider) render(c *gin.Context, status int, data page) {
+	// 禁止嵌入到其他页面中, 防止点击劫持
+	c.Header("X-Frame-Options", "DENY")
+	c.Header("Content-Security-Policy", "frame-ancestors 'none'")
+	c.Header("Cache-Control", "no-store")
+	c.Header("Content-Type", "text/html; charset=utf-8")
+	c.Status(status)
+
+	_ = pageTemplate.Execute(c.Writer, data)
+	c.Abort()
+}
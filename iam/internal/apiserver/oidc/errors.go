@@ -0,0 +1,50 @@
+package oidc
+
+import (
+	"fmt"
+	"github.com/gin-gonic/gin"
+	"github.com/sirupsen/logrus"
+	"net/http"
+)
+
+// 错误码由 RFC 6749 和 OIDC 规范定义, 客户端按 error 字段处理, 所以不使用 code 包中的错误码
+const (
+	errInvalidRequest          = "invalid_request"
+	errInvalidClient           = "invalid_client"
+	errInvalidGrant            = "invalid_grant"
+	errInvalidScope            = "invalid_scope"
+	errInvalidToken            = "invalid_token"
+	errUnauthorizedClient      = "unauthorized_client"
+	errUnsupportedGrantType    = "unsupported_grant_type"
+	errUnsupportedResponseType = "unsupported_response_type"
+	errInsufficientScope       = "insufficient_scope"
+	errLoginRequired           = "login_required"
+	errServerError             = "server_error"
+)
+
+// oauthError RFC 6749 5.2 中的错误响应
+type oauthError struct {
+	Code        string `json:"error"`
+	Description string `json:"error_description,omitempty"`
+
+	status int
+}
+
+func (e *oauthError) Error() string {
+	return e.Code + ": " + e.Description
+}
+
+func newError(status int, code, format string, args ...interface{}) *oauthError {
+	return &oauthError{Code: code, Description: fmt.Sprintf(format, args...), status: status}
+}
+
+// serverError 记录内部错误, 不对外暴露详细信息
+func serverError(err error, msg string) *oauthError {
+	logrus.Errorf("oidc: %s: %v", msg, err)
+
+	return newError(http.StatusInternalServerError, errServerError, "%s", msg)
+}
+
+func writeError(c *gin.Context, err *oauthError) {
+	c.AbortWithStatusJSON(err.status, err)
+}
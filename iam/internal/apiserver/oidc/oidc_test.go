@@ -0,0 +1,494 @@
+package oidc
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"github.com/gin-gonic/gin"
+	"github.com/golang-jwt/jwt/v4"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/crypto/bcrypt"
+	"html"
+	"iam/internal/apiserver/store"
+	"iam/internal/pkg/code"
+	"iam/internal/pkg/middleware/auth"
+	"iam/internal/pkg/options"
+	metav1 "iam/pkg/api/meta/v1"
+	"iam/pkg/api/oauth"
+	"iam/pkg/api/user"
+	"iam/pkg/errors"
+	"iam/pkg/jwks"
+	"io"
+	"net/http"
+	"net/http/cookiejar"
+	"net/http/httptest"
+	"net/url"
+	"regexp"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+const (
+	webRedirect = "http://localhost:9000/callback"
+	verifier    = "dBjftJeZ4CVP-mB92K27uhbUJU1p1r_wW1gFWFOEjXk"
+)
+
+type fakeFactory struct {
+	store.Factory
+	users   fakeUsers
+	clients fakeClients
+}
+
+func (f *fakeFactory) User() store.UserStore      { return f.users }
+func (f *fakeFactory) Clients() store.ClientStore { return f.clients }
+
+type fakeUsers struct {
+	store.UserStore
+	items map[string]*user.User
+}
+
+func (s fakeUsers) GetUserByName(_ context.Context, username string) (*user.User, error) {
+	if u, ok := s.items[username]; ok {
+		return u, nil
+	}
+	return nil, errors.WithCode(code.ErrUserNotFound, "user %s not found", username)
+}
+
+type fakeClients struct {
+	store.ClientStore
+	items map[string]*oauth.Client
+}
+
+func (s fakeClients) GetByClientID(_ context.Context, clientID string) (*oauth.Client, error) {
+	if c, ok := s.items[clientID]; ok {
+		return c, nil
+	}
+	return nil, errors.WithCode(code.ErrClientNotFound, "client %s not found", clientID)
+}
+
+// fakeTokenStore 同时实现 TokenStore 和 auth.RevocationStore
+type fakeTokenStore struct {
+	mu     sync.Mutex
+	values map[string]string
+}
+
+func (s *fakeTokenStore) SetKey(key, value string, _ time.Duration) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.values[key] = value
+	return nil
+}
+
+func (s *fakeTokenStore) TakeKey(key string) (string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	value := s.values[key]
+	delete(s.values, key)
+	return value, nil
+}
+
+func (s *fakeTokenStore) GetMultiKey(keys []string) ([]string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	values := make([]string, 0, len(keys))
+	for _, key := range keys {
+		values = append(values, s.values[key])
+	}
+	return values, nil
+}
+
+type testEnv struct {
+	server     *httptest.Server
+	keys       *jwks.KeySet
+	revocation *auth.Revocation
+	browser    *http.Client
+}
+
+func newTestEnv(t *testing.T) *testEnv {
+	gin.SetMode(gin.TestMode)
+
+	ecKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	key, err := jwks.NewKey("test", ecKey)
+	require.NoError(t, err)
+	keys, err := jwks.New("test", key)
+	require.NoError(t, err)
+
+	password, err := bcrypt.GenerateFromPassword([]byte("Colin@2024"), bcrypt.MinCost)
+	require.NoError(t, err)
+
+	web := &oauth.Client{ObjectMeta: metav1.ObjectMeta{Name: "web"}, ClientID: "web-app", ClientSecret: "web-secret",
+		RedirectURIs: []string{webRedirect}, Description: "Wiki"}
+	web.Default()
+	require.NoError(t, web.HashSecret())
+
+	spa := &oauth.Client{ObjectMeta: metav1.ObjectMeta{Name: "spa"}, ClientID: "spa-app", Public: true,
+		RedirectURIs: []string{webRedirect}}
+	spa.Default()
+
+	job := &oauth.Client{ObjectMeta: metav1.ObjectMeta{Name: "job"}, ClientID: "job", ClientSecret: "job-secret",
+		GrantTypes: []string{oauth.GrantClientCredentials}, Scopes: []string{oauth.ScopeProfile}}
+	require.NoError(t, job.HashSecret())
+
+	factory := &fakeFactory{
+		users: fakeUsers{items: map[string]*user.User{
+			"colin": {ObjectMeta: metav1.ObjectMeta{Name: "colin"}, NickName: "Colin", Email: "colin@example.com", Password: string(password)},
+		}},
+		clients: fakeClients{items: map[string]*oauth.Client{"web-app": web, "spa-app": spa, "job": job}},
+	}
+
+	tokens := &fakeTokenStore{values: map[string]string{}}
+	revocation := auth.NewRevocation(tokens, false)
+
+	server := httptest.NewUnstartedServer(nil)
+	opts := options.NewOIDCOptions()
+	opts.Enable, opts.Issuer = true, "http://"+server.Listener.Addr().String()
+
+	g := gin.New()
+	NewProvider(opts, keys, factory, tokens, revocation).Install(g)
+	server.Config.Handler = g
+	server.Start()
+	t.Cleanup(server.Close)
+
+	jar, _ := cookiejar.New(nil)
+	browser := &http.Client{
+		Jar: jar,
+		// 回调地址属于客户端, 浏览器停在重定向处
+		CheckRedirect: func(*http.Request, []*http.Request) error { return http.ErrUseLastResponse },
+	}
+
+	return &testEnv{server: server, keys: keys, revocation: revocation, browser: browser}
+}
+
+func authorizeURL(issuer string, params url.Values) string {
+	sum := sha256.Sum256([]byte(verifier))
+	query := url.Values{
+		"response_type":         {"code"},
+		"client_id":             {"web-app"},
+		"redirect_uri":          {webRedirect},
+		"scope":                 {"openid profile email"},
+		"state":                 {"xyz"},
+		"nonce":                 {"n-0S6_WzA2Mj"},
+		"code_challenge":        {base64.RawURLEncoding.EncodeToString(sum[:])},
+		"code_challenge_method": {"S256"},
+	}
+	for key, values := range params {
+		query[key] = values
+	}
+
+	return issuer + AuthorizePath + "?" + query.Encode()
+}
+
+var hiddenInputRegexp = regexp.MustCompile(`<input type="hidden" name="([^"]+)" value="([^"]*)">`)
+
+// submitLogin 像浏览器一样提交登录页面中的表单
+func (e *testEnv) submitLogin(t *testing.T, page, username, password string) *http.Response {
+	form := url.Values{"username": {username}, "password": {password}}
+	for _, m := range hiddenInputRegexp.FindAllStringSubmatch(page, -1) {
+		form.Set(m[1], html.UnescapeString(m[2]))
+	}
+
+	rsp, err := e.browser.PostForm(e.server.URL+AuthorizePath, form)
+	require.NoError(t, err)
+	return rsp
+}
+
+func readBody(t *testing.T, rsp *http.Response) string {
+	defer rsp.Body.Close()
+
+	data, err := io.ReadAll(rsp.Body)
+	require.NoError(t, err)
+	return string(data)
+}
+
+// callback 解析重定向到客户端回调地址的参数
+func callback(t *testing.T, rsp *http.Response) url.Values {
+	_ = rsp.Body.Close()
+
+	location, err := url.Parse(rsp.Header.Get("Location"))
+	require.NoError(t, err)
+	require.Equal(t, webRedirect, location.Scheme+"://"+location.Host+location.Path)
+	return location.Query()
+}
+
+func (e *testEnv) token(t *testing.T, form url.Values, clientID, secret string) (int, map[string]interface{}) {
+	req, _ := http.NewRequest(http.MethodPost, e.server.URL+TokenPath, strings.NewReader(form.Encode()))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	if secret != "" {
+		req.SetBasicAuth(clientID, secret)
+	}
+
+	rsp, err := http.DefaultClient.Do(req)
+	require.NoError(t, err)
+
+	body := map[string]interface{}{}
+	require.NoError(t, json.Unmarshal([]byte(readBody(t, rsp)), &body))
+	return rsp.StatusCode, body
+}
+
+func (e *testEnv) userinfo(t *testing.T, accessToken string) (int, map[string]interface{}) {
+	req, _ := http.NewRequest(http.MethodGet, e.server.URL+UserinfoPath, nil)
+	req.Header.Set("Authorization", "Bearer "+accessToken)
+
+	rsp, err := http.DefaultClient.Do(req)
+	require.NoError(t, err)
+
+	body := map[string]interface{}{}
+	require.NoError(t, json.Unmarshal([]byte(readBody(t, rsp)), &body))
+	return rsp.StatusCode, body
+}
+
+// loginAuth 使用 apiserver 和 authz 的 Bearer 认证验证 token, 返回两者的状态码
+func (e *testEnv) loginAuth(t *testing.T, token string) (int, int) {
+	jwtStrategy := auth.NewJWTStrategy(e.keys, nil)
+	cacheStrategy := auth.NewCacheStrategy(func(kid string) (auth.Secret, error) {
+		return auth.Secret{}, auth.ErrMissingSecret
+	}, e.keys, nil)
+
+	g := gin.New()
+	g.GET("/apiserver", jwtStrategy.Auth(), func(c *gin.Context) { c.Status(http.StatusOK) })
+	g.GET("/authz", cacheStrategy.Auth(), func(c *gin.Context) { c.Status(http.StatusOK) })
+
+	statuses := make([]int, 0, 2)
+	for _, path := range []string{"/apiserver", "/authz"} {
+		req := httptest.NewRequest(http.MethodGet, path, nil)
+		req.Header.Set("Authorization", "Bearer "+token)
+		w := httptest.NewRecorder()
+		g.ServeHTTP(w, req)
+
+		statuses = append(statuses, w.Code)
+		if w.Code != http.StatusOK {
+			var rsp struct {
+				Code int `json:"code"`
+			}
+			require.NoError(t, json.Unmarshal(w.Body.Bytes(), &rsp))
+			assert.Equal(t, code.ErrTokenInvalid, rsp.Code, path)
+		}
+	}
+
+	return statuses[0], statuses[1]
+}
+
+func TestAuthorizationCodeFlow(t *testing.T) {
+	e := newTestEnv(t)
+	issuer := e.server.URL
+
+	// 客户端通过 discovery 获取端点
+	rsp, err := http.Get(issuer + DiscoveryPath)
+	require.NoError(t, err)
+	discovery := map[string]interface{}{}
+	require.NoError(t, json.Unmarshal([]byte(readBody(t, rsp)), &discovery))
+	assert.Equal(t, issuer, discovery["issuer"])
+	assert.Equal(t, issuer+TokenPath, discovery["token_endpoint"])
+	assert.Equal(t, []interface{}{"ES256"}, discovery["id_token_signing_alg_values_supported"])
+
+	// 未登录时显示登录页面, 密码错误时重新显示
+	rsp, err = e.browser.Get(authorizeURL(issuer, nil))
+	require.NoError(t, err)
+	require.Equal(t, http.StatusOK, rsp.StatusCode)
+	page := readBody(t, rsp)
+	assert.Contains(t, page, "Wiki")
+
+	rsp = e.submitLogin(t, page, "colin", "wrong")
+	assert.Equal(t, http.StatusUnauthorized, rsp.StatusCode)
+	page = readBody(t, rsp)
+
+	rsp = e.submitLogin(t, page, "colin", "Colin@2024")
+	require.Equal(t, http.StatusSeeOther, rsp.StatusCode)
+	params := callback(t, rsp)
+	assert.Equal(t, "xyz", params.Get("state"))
+	code := params.Get("code")
+	require.NotEmpty(t, code)
+
+	// 错误的 code_verifier 会使授权码失效
+	status, body := e.token(t, url.Values{"grant_type": {"authorization_code"}, "code": {code},
+		"redirect_uri": {webRedirect}, "code_verifier": {strings.Repeat("a", 43)}}, "web-app", "web-secret")
+	assert.Equal(t, http.StatusBadRequest, status)
+	assert.Equal(t, errInvalidGrant, body["error"])
+
+	// 已登录时直接签发新的授权码
+	rsp, err = e.browser.Get(authorizeURL(issuer, nil))
+	require.NoError(t, err)
+	require.Equal(t, http.StatusFound, rsp.StatusCode)
+	code = callback(t, rsp).Get("code")
+
+	exchange := url.Values{"grant_type": {"authorization_code"}, "code": {code},
+		"redirect_uri": {webRedirect}, "code_verifier": {verifier}}
+	status, _ = e.token(t, exchange, "web-app", "wrong-secret")
+	assert.Equal(t, http.StatusUnauthorized, status)
+
+	status, body = e.token(t, exchange, "web-app", "web-secret")
+	require.Equal(t, http.StatusOK, status, body)
+	assert.Equal(t, "Bearer", body["token_type"])
+	assert.Equal(t, "openid profile email", body["scope"])
+
+	// id token 使用 jwks 中的公钥验证
+	idToken, err := jwt.Parse(body["id_token"].(string), e.keys.Keyfunc)
+	require.NoError(t, err)
+	claims := idToken.Claims.(jwt.MapClaims)
+	assert.Equal(t, issuer, claims["iss"])
+	assert.Equal(t, "colin", claims["sub"])
+	assert.Equal(t, "web-app", claims["aud"])
+	assert.Equal(t, "n-0S6_WzA2Mj", claims["nonce"])
+	assert.Equal(t, "test", idToken.Header["kid"])
+
+	// 授权码只能使用一次
+	status, body2 := e.token(t, exchange, "web-app", "web-secret")
+	assert.Equal(t, http.StatusBadRequest, status)
+	assert.Equal(t, errInvalidGrant, body2["error"])
+
+	status, info := e.userinfo(t, body["access_token"].(string))
+	require.Equal(t, http.StatusOK, status)
+	assert.Equal(t, map[string]interface{}{"sub": "colin", "name": "colin", "preferred_username": "colin",
+		"nickname": "Colin", "email": "colin@example.com", "updated_at": info["updated_at"]}, info)
+
+	status, _ = e.userinfo(t, body["id_token"].(string))
+	assert.Equal(t, http.StatusUnauthorized, status)
+
+	// 与登录 token 使用同一组密钥, 但不能作为用户的登录凭证
+	serverURL, _ := url.Parse(issuer + AuthorizePath)
+	var session string
+	for _, cookie := range e.browser.Jar.Cookies(serverURL) {
+		if cookie.Name == sessionCookie {
+			session = cookie.Value
+		}
+	}
+	require.NotEmpty(t, session)
+	for name, token := range map[string]string{"access token": body["access_token"].(string),
+		"id token": body["id_token"].(string), "session": session} {
+		apiserverStatus, authzStatus := e.loginAuth(t, token)
+		assert.Equal(t, http.StatusUnauthorized, apiserverStatus, name)
+		assert.Equal(t, http.StatusUnauthorized, authzStatus, name)
+	}
+
+	// refresh token 轮换, 可以缩小 scope
+	refresh := url.Values{"grant_type": {"refresh_token"}, "refresh_token": {body["refresh_token"].(string)}, "scope": {"openid"}}
+	status, refreshed := e.token(t, refresh, "web-app", "web-secret")
+	require.Equal(t, http.StatusOK, status, refreshed)
+	assert.Equal(t, "openid", refreshed["scope"])
+	assert.NotEqual(t, body["refresh_token"], refreshed["refresh_token"])
+
+	status, info = e.userinfo(t, refreshed["access_token"].(string))
+	require.Equal(t, http.StatusOK, status)
+	assert.Equal(t, map[string]interface{}{"sub": "colin"}, info)
+
+	status, _ = e.token(t, refresh, "web-app", "web-secret")
+	assert.Equal(t, http.StatusBadRequest, status)
+
+	// 吊销用户的所有 token 后, 会话和 refresh token 一并失效
+	require.NoError(t, e.revocation.RevokeUser("colin", time.Now()))
+	status, body = e.token(t, url.Values{"grant_type": {"refresh_token"},
+		"refresh_token": {refreshed["refresh_token"].(string)}}, "web-app", "web-secret")
+	assert.Equal(t, http.StatusBadRequest, status)
+	assert.Equal(t, errInvalidGrant, body["error"])
+
+	rsp, err = e.browser.Get(authorizeURL(issuer, nil))
+	require.NoError(t, err)
+	assert.Equal(t, http.StatusOK, rsp.StatusCode)
+	_ = rsp.Body.Close()
+}
+
+func TestPublicClient(t *testing.T) {
+	e := newTestEnv(t)
+
+	rsp, err := e.browser.Get(authorizeURL(e.server.URL, url.Values{"client_id": {"spa-app"}}))
+	require.NoError(t, err)
+	rsp = e.submitLogin(t, readBody(t, rsp), "colin", "Colin@2024")
+	require.Equal(t, http.StatusSeeOther, rsp.StatusCode)
+	code := callback(t, rsp).Get("code")
+
+	// 公共客户端没有密钥, 由 PKCE 保证授权码不能被其他人使用
+	status, body := e.token(t, url.Values{"grant_type": {"authorization_code"}, "client_id": {"spa-app"},
+		"code": {code}, "redirect_uri": {webRedirect}, "code_verifier": {verifier}}, "", "")
+	require.Equal(t, http.StatusOK, status, body)
+	assert.NotEmpty(t, body["id_token"])
+
+	status, body = e.token(t, url.Values{"grant_type": {"client_credentials"}, "client_id": {"spa-app"}}, "", "")
+	assert.Equal(t, http.StatusBadRequest, status)
+	assert.Equal(t, errUnauthorizedClient, body["error"])
+}
+
+func TestClientCredentials(t *testing.T) {
+	e := newTestEnv(t)
+
+	status, body := e.token(t, url.Values{"grant_type": {"client_credentials"}, "scope": {"profile"}}, "job", "job-secret")
+	require.Equal(t, http.StatusOK, status, body)
+	assert.Empty(t, body["id_token"])
+	assert.Empty(t, body["refresh_token"])
+
+	token, err := jwt.Parse(body["access_token"].(string), e.keys.Keyfunc)
+	require.NoError(t, err)
+	assert.Equal(t, "client:job", token.Claims.(jwt.MapClaims)["sub"])
+	assert.Equal(t, accessTokenType, token.Claims.(jwt.MapClaims)["typ"])
+
+	// 不能以客户端 ID 作为用户名认证
+	apiserverStatus, authzStatus := e.loginAuth(t, body["access_token"].(string))
+	assert.Equal(t, http.StatusUnauthorized, apiserverStatus)
+	assert.Equal(t, http.StatusUnauthorized, authzStatus)
+
+	status, body = e.token(t, url.Values{"grant_type": {"client_credentials"}, "scope": {"openid"}}, "job", "job-secret")
+	assert.Equal(t, http.StatusBadRequest, status)
+	assert.Equal(t, errInvalidScope, body["error"])
+
+	status, body = e.token(t, url.Values{"grant_type": {"password"}}, "job", "job-secret")
+	assert.Equal(t, http.StatusBadRequest, status)
+	assert.Equal(t, errUnsupportedGrantType, body["error"])
+}
+
+func TestAuthorizeErrors(t *testing.T) {
+	e := newTestEnv(t)
+
+	// 回调地址未注册时不能重定向
+	rsp, err := e.browser.Get(authorizeURL(e.server.URL, url.Values{"redirect_uri": {"https://evil.example.com/cb"}}))
+	require.NoError(t, err)
+	assert.Equal(t, http.StatusBadRequest, rsp.StatusCode)
+	_ = rsp.Body.Close()
+
+	tests := []struct {
+		name   string
+		params url.Values
+		want   string
+	}{
+		{"missing pkce", url.Values{"code_challenge": {""}}, errInvalidRequest},
+		{"plain pkce", url.Values{"code_challenge_method": {"plain"}}, errInvalidRequest},
+		{"token response", url.Values{"response_type": {"token"}}, errUnsupportedResponseType},
+		{"unknown scope", url.Values{"scope": {"openid admin"}}, errInvalidScope},
+		{"prompt none", url.Values{"prompt": {"none"}}, errLoginRequired},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rsp, err := e.browser.Get(authorizeURL(e.server.URL, tt.params))
+			require.NoError(t, err)
+			require.Equal(t, http.StatusFound, rsp.StatusCode)
+
+			params := callback(t, rsp)
+			assert.Equal(t, tt.want, params.Get("error"))
+			assert.Equal(t, "xyz", params.Get("state"))
+		})
+	}
+
+	// 缺少 csrf cookie 的登录请求
+	form := url.Values{"username": {"colin"}, "password": {"Colin@2024"}, "csrf_token": {"forged"}}
+	for key, values := range mustParseQuery(authorizeURL(e.server.URL, nil)) {
+		form[key] = values
+	}
+	rsp, err = http.PostForm(e.server.URL+AuthorizePath, form)
+	require.NoError(t, err)
+	assert.Equal(t, http.StatusForbidden, rsp.StatusCode)
+	_ = rsp.Body.Close()
+}
+
+func mustParseQuery(raw string) url.Values {
+	u, _ := url.Parse(raw)
+	return u.Query()
+}
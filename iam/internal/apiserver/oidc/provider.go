@@ -0,0 +1,185 @@
+package oidc
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"github.com/gin-gonic/gin"
+	"iam/internal/apiserver/store"
+	"iam/internal/pkg/code"
+	"iam/internal/pkg/middleware/auth"
+	"iam/internal/pkg/options"
+	"iam/pkg/api/oauth"
+	"iam/pkg/api/user"
+	"iam/pkg/core"
+	"iam/pkg/errors"
+	"iam/pkg/jwks"
+	"iam/pkg/util/idutil"
+	"strings"
+	"time"
+)
+
+const (
+	AuthorizePath = "/oauth2/authorize"
+	TokenPath     = "/oauth2/token"
+	UserinfoPath  = "/oauth2/userinfo"
+	DiscoveryPath = "/.well-known/openid-configuration"
+	JWKSPath      = "/.well-known/jwks.json" // 由 apiserver 路由提供
+
+	codeKeyPrefix    = "iam.oidc.code."    // + sha256(code), 只能使用一次
+	refreshKeyPrefix = "iam.oidc.refresh." // + sha256(refresh token), 每次刷新后轮换
+
+	storeTimeout = 5 * time.Second
+)
+
+// TokenStore 保存授权码和 refresh token, 由 cache.RedisCluster 实现
+type TokenStore interface {
+	SetKey(key, value string, expire time.Duration) error
+	TakeKey(key string) (string, error) // 获取并删除, 不存在时返回空字符串
+}
+
+// Provider apiserver 作为 OIDC provider, 支持授权码(强制 PKCE), 客户端凭证和 refresh token.
+// 用户在登录页面认证后写入会话 cookie, 会话有效期内其他应用授权时不需要重新登录.
+// 令牌使用 jwt 的签发密钥签名, 客户端通过 jwks_uri 验证
+type Provider struct {
+	opts       *options.OIDCOptions
+	issuer     string
+	keys       *jwks.KeySet
+	factory    store.Factory
+	tokens     TokenStore
+	revocation *auth.Revocation // 用户被吊销后, 会话和 refresh token 一并失效
+}
+
+func NewProvider(opts *options.OIDCOptions, keys *jwks.KeySet, factory store.Factory, tokens TokenStore,
+	revocation *auth.Revocation) *Provider {
+	return &Provider{
+		opts:       opts,
+		issuer:     strings.TrimRight(opts.Issuer, "/"),
+		keys:       keys,
+		factory:    factory,
+		tokens:     tokens,
+		revocation: revocation,
+	}
+}
+
+// Install 注册 OIDC 相关路由
+func (p *Provider) Install(g *gin.Engine) {
+	g.GET(DiscoveryPath, p.Discovery)
+	g.GET(AuthorizePath, p.Authorize)
+	g.POST(AuthorizePath, p.Login)
+	g.POST(TokenPath, p.Token)
+	g.GET(UserinfoPath, p.Userinfo)
+	g.POST(UserinfoPath, p.Userinfo)
+}
+
+// Discovery 返回 provider 的元数据 GET /.well-known/openid-configuration
+func (p *Provider) Discovery(c *gin.Context) {
+	c.Header("Cache-Control", "public, max-age=300")
+	core.WriteResponse(c, nil, gin.H{
+		"issuer":                                p.issuer,
+		"authorization_endpoint":                p.issuer + AuthorizePath,
+		"token_endpoint":                        p.issuer + TokenPath,
+		"userinfo_endpoint":                     p.issuer + UserinfoPath,
+		"jwks_uri":                              p.issuer + JWKSPath,
+		"response_types_supported":              []string{"code"},
+		"grant_types_supported":                 []string{oauth.GrantAuthorizationCode, oauth.GrantRefreshToken, oauth.GrantClientCredentials},
+		"subject_types_supported":               []string{"public"},
+		"id_token_signing_alg_values_supported": []string{p.keys.SigningKey().Method.Alg()},
+		"scopes_supported":                      []string{oauth.ScopeOpenID, oauth.ScopeProfile, oauth.ScopeEmail},
+		"token_endpoint_auth_methods_supported": []string{"client_secret_basic", "client_secret_post", "none"},
+		"code_challenge_methods_supported":      []string{codeChallengeS256},
+		"claims_supported": []string{"iss", "sub", "aud", "exp", "iat", "auth_time", "nonce",
+			"name", "nickname", "preferred_username", "email"},
+	})
+}
+
+// grant 授权码和 refresh token 对应的授权信息, 保存到 TokenStore 中
+type grant struct {
+	ClientID      string  `json:"client_id"`
+	Username      string  `json:"username"`
+	Scope         string  `json:"scope"`
+	AuthTime      float64 `json:"auth_time"` // 用户登录时间, 精确到毫秒, 用于判断是否已被吊销
+	RedirectURI   string  `json:"redirect_uri,omitempty"`
+	Nonce         string  `json:"nonce,omitempty"`
+	CodeChallenge string  `json:"code_challenge,omitempty"`
+}
+
+// saveGrant 生成随机的令牌并保存授权信息, redis 中只保存令牌的 hash
+func (p *Provider) saveGrant(prefix string, g *grant, ttl time.Duration) (string, error) {
+	data, err := json.Marshal(g)
+	if err != nil {
+		return "", err
+	}
+
+	token := idutil.NewSecretKey() + idutil.NewSecretKey()
+	if err = p.tokens.SetKey(prefix+hashToken(token), string(data), ttl); err != nil {
+		return "", err
+	}
+
+	return token, nil
+}
+
+// takeGrant 取出并删除令牌对应的授权信息, 不存在或已使用时返回 nil
+func (p *Provider) takeGrant(prefix, token string) (*grant, error) {
+	if token == "" {
+		return nil, nil
+	}
+
+	data, err := p.tokens.TakeKey(prefix + hashToken(token))
+	if err != nil || data == "" {
+		return nil, err
+	}
+
+	g := &grant{}
+	if err = json.Unmarshal([]byte(data), g); err != nil {
+		return nil, err
+	}
+
+	return g, nil
+}
+
+func hashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+// getClient 客户端不存在时返回 nil
+func (p *Provider) getClient(clientID string) (*oauth.Client, error) {
+	if clientID == "" {
+		return nil, nil
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), storeTimeout)
+	defer cancel()
+
+	client, err := p.factory.Clients().GetByClientID(ctx, clientID)
+	if errors.IsCode(err, code.ErrClientNotFound) {
+		return nil, nil
+	}
+
+	return client, err
+}
+
+// getUser 用户不存在时返回 nil
+func (p *Provider) getUser(username string) (*user.User, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), storeTimeout)
+	defer cancel()
+
+	u, err := p.factory.User().GetUserByName(ctx, username)
+	if errors.IsCode(err, code.ErrUserNotFound) {
+		return nil, nil
+	}
+
+	return u, err
+}
+
+func hasScope(scope, want string) bool {
+	for _, s := range strings.Fields(scope) {
+		if s == want {
+			return true
+		}
+	}
+
+	return false
+}
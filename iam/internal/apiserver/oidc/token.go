@@ -0,0 +1,275 @@
+package oidc
+
+import (
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"github.com/gin-gonic/gin"
+	"github.com/golang-jwt/jwt/v4"
+	"iam/pkg/api/oauth"
+	"iam/pkg/api/user"
+	"iam/pkg/util/idutil"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// 与 apiserver 登录签发的 token 使用同一组密钥, 通过 typ 区分, 带 typ 的 token 不能用于登录认证
+const (
+	accessTokenType = "access_token"
+	idTokenType     = "id_token"
+
+	// client_credentials 签发的 token 以客户端自身为主体, 用户名不能包含 ':', 不会与用户混淆
+	clientSubjectPrefix = "client:"
+)
+
+// tokenResponse RFC 6749 5.1 中的令牌响应
+type tokenResponse struct {
+	AccessToken  string `json:"access_token"`
+	TokenType    string `json:"token_type"`
+	ExpiresIn    int64  `json:"expires_in"`
+	RefreshToken string `json:"refresh_token,omitempty"`
+	IDToken      string `json:"id_token,omitempty"`
+	Scope        string `json:"scope,omitempty"`
+}
+
+// Token 令牌端点 POST /oauth2/token
+func (p *Provider) Token(c *gin.Context) {
+	c.Header("Cache-Control", "no-store")
+	c.Header("Pragma", "no-cache")
+
+	client, oerr := p.authenticateClient(c)
+	if oerr != nil {
+		writeError(c, oerr)
+		return
+	}
+
+	var rsp *tokenResponse
+	switch grantType := c.PostForm("grant_type"); grantType {
+	case oauth.GrantAuthorizationCode:
+		rsp, oerr = p.authorizationCodeGrant(c, client)
+	case oauth.GrantRefreshToken:
+		rsp, oerr = p.refreshTokenGrant(c, client)
+	case oauth.GrantClientCredentials:
+		rsp, oerr = p.clientCredentialsGrant(c, client)
+	case "":
+		oerr = newError(http.StatusBadRequest, errInvalidRequest, "grant_type is required")
+	default:
+		oerr = newError(http.StatusBadRequest, errUnsupportedGrantType, "grant_type %s is not supported", grantType)
+	}
+
+	if oerr != nil {
+		writeError(c, oerr)
+		return
+	}
+
+	c.JSON(http.StatusOK, rsp)
+}
+
+// authenticateClient 支持 client_secret_basic, client_secret_post, 公共客户端只需要 client_id
+func (p *Provider) authenticateClient(c *gin.Context) (*oauth.Client, *oauthError) {
+	clientID, secret, basic := c.Request.BasicAuth()
+	if basic {
+		// RFC 6749 2.3.1 中 client_id 和 client_secret 需要先进行 url 编码
+		clientID, _ = url.QueryUnescape(clientID)
+		secret, _ = url.QueryUnescape(secret)
+		if id := c.PostForm("client_id"); id != "" && id != clientID {
+			return nil, newError(http.StatusBadRequest, errInvalidRequest, "client_id does not match the authorization header")
+		}
+	} else {
+		clientID, secret = c.PostForm("client_id"), c.PostForm("client_secret")
+	}
+
+	invalid := func() *oauthError {
+		if basic {
+			c.Header("WWW-Authenticate", `Basic realm="iam"`)
+		}
+		return newError(http.StatusUnauthorized, errInvalidClient, "client authentication failed")
+	}
+
+	client, err := p.getClient(clientID)
+	if err != nil {
+		return nil, serverError(err, "get client failed")
+	}
+	if client == nil {
+		return nil, invalid()
+	}
+	if !client.Public && client.CompareSecret(secret) != nil {
+		return nil, invalid()
+	}
+
+	return client, nil
+}
+
+// authorizationCodeGrant 使用授权码换取令牌, 授权码只能使用一次, 同时校验回调地址和 PKCE
+func (p *Provider) authorizationCodeGrant(c *gin.Context, client *oauth.Client) (*tokenResponse, *oauthError) {
+	if !client.HasGrantType(oauth.GrantAuthorizationCode) {
+		return nil, newError(http.StatusBadRequest, errUnauthorizedClient, "client is not allowed to use authorization_code")
+	}
+
+	g, err := p.takeGrant(codeKeyPrefix, c.PostForm("code"))
+	if err != nil {
+		return nil, serverError(err, "get authorization code failed")
+	}
+	if g == nil || g.ClientID != client.ClientID {
+		return nil, newError(http.StatusBadRequest, errInvalidGrant, "authorization code is invalid or expired")
+	}
+	if g.RedirectURI != c.PostForm("redirect_uri") {
+		return nil, newError(http.StatusBadRequest, errInvalidGrant, "redirect_uri does not match")
+	}
+	if !verifyCodeChallenge(g.CodeChallenge, c.PostForm("code_verifier")) {
+		return nil, newError(http.StatusBadRequest, errInvalidGrant, "code_verifier does not match")
+	}
+
+	u, oerr := p.grantUser(g)
+	if oerr != nil {
+		return nil, oerr
+	}
+
+	return p.issueTokens(client, g, u, g.Scope)
+}
+
+// refreshTokenGrant 使用 refresh token 换取令牌, 旧的 refresh token 随即失效.
+// 可以通过 scope 缩小本次签发令牌的范围, 新的 refresh token 保留原有范围
+func (p *Provider) refreshTokenGrant(c *gin.Context, client *oauth.Client) (*tokenResponse, *oauthError) {
+	if !client.HasGrantType(oauth.GrantRefreshToken) {
+		return nil, newError(http.StatusBadRequest, errUnauthorizedClient, "client is not allowed to use refresh_token")
+	}
+
+	g, err := p.takeGrant(refreshKeyPrefix, c.PostForm("refresh_token"))
+	if err != nil {
+		return nil, serverError(err, "get refresh token failed")
+	}
+	if g == nil || g.ClientID != client.ClientID {
+		return nil, newError(http.StatusBadRequest, errInvalidGrant, "refresh token is invalid or expired")
+	}
+
+	scope := g.Scope
+	if requested, ok := c.GetPostForm("scope"); ok {
+		for _, s := range strings.Fields(requested) {
+			if !hasScope(g.Scope, s) {
+				return nil, newError(http.StatusBadRequest, errInvalidScope, "scope %s exceeds the original grant", s)
+			}
+		}
+		scope = requested
+	}
+
+	u, oerr := p.grantUser(g)
+	if oerr != nil {
+		return nil, oerr
+	}
+
+	return p.issueTokens(client, g, u, scope)
+}
+
+// clientCredentialsGrant 客户端以自身身份获取 access token, 不签发 id token 和 refresh token
+func (p *Provider) clientCredentialsGrant(c *gin.Context, client *oauth.Client) (*tokenResponse, *oauthError) {
+	if client.Public || !client.HasGrantType(oauth.GrantClientCredentials) {
+		return nil, newError(http.StatusBadRequest, errUnauthorizedClient, "client is not allowed to use client_credentials")
+	}
+
+	scope := c.PostForm("scope")
+	for _, s := range strings.Fields(scope) {
+		if s == oauth.ScopeOpenID || !client.AllowScope(s) {
+			return nil, newError(http.StatusBadRequest, errInvalidScope, "scope %s is not allowed", s)
+		}
+	}
+
+	now := time.Now()
+	accessToken, err := p.keys.Sign(p.accessClaims(client, clientSubjectPrefix+client.ClientID, scope, now))
+	if err != nil {
+		return nil, serverError(err, "sign access token failed")
+	}
+
+	return &tokenResponse{
+		AccessToken: accessToken,
+		TokenType:   "Bearer",
+		ExpiresIn:   int64(p.opts.AccessTokenTTL.Seconds()),
+		Scope:       scope,
+	}, nil
+}
+
+// grantUser 获取授权对应的用户, 用户被禁用, 删除或在登录后吊销了所有 token 时授权失效
+func (p *Provider) grantUser(g *grant) (*user.User, *oauthError) {
+	u, err := p.getUser(g.Username)
+	if err != nil {
+		return nil, serverError(err, "get user failed")
+	}
+	if u == nil || p.revocation.IsRevoked("", g.Username, g.AuthTime) {
+		return nil, newError(http.StatusBadRequest, errInvalidGrant, "grant has been revoked")
+	}
+
+	return u, nil
+}
+
+// issueTokens 为用户签发 access token, 包含 openid 时签发 id token, 允许刷新时签发新的 refresh token
+func (p *Provider) issueTokens(client *oauth.Client, g *grant, u *user.User, scope string) (*tokenResponse, *oauthError) {
+	now := time.Now()
+
+	accessToken, err := p.keys.Sign(p.accessClaims(client, u.Name, scope, now))
+	if err != nil {
+		return nil, serverError(err, "sign access token failed")
+	}
+
+	rsp := &tokenResponse{
+		AccessToken: accessToken,
+		TokenType:   "Bearer",
+		ExpiresIn:   int64(p.opts.AccessTokenTTL.Seconds()),
+		Scope:       scope,
+	}
+
+	if hasScope(scope, oauth.ScopeOpenID) {
+		claims := userClaims(u, scope)
+		claims["iss"] = p.issuer
+		claims["aud"] = client.ClientID
+		claims["azp"] = client.ClientID
+		claims["typ"] = idTokenType
+		claims["iat"] = now.Unix()
+		claims["exp"] = now.Add(p.opts.AccessTokenTTL).Unix()
+		claims["auth_time"] = int64(g.AuthTime)
+		if g.Nonce != "" {
+			claims["nonce"] = g.Nonce
+		}
+
+		if rsp.IDToken, err = p.keys.Sign(claims); err != nil {
+			return nil, serverError(err, "sign id token failed")
+		}
+	}
+
+	if client.HasGrantType(oauth.GrantRefreshToken) {
+		refresh := &grant{ClientID: client.ClientID, Username: u.Name, Scope: g.Scope, AuthTime: g.AuthTime}
+		if rsp.RefreshToken, err = p.saveGrant(refreshKeyPrefix, refresh, p.opts.RefreshTokenTTL); err != nil {
+			return nil, serverError(err, "save refresh token failed")
+		}
+	}
+
+	return rsp, nil
+}
+
+// accessClaims access token 的负载, 只能用于 userinfo, 带有 typ, aud 和 client_id, apiserver 和 authz 的认证会拒绝
+func (p *Provider) accessClaims(client *oauth.Client, subject, scope string, now time.Time) jwt.MapClaims {
+	return jwt.MapClaims{
+		"iss":       p.issuer,
+		"sub":       subject,
+		"aud":       client.ClientID,
+		"client_id": client.ClientID,
+		"typ":       accessTokenType,
+		"scope":     scope,
+		"jti":       idutil.GetUUID36(""),
+		"iat":       now.Unix(),
+		"exp":       now.Add(p.opts.AccessTokenTTL).Unix(),
+	}
+}
+
+// verifyCodeChallenge RFC 7636 S256: BASE64URL(SHA256(code_verifier)) == code_challenge
+func verifyCodeChallenge(challenge, verifier string) bool {
+	if len(verifier) < 43 || len(verifier) > 128 {
+		return false
+	}
+
+	sum := sha256.Sum256([]byte(verifier))
+	computed := base64.RawURLEncoding.EncodeToString(sum[:])
+
+	return subtle.ConstantTimeCompare([]byte(computed), []byte(challenge)) == 1
+}
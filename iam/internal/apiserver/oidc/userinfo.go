@@ -0,0 +1,87 @@
+package oidc
+
+import (
+	"fmt"
+	"github.com/gin-gonic/gin"
+	"github.com/golang-jwt/jwt/v4"
+	"iam/pkg/api/oauth"
+	"iam/pkg/api/user"
+	"net/http"
+	"strings"
+)
+
+// Userinfo 返回 access token 对应用户的信息 GET/POST /oauth2/userinfo , 字段由 scope 决定
+func (p *Provider) Userinfo(c *gin.Context) {
+	claims, oerr := p.parseAccessToken(c.GetHeader("Authorization"))
+	if oerr != nil {
+		c.Header("WWW-Authenticate", fmt.Sprintf(`Bearer realm="iam", error=%q, error_description=%q`, oerr.Code, oerr.Description))
+		writeError(c, oerr)
+		return
+	}
+
+	scope, _ := claims["scope"].(string)
+	username, _ := claims["sub"].(string)
+
+	u, err := p.getUser(username)
+	if err != nil {
+		writeError(c, serverError(err, "get user failed"))
+		return
+	}
+	if u == nil {
+		c.Header("WWW-Authenticate", `Bearer realm="iam", error="invalid_token"`)
+		writeError(c, newError(http.StatusUnauthorized, errInvalidToken, "user does not exist"))
+		return
+	}
+
+	c.Header("Cache-Control", "no-store")
+	c.JSON(http.StatusOK, userClaims(u, scope))
+}
+
+// parseAccessToken 验证本 provider 签发的, 包含 openid 的 access token
+func (p *Provider) parseAccessToken(header string) (jwt.MapClaims, *oauthError) {
+	raw := strings.TrimSpace(header)
+	if len(raw) < 7 || !strings.EqualFold(raw[:7], "Bearer ") {
+		return nil, newError(http.StatusUnauthorized, errInvalidToken, "bearer token is required")
+	}
+
+	token, err := jwt.Parse(strings.TrimSpace(raw[7:]), p.keys.Keyfunc)
+	if err != nil || !token.Valid {
+		return nil, newError(http.StatusUnauthorized, errInvalidToken, "token is invalid or expired")
+	}
+
+	claims, _ := token.Claims.(jwt.MapClaims)
+	if claims["iss"] != p.issuer || claims["typ"] != accessTokenType || claims["client_id"] == nil {
+		return nil, newError(http.StatusUnauthorized, errInvalidToken, "token is not an access token")
+	}
+
+	username, _ := claims["sub"].(string)
+	jti, _ := claims["jti"].(string)
+	iat, _ := claims["iat"].(float64)
+	if p.revocation.IsRevoked(jti, username, iat) {
+		return nil, newError(http.StatusUnauthorized, errInvalidToken, "token has been revoked")
+	}
+
+	scope, _ := claims["scope"].(string)
+	if !hasScope(scope, oauth.ScopeOpenID) {
+		return nil, newError(http.StatusForbidden, errInsufficientScope, "openid scope is required")
+	}
+
+	return claims, nil
+}
+
+// userClaims 根据 scope 返回用户信息, 同时用于 id token
+func userClaims(u *user.User, scope string) jwt.MapClaims {
+	claims := jwt.MapClaims{"sub": u.Name}
+
+	if hasScope(scope, oauth.ScopeProfile) {
+		claims["name"] = u.Name
+		claims["preferred_username"] = u.Name
+		claims["nickname"] = u.NickName
+		claims["updated_at"] = u.UpdatedAt.Unix()
+	}
+	if hasScope(scope, oauth.ScopeEmail) {
+		claims["email"] = u.Email
+	}
+
+	return claims
+}
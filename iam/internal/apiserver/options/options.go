@@ -21,6 +21,9 @@ type Options struct {
 	Mysql *options.MysqlOptions           `json:"mysql" mapstructure:"mysql"`
 	Redis *options.RedisOptions           `json:"redis" mapstructure:"redis"`
 	Jwt   *options.JwtOptions             `json:"jwt" mapstructure:"jwt"`
+	OIDC  *options.OIDCOptions            `json:"oidc" mapstructure:"oidc"` // 单点登录
+	// 使用上游 IdP 登录
+	Federation *options.FederationOptions `json:"federation" mapstructure:"federation"`
 	// 服务相关配置
 	ServerRun *options.ServerRunOptions `json:"server" mapstructure:"server"`   // mode healthz middleware  apply 进行构建到pkg.config中
 	Feature   *options.FeatureOptions   `json:"feature" mapstructure:"feature"` // pprof metrics apply 进行构建到pkg.config中
@@ -34,11 +37,14 @@ func NewOptions() *Options {
 		Https:     options.NewSecureServing(),
 		Grpc:      options.NewGrpcOptions(),
 		Jwt:       options.NewJwtOptions(),
+		OIDC:      options.NewOIDCOptions(),
 		Mysql:     options.NewMysqlOptions(),
 		Redis:     options.NewRedisOptions(), // 更新或者其他操作策略，进行更改
 		ServerRun: options.NewServerRunOptions(),
 		Feature:   options.NewFeatureOptions(),
 		Log:       options.NewLogOption(),
+
+		Federation: options.NewFederationOptions(),
 	}
 	return newOp
 }
@@ -52,6 +58,8 @@ func (ops *Options) Flags() (fss pkg.NamedFlagSets) {
 	ops.Mysql.AddFlags(fss.FlagSet("mysql"))
 	ops.Redis.AddFlags(fss.FlagSet("redis"))
 	ops.Jwt.AddFlags(fss.FlagSet("jwt"))
+	ops.OIDC.AddFlags(fss.FlagSet("oidc"))
+	ops.Federation.AddFlags(fss.FlagSet("federation"))
 	ops.ServerRun.AddFlags(fss.FlagSet("server"))
 	ops.Feature.AddFlags(fss.FlagSet("feature"))
 	ops.Log.AddFlags(fss.FlagSet("logger"))
@@ -69,6 +77,8 @@ func (ops *Options) Validate() []error {
 	errs = append(errs, ops.Mysql.Validate()...)
 	errs = append(errs, ops.Redis.Validate()...)
 	errs = append(errs, ops.Jwt.Validate()...)
+	errs = append(errs, ops.OIDC.Validate()...)
+	errs = append(errs, ops.Federation.Validate()...)
 	errs = append(errs, ops.ServerRun.Validate()...)
 	errs = append(errs, ops.Feature.Validate()...)
 	errs = append(errs, ops.Log.Validate()...)
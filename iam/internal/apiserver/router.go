@@ -1,41 +1,108 @@
 package apiserver
 
 import (
-	"fmt"
 	"github.com/gin-gonic/gin"
+	clientv1 "iam/internal/apiserver/controller/v1/client"
+	policyv1 "iam/internal/apiserver/controller/v1/policy"
+	secretv1 "iam/internal/apiserver/controller/v1/secret"
 	userv1 "iam/internal/apiserver/controller/v1/user"
+	"iam/internal/apiserver/oidc"
 	"iam/internal/apiserver/store"
+	"iam/internal/pkg/code"
+	"iam/internal/pkg/middleware"
 	"iam/internal/pkg/middleware/auth"
 	"iam/pkg/core"
-	"net/http"
+	"iam/pkg/errors"
+	"iam/pkg/jwks"
 )
 
-func initRouter(engine *gin.Engine) {
-	installController(engine)
+func initRouter(engine *gin.Engine, keys *jwks.KeySet, revocation *auth.Revocation, provider *oidc.Provider,
+	federation auth.FederatedAuthenticator) {
+	installController(engine, keys, revocation, provider, federation)
 }
 
-func installController(g *gin.Engine) *gin.Engine {
+// provider 为空时不开启单点登录, federation 为空时不接受上游 IdP 登录
+func installController(g *gin.Engine, keys *jwks.KeySet, revocation *auth.Revocation, provider *oidc.Provider,
+	federation auth.FederatedAuthenticator) *gin.Engine {
 
-	strategy := auth.NewJWTStrategy(auth.NewGinGwt())
+	strategy := auth.NewJWTStrategy(keys, revocation)
+	if federation != nil {
+		strategy.SetFederation(federation)
+	}
 
-	g.POST("/login", strategy.LoginHandler)     // 登录
-	g.POST("/logout", strategy.LogoutHandler)   // 登出
+	g.POST("/login", strategy.LoginHandler)     // 登录, 支持用户名密码或上游 IdP 的 id token
+	g.POST("/logout", strategy.LogoutHandler)   // 登出, 吊销当前 token
 	g.POST("/refresh", strategy.RefreshHandler) // 刷新
 
-	auto := newAuto()
+	// 公开验证 token 的公钥, 供其他服务按 kid 验证 apiserver 签发的 token
+	g.GET(oidc.JWKSPath, func(c *gin.Context) {
+		c.Header("Cache-Control", "public, max-age=300")
+		core.WriteResponse(c, nil, keys.JWKS())
+	})
+
+	if provider != nil {
+		provider.Install(g)
+	}
+
+	auto := newAuto(keys, revocation)
 	// 若无以下接口
 	g.NoRoute(auto.Auth(), func(c *gin.Context) {
-		core.WriteResponse(c, http.StatusBadRequest, fmt.Errorf("auth failed"), nil) // todo err 构建
+		core.WriteResponse(c, errors.WithCode(code.ErrPageNotFound, "page not found."), nil)
 	})
 
 	// 获取mysql的信息
 	storeIns := store.GetFactory()
 
-	v1 := g.Group("/v1")
+	// 变更后通知 authz 重新加载缓存
+	v1 := g.Group("/v1", middleware.Publish())
 	{
 		user := v1.Group("/user") // auto.Auth()
-		userCtl := userv1.NewUserCtl(storeIns)
+		userCtl := userv1.NewUserCtl(storeIns, revocation)
 		user.POST("/create", userCtl.Create) // 创建用户 -->
+
+		users := v1.Group("/users", auto.Auth())
+		{
+			users.GET("", userCtl.List)                                 // 分页获取用户
+			users.GET("/:name", userCtl.Get)                            // 获取用户信息
+			users.PUT("/:name", userCtl.Update)                         // 更新用户信息
+			users.DELETE("/:name", userCtl.Delete)                      // 删除用户
+			users.PUT("/:name/change-password", userCtl.ChangePassword) // 修改密码
+			users.POST("/:name/revoke-tokens", userCtl.RevokeTokens)    // 吊销用户的所有 token
+		}
+
+		// 密钥, 仅能操作当前登录用户的密钥
+		secrets := v1.Group("/secrets", auto.Auth())
+		{
+			secretCtl := secretv1.NewSecretCtl(storeIns)
+			secrets.POST("", secretCtl.Create)
+			secrets.GET("", secretCtl.List)
+			secrets.GET("/:name", secretCtl.Get)
+			secrets.PUT("/:name", secretCtl.Update)
+			secrets.DELETE("/:name", secretCtl.Delete)
+		}
+
+		// 策略, 仅能操作当前登录用户的策略
+		policies := v1.Group("/policies", auto.Auth())
+		{
+			policyCtl := policyv1.NewPolicyCtl(storeIns)
+			policies.POST("", policyCtl.Create)
+			policies.DELETE("", policyCtl.DeleteCollection)
+			policies.GET("", policyCtl.List)
+			policies.GET("/:name", policyCtl.Get)
+			policies.PUT("/:name", policyCtl.Update)
+			policies.DELETE("/:name", policyCtl.Delete)
+		}
+
+		// OIDC 客户端, 仅能操作当前登录用户注册的客户端
+		clients := v1.Group("/clients", auto.Auth())
+		{
+			clientCtl := clientv1.NewClientCtl(storeIns)
+			clients.POST("", clientCtl.Create)
+			clients.GET("", clientCtl.List)
+			clients.GET("/:name", clientCtl.Get)
+			clients.PUT("/:name", clientCtl.Update)
+			clients.DELETE("/:name", clientCtl.Delete)
+		}
 	}
 
 	return g
@@ -3,11 +3,18 @@ package apiserver
 import (
 	"context"
 	"fmt"
+	"github.com/golang-jwt/jwt/v4"
 	"github.com/sirupsen/logrus"
+	"google.golang.org/grpc/reflection"
 	"iam/internal/apiserver/config"
+	cachev1 "iam/internal/apiserver/controller/v1/cache"
+	"iam/internal/apiserver/federation"
+	"iam/internal/apiserver/oidc"
 	"iam/internal/apiserver/store"
 	"iam/internal/apiserver/store/mysql"
+	"iam/internal/pkg/middleware/auth"
 	"iam/internal/pkg/options"
+	pb "iam/internal/pkg/proto/apiserver/v1"
 	genericserver "iam/internal/pkg/server"
 	"iam/pkg/cache"
 	"iam/pkg/shutdown"
@@ -24,6 +31,8 @@ type apiServer struct {
 	mysql         *options.MysqlOptions // 初始化mysql所需
 	redis         *options.RedisOptions
 	jwt           *options.JwtOptions
+	oidc          *options.OIDCOptions
+	federation    *options.FederationOptions
 	GrpcServer    *genericserver.GrpcAPIServer
 	GenericServer *genericserver.GenericAPIServer
 	serverRun     *options.ServerRunOptions // mode healthz middleware  apply 进行构建到pkg.config中
@@ -92,8 +101,11 @@ func newApiServer(cfg *config.Config) (*apiServer, error) {
 		mysql:     cfg.Mysql,
 		redis:     cfg.Redis,
 		jwt:       cfg.Jwt,
+		oidc:      cfg.OIDC,
 		serverRun: cfg.ServerRun,
 		feature:   cfg.Feature,
+
+		federation: cfg.Federation,
 	}
 
 	// 应用 generic server 所需配置参数
@@ -124,8 +136,8 @@ func newApiServer(cfg *config.Config) (*apiServer, error) {
 func (server *apiServer) PrepareServer() *perparesApiServer {
 
 	// 初始化 redis mysql
-	// server.initRedisStore() --> 暂时不进行测试
-	// server.initMysqlStore()
+	server.initRedisStore() // 用于发布变更通知
+	server.initMysqlStore()
 
 	// es
 	// mq
@@ -142,11 +154,43 @@ func (server *apiServer) PrepareServer() *perparesApiServer {
 		return nil
 	}))
 
+	// 加载签发 token 的密钥
+	keys, err := server.jwt.KeySet()
+	if err != nil {
+		log.Fatalf("load jwt keys failed: %s", err.Error())
+	}
+	if keys.SigningKey() == nil {
+		log.Fatalf("load jwt keys failed: no private key to sign tokens")
+	}
+
+	// token 吊销列表保存在 redis 中, 与 authz 共用
+	revocation := auth.NewRevocation(&cache.RedisCluster{}, server.jwt.RevocationFailClosed)
+
+	// 单点登录, id token 需要客户端通过 jwks 验证, 所以只能使用非对称密钥签发
+	var provider *oidc.Provider
+	if server.oidc.Enable {
+		if _, ok := keys.SigningKey().Method.(*jwt.SigningMethodHMAC); ok {
+			log.Fatalf("oidc provider requires asymmetric jwt keys, please configure jwt.keys")
+		}
+		provider = oidc.NewProvider(server.oidc, keys, store.GetFactory(), &cache.RedisCluster{}, revocation)
+	}
+
+	// 使用上游 IdP 的 id token 登录
+	var federated auth.FederatedAuthenticator
+	if len(server.federation.Providers) > 0 {
+		federated = federation.New(server.federation, store.GetFactory())
+	}
+
 	// 构建路由
-	initRouter(server.GenericServer.Engine)
+	initRouter(server.GenericServer.Engine, keys, revocation, provider, federated)
 
-	// todo 注册 pb 服务
-	// pb.RegisterCacheServer(server.GrpcServer.Server, cacheIns)
+	// 注册 pb 服务, 提供给 authz 服务同步密钥和策略
+	cacheIns, err := cachev1.GetCacheInsOr(store.GetFactory())
+	if err != nil {
+		log.Fatalf("get cache instance failed: %s", err.Error())
+	}
+	pb.RegisterCacheServer(server.GrpcServer.Server, cacheIns)
+	reflection.Register(server.GrpcServer.Server)
 
 	return &perparesApiServer{server}
 }
@@ -0,0 +1,64 @@
+package v1
+
+import (
+	"context"
+	"iam/internal/apiserver/store"
+	"iam/internal/pkg/code"
+	metav1 "iam/pkg/api/meta/v1"
+	"iam/pkg/api/oauth"
+	"iam/pkg/errors"
+	"iam/pkg/util/idutil"
+)
+
+type ClientSvc interface {
+	Create(ctx context.Context, client *oauth.Client) (secret string, err error)
+	Update(ctx context.Context, client *oauth.Client) error
+	Delete(ctx context.Context, username, name string) error
+	Get(ctx context.Context, username, name string) (*oauth.Client, error)
+	List(ctx context.Context, username string, opts metav1.ListOptions) (*oauth.ClientList, error)
+}
+
+type clientSvc struct {
+	factory store.Factory
+}
+
+func newClientSvc(f store.Factory) *clientSvc {
+	return &clientSvc{f}
+}
+
+// Create 创建客户端, 自动生成 clientID/clientSecret, 返回的明文密钥只在此时可见, 公共客户端没有密钥
+func (svc *clientSvc) Create(ctx context.Context, c *oauth.Client) (string, error) {
+	c.ClientID = idutil.NewSecretID()
+	c.ClientSecret = ""
+
+	var secret string
+	if !c.Public {
+		secret = idutil.NewSecretKey()
+		c.ClientSecret = secret
+		if err := c.HashSecret(); err != nil {
+			return "", errors.WrapC(err, code.ErrEncrypt, "hash client secret failed")
+		}
+	}
+
+	if err := svc.factory.Clients().Create(ctx, c); err != nil {
+		return "", err
+	}
+
+	return secret, nil
+}
+
+func (svc *clientSvc) Update(ctx context.Context, c *oauth.Client) error {
+	return svc.factory.Clients().Update(ctx, c)
+}
+
+func (svc *clientSvc) Delete(ctx context.Context, username, name string) error {
+	return svc.factory.Clients().Delete(ctx, username, name)
+}
+
+func (svc *clientSvc) Get(ctx context.Context, username, name string) (*oauth.Client, error) {
+	return svc.factory.Clients().Get(ctx, username, name)
+}
+
+func (svc *clientSvc) List(ctx context.Context, username string, opts metav1.ListOptions) (*oauth.ClientList, error) {
+	return svc.factory.Clients().List(ctx, username, opts)
+}
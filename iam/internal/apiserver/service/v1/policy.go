@@ -0,0 +1,49 @@
+package v1
+
+import (
+	"context"
+	"iam/internal/apiserver/store"
+	metav1 "iam/pkg/api/meta/v1"
+	"iam/pkg/api/policy"
+)
+
+type PolicySvc interface {
+	Create(ctx context.Context, policy *policy.Policy) error
+	Update(ctx context.Context, policy *policy.Policy) error
+	Delete(ctx context.Context, username, name string) error
+	DeleteCollection(ctx context.Context, username string, names []string) error
+	Get(ctx context.Context, username, name string) (*policy.Policy, error)
+	List(ctx context.Context, username string, opts metav1.ListOptions) (*policy.PolicyList, error)
+}
+
+type policySvc struct {
+	factory store.Factory
+}
+
+func newPolicySvc(f store.Factory) *policySvc {
+	return &policySvc{f}
+}
+
+func (svc *policySvc) Create(ctx context.Context, p *policy.Policy) error {
+	return svc.factory.Policies().Create(ctx, p)
+}
+
+func (svc *policySvc) Update(ctx context.Context, p *policy.Policy) error {
+	return svc.factory.Policies().Update(ctx, p)
+}
+
+func (svc *policySvc) Delete(ctx context.Context, username, name string) error {
+	return svc.factory.Policies().Delete(ctx, username, name)
+}
+
+func (svc *policySvc) DeleteCollection(ctx context.Context, username string, names []string) error {
+	return svc.factory.Policies().DeleteCollection(ctx, username, names)
+}
+
+func (svc *policySvc) Get(ctx context.Context, username, name string) (*policy.Policy, error) {
+	return svc.factory.Policies().Get(ctx, username, name)
+}
+
+func (svc *policySvc) List(ctx context.Context, username string, opts metav1.ListOptions) (*policy.PolicyList, error) {
+	return svc.factory.Policies().List(ctx, username, opts)
+}
@@ -0,0 +1,63 @@
+package v1
+
+import (
+	"context"
+	"iam/internal/apiserver/store"
+	"iam/internal/pkg/code"
+	metav1 "iam/pkg/api/meta/v1"
+	"iam/pkg/api/secret"
+	"iam/pkg/errors"
+	"iam/pkg/util/idutil"
+)
+
+// MaxSecretCount 每个用户最多可拥有的密钥个数
+const MaxSecretCount = 10
+
+type SecretSvc interface {
+	Create(ctx context.Context, secret *secret.Secret) error
+	Update(ctx context.Context, secret *secret.Secret) error
+	Delete(ctx context.Context, username, name string) error
+	Get(ctx context.Context, username, name string) (*secret.Secret, error)
+	List(ctx context.Context, username string, opts metav1.ListOptions) (*secret.SecretList, error)
+}
+
+type secretSvc struct {
+	factory store.Factory
+}
+
+func newSecretSvc(f store.Factory) *secretSvc {
+	return &secretSvc{f}
+}
+
+// Create 创建密钥, 自动生成 secretID/secretKey , 超过配额时返回 code.ErrReachMaxCount
+func (svc *secretSvc) Create(ctx context.Context, s *secret.Secret) error {
+	count, err := svc.factory.Secrets().Count(ctx, s.Username)
+	if err != nil {
+		return err
+	}
+
+	if count >= MaxSecretCount {
+		return errors.WithCode(code.ErrReachMaxCount, "secret count has reached the max limit %d", MaxSecretCount)
+	}
+
+	s.SecretID = idutil.NewSecretID()
+	s.SecretKey = idutil.NewSecretKey()
+
+	return svc.factory.Secrets().Create(ctx, s)
+}
+
+func (svc *secretSvc) Update(ctx context.Context, s *secret.Secret) error {
+	return svc.factory.Secrets().Update(ctx, s)
+}
+
+func (svc *secretSvc) Delete(ctx context.Context, username, name string) error {
+	return svc.factory.Secrets().Delete(ctx, username, name)
+}
+
+func (svc *secretSvc) Get(ctx context.Context, username, name string) (*secret.Secret, error) {
+	return svc.factory.Secrets().Get(ctx, username, name)
+}
+
+func (svc *secretSvc) List(ctx context.Context, username string, opts metav1.ListOptions) (*secret.SecretList, error) {
+	return svc.factory.Secrets().List(ctx, username, opts)
+}
@@ -6,6 +6,9 @@ import "iam/internal/apiserver/store"
 
 type Service interface {
 	User() UserSvc
+	Secrets() SecretSvc
+	Policies() PolicySvc
+	Clients() ClientSvc
 }
 
 type service struct {
@@ -16,6 +19,18 @@ func (svc *service) User() UserSvc {
 	return newUserSvc(svc.factory)
 }
 
+func (svc *service) Secrets() SecretSvc {
+	return newSecretSvc(svc.factory)
+}
+
+func (svc *service) Policies() PolicySvc {
+	return newPolicySvc(svc.factory)
+}
+
+func (svc *service) Clients() ClientSvc {
+	return newClientSvc(svc.factory)
+}
+
 // NewSvc 外部使用服务，返回对应操作的接口
 func NewSvc(factory store.Factory) Service {
 	return &service{factory}
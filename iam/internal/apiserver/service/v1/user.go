@@ -3,6 +3,7 @@ package v1
 import (
 	"context"
 	"iam/internal/apiserver/store"
+	metav1 "iam/pkg/api/meta/v1"
 	"iam/pkg/api/user"
 )
 
@@ -12,6 +13,8 @@ type UserSvc interface {
 	// 批量删除
 	UpdateUser(ctx context.Context, user *user.User) error
 	GetUser(ctx context.Context, userId uint64) (*user.User, error)
+	GetUserByName(ctx context.Context, username string) (*user.User, error)
+	List(ctx context.Context, opts metav1.ListOptions) (*user.UserList, error)
 }
 
 type userSvc struct {
@@ -37,3 +40,21 @@ func (svc *userSvc) UpdateUser(ctx context.Context, user *user.User) error {
 func (svc *userSvc) GetUser(ctx context.Context, userId uint64) (*user.User, error) {
 	return svc.factory.User().GetUser(ctx, userId)
 }
+
+func (svc *userSvc) GetUserByName(ctx context.Context, username string) (*user.User, error) {
+	return svc.factory.User().GetUserByName(ctx, username)
+}
+
+// List 分页获取用户, 返回的用户信息中不包含密码
+func (svc *userSvc) List(ctx context.Context, opts metav1.ListOptions) (*user.UserList, error) {
+	users, err := svc.factory.User().List(ctx, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, u := range users.Items {
+		u.Password = ""
+	}
+
+	return users, nil
+}
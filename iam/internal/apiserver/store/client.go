@@ -0,0 +1,17 @@
+package store
+
+import (
+	"context"
+	metav1 "iam/pkg/api/meta/v1"
+	"iam/pkg/api/oauth"
+)
+
+// ClientStore OIDC 客户端相关的db操作
+type ClientStore interface {
+	Create(ctx context.Context, client *oauth.Client) error
+	Update(ctx context.Context, client *oauth.Client) error
+	Delete(ctx context.Context, username, name string) error
+	Get(ctx context.Context, username, name string) (*oauth.Client, error)
+	GetByClientID(ctx context.Context, clientID string) (*oauth.Client, error)
+	List(ctx context.Context, username string, opts metav1.ListOptions) (*oauth.ClientList, error) // username 为空时获取全部用户的客户端
+}
@@ -0,0 +1,12 @@
+package store
+
+import (
+	"context"
+	"iam/pkg/api/user"
+)
+
+// IdentityStore 上游 IdP 用户关联相关的db操作
+type IdentityStore interface {
+	Create(ctx context.Context, identity *user.Identity) error
+	Get(ctx context.Context, issuer, subject string) (*user.Identity, error)
+}
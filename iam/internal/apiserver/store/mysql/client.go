@@ -0,0 +1,86 @@
+package mysql
+
+import (
+	"context"
+	"gorm.io/gorm"
+	"iam/internal/pkg/code"
+	metav1 "iam/pkg/api/meta/v1"
+	"iam/pkg/api/oauth"
+	"iam/pkg/errors"
+)
+
+type clientStore struct {
+	db *gorm.DB
+}
+
+func newClients(ds *gorm.DB) *clientStore {
+	return &clientStore{ds}
+}
+
+// Create 添加 client
+func (store *clientStore) Create(ctx context.Context, client *oauth.Client) error {
+	err := store.db.WithContext(ctx).Create(client).Error
+
+	return errors.WrapC(err, code.ErrDatabase, "create client failed")
+}
+
+// Update 更新 client
+func (store *clientStore) Update(ctx context.Context, client *oauth.Client) error {
+	err := store.db.WithContext(ctx).Save(client).Error
+
+	return errors.WrapC(err, code.ErrDatabase, "update client failed")
+}
+
+// Delete 删除某个用户的 client
+func (store *clientStore) Delete(ctx context.Context, username, name string) error {
+	err := store.db.WithContext(ctx).Where("username = ? and name = ?", username, name).Delete(&oauth.Client{}).Error
+
+	return errors.WrapC(err, code.ErrDatabase, "delete client failed")
+}
+
+// Get 获取某个用户的 client
+func (store *clientStore) Get(ctx context.Context, username, name string) (*oauth.Client, error) {
+	c := &oauth.Client{}
+	err := store.db.WithContext(ctx).Where("username = ? and name = ?", username, name).Take(c).Error
+	if err != nil {
+		return nil, wrapNotFound(err, code.ErrClientNotFound, "client %s not found", name)
+	}
+
+	return c, nil
+}
+
+// GetByClientID 通过 clientID 获取 client, 供 OIDC 授权时使用
+func (store *clientStore) GetByClientID(ctx context.Context, clientID string) (*oauth.Client, error) {
+	c := &oauth.Client{}
+	err := store.db.WithContext(ctx).Where("clientID = ?", clientID).Take(c).Error
+	if err != nil {
+		return nil, wrapNotFound(err, code.ErrClientNotFound, "client %s not found", clientID)
+	}
+
+	return c, nil
+}
+
+// List 分页获取 client, username 为空时获取全部
+func (store *clientStore) List(ctx context.Context, username string, opts metav1.ListOptions) (*oauth.ClientList, error) {
+	ret := &oauth.ClientList{}
+
+	offset, limit := unpointerPage(opts)
+
+	d := store.db.WithContext(ctx).Model(&oauth.Client{})
+	if username != "" {
+		d = d.Where("username = ?", username)
+	}
+
+	var count int64
+	if err := d.Count(&count).Error; err != nil {
+		return nil, errors.WrapC(err, code.ErrDatabase, "count clients failed")
+	}
+
+	err := d.Offset(offset).Limit(limit).Order("id desc").Find(&ret.Items).Error
+	if err != nil {
+		return nil, errors.WrapC(err, code.ErrDatabase, "list clients failed")
+	}
+	ret.Count = int(count)
+
+	return ret, nil
+}
@@ -0,0 +1,35 @@
+package mysql
+
+import (
+	"context"
+	"gorm.io/gorm"
+	"iam/internal/pkg/code"
+	"iam/pkg/api/user"
+	"iam/pkg/errors"
+)
+
+type identityStore struct {
+	db *gorm.DB
+}
+
+func newIdentities(ds *gorm.DB) *identityStore {
+	return &identityStore{ds}
+}
+
+// Create 添加关联
+func (store *identityStore) Create(ctx context.Context, identity *user.Identity) error {
+	err := store.db.WithContext(ctx).Create(identity).Error
+
+	return errors.WrapC(err, code.ErrDatabase, "create identity failed")
+}
+
+// Get 获取上游用户关联的本地用户
+func (store *identityStore) Get(ctx context.Context, issuer, subject string) (*user.Identity, error) {
+	identity := &user.Identity{}
+	err := store.db.WithContext(ctx).Where("issuer = ? and subject = ?", issuer, subject).Take(identity).Error
+	if err != nil {
+		return nil, wrapNotFound(err, code.ErrIdentityNotFound, "identity %s of %s not found", subject, issuer)
+	}
+
+	return identity, nil
+}
@@ -2,10 +2,13 @@ package mysql
 
 import (
 	"fmt"
-	"github.com/pkg/errors"
 	"gorm.io/gorm"
 	"iam/internal/apiserver/store"
+	"iam/internal/pkg/code"
 	"iam/internal/pkg/options"
+	metav1 "iam/pkg/api/meta/v1"
+	"iam/pkg/errors"
+	"math"
 	"sync"
 
 	"iam/pkg/db"
@@ -20,6 +23,22 @@ func (store *datastore) User() store.UserStore {
 	return newUsers(store.db)
 }
 
+func (store *datastore) Secrets() store.SecretStore {
+	return newSecrets(store.db)
+}
+
+func (store *datastore) Policies() store.PolicyStore {
+	return newPolicies(store.db)
+}
+
+func (store *datastore) Clients() store.ClientStore {
+	return newClients(store.db)
+}
+
+func (store *datastore) Identities() store.IdentityStore {
+	return newIdentities(store.db)
+}
+
 func (store *datastore) Close() error {
 
 	myDb, err := store.db.DB()
@@ -71,3 +90,26 @@ func GetStoreFactory(opts *options.MysqlOptions) (store.Factory, error) {
 
 	return mysqlFactory, nil
 }
+
+// unpointerPage 将 ListOptions 转换为 gorm 使用的 offset/limit, limit <= 0 表示不进行限制
+// 注: mysql 中 OFFSET 必须和 LIMIT 同时使用, 所以不限制时使用 math.MaxInt32 而不是 -1
+func unpointerPage(opts metav1.ListOptions) (offset int, limit int) {
+	offset, limit = opts.Offset, opts.Limit
+	if offset < 0 {
+		offset = 0
+	}
+	if limit <= 0 {
+		limit = math.MaxInt32
+	}
+
+	return
+}
+
+// wrapNotFound 记录不存在时返回 notFound 错误码, 其他错误返回 ErrDatabase
+func wrapNotFound(err error, notFound int, format string, args ...interface{}) error {
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return errors.WithCode(notFound, format, args...)
+	}
+
+	return errors.WrapC(err, code.ErrDatabase, "query failed")
+}
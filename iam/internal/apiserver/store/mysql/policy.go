@@ -0,0 +1,82 @@
+package mysql
+
+import (
+	"context"
+	"gorm.io/gorm"
+	"iam/internal/pkg/code"
+	metav1 "iam/pkg/api/meta/v1"
+	"iam/pkg/api/policy"
+	"iam/pkg/errors"
+)
+
+type policyStore struct {
+	db *gorm.DB
+}
+
+func newPolicies(ds *gorm.DB) *policyStore {
+	return &policyStore{ds}
+}
+
+// Create 添加 policy
+func (store *policyStore) Create(ctx context.Context, policy *policy.Policy) error {
+	err := store.db.WithContext(ctx).Create(policy).Error
+
+	return errors.WrapC(err, code.ErrDatabase, "create policy failed")
+}
+
+// Update 更新 policy
+func (store *policyStore) Update(ctx context.Context, policy *policy.Policy) error {
+	err := store.db.WithContext(ctx).Save(policy).Error
+
+	return errors.WrapC(err, code.ErrDatabase, "update policy failed")
+}
+
+// Delete 删除某个用户的 policy, 删除的记录由 policy_BEFORE_DELETE 触发器写入 policy_audit
+func (store *policyStore) Delete(ctx context.Context, username, name string) error {
+	err := store.db.WithContext(ctx).Where("username = ? and name = ?", username, name).Delete(&policy.Policy{}).Error
+
+	return errors.WrapC(err, code.ErrDatabase, "delete policy failed")
+}
+
+// DeleteCollection 批量删除某个用户的 policy
+func (store *policyStore) DeleteCollection(ctx context.Context, username string, names []string) error {
+	err := store.db.WithContext(ctx).Where("username = ? and name in (?)", username, names).Delete(&policy.Policy{}).Error
+
+	return errors.WrapC(err, code.ErrDatabase, "delete policies failed")
+}
+
+// Get 获取某个用户的 policy
+func (store *policyStore) Get(ctx context.Context, username, name string) (*policy.Policy, error) {
+	p := &policy.Policy{}
+	err := store.db.WithContext(ctx).Where("username = ? and name = ?", username, name).Take(p).Error
+	if err != nil {
+		return nil, wrapNotFound(err, code.ErrPolicyNotFound, "policy %s not found", name)
+	}
+
+	return p, nil
+}
+
+// List 分页获取 policy, username 为空时获取全部
+func (store *policyStore) List(ctx context.Context, username string, opts metav1.ListOptions) (*policy.PolicyList, error) {
+	ret := &policy.PolicyList{}
+
+	offset, limit := unpointerPage(opts)
+
+	d := store.db.WithContext(ctx).Model(&policy.Policy{})
+	if username != "" {
+		d = d.Where("username = ?", username)
+	}
+
+	var count int64
+	if err := d.Count(&count).Error; err != nil {
+		return nil, errors.WrapC(err, code.ErrDatabase, "count policies failed")
+	}
+
+	err := d.Offset(offset).Limit(limit).Order("id desc").Find(&ret.Items).Error
+	if err != nil {
+		return nil, errors.WrapC(err, code.ErrDatabase, "list policies failed")
+	}
+	ret.Count = int(count)
+
+	return ret, nil
+}
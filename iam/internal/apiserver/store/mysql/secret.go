@@ -0,0 +1,83 @@
+package mysql
+
+import (
+	"context"
+	"gorm.io/gorm"
+	"iam/internal/pkg/code"
+	metav1 "iam/pkg/api/meta/v1"
+	"iam/pkg/api/secret"
+	"iam/pkg/errors"
+)
+
+type secretStore struct {
+	db *gorm.DB
+}
+
+func newSecrets(ds *gorm.DB) *secretStore {
+	return &secretStore{ds}
+}
+
+// Create 添加 secret
+func (store *secretStore) Create(ctx context.Context, secret *secret.Secret) error {
+	err := store.db.WithContext(ctx).Create(secret).Error
+
+	return errors.WrapC(err, code.ErrDatabase, "create secret failed")
+}
+
+// Update 更新 secret
+func (store *secretStore) Update(ctx context.Context, secret *secret.Secret) error {
+	err := store.db.WithContext(ctx).Save(secret).Error
+
+	return errors.WrapC(err, code.ErrDatabase, "update secret failed")
+}
+
+// Delete 删除某个用户的 secret
+func (store *secretStore) Delete(ctx context.Context, username, name string) error {
+	err := store.db.WithContext(ctx).Where("username = ? and name = ?", username, name).Delete(&secret.Secret{}).Error
+
+	return errors.WrapC(err, code.ErrDatabase, "delete secret failed")
+}
+
+// Get 获取某个用户的 secret
+func (store *secretStore) Get(ctx context.Context, username, name string) (*secret.Secret, error) {
+	s := &secret.Secret{}
+	err := store.db.WithContext(ctx).Where("username = ? and name = ?", username, name).Take(s).Error
+	if err != nil {
+		return nil, wrapNotFound(err, code.ErrSecretNotFound, "secret %s not found", name)
+	}
+
+	return s, nil
+}
+
+// List 分页获取 secret, username 为空时获取全部
+func (store *secretStore) List(ctx context.Context, username string, opts metav1.ListOptions) (*secret.SecretList, error) {
+	ret := &secret.SecretList{}
+
+	offset, limit := unpointerPage(opts)
+
+	d := store.db.WithContext(ctx).Model(&secret.Secret{})
+	if username != "" {
+		d = d.Where("username = ?", username)
+	}
+
+	var count int64
+	if err := d.Count(&count).Error; err != nil {
+		return nil, errors.WrapC(err, code.ErrDatabase, "count secrets failed")
+	}
+
+	err := d.Offset(offset).Limit(limit).Order("id desc").Find(&ret.Items).Error
+	if err != nil {
+		return nil, errors.WrapC(err, code.ErrDatabase, "list secrets failed")
+	}
+	ret.Count = int(count)
+
+	return ret, nil
+}
+
+// Count 获取某个用户的 secret 个数
+func (store *secretStore) Count(ctx context.Context, username string) (int64, error) {
+	var count int64
+	err := store.db.WithContext(ctx).Model(&secret.Secret{}).Where("username = ?", username).Count(&count).Error
+
+	return count, errors.WrapC(err, code.ErrDatabase, "count secrets failed")
+}
@@ -3,9 +3,16 @@ package mysql
 import (
 	"context"
 	"gorm.io/gorm"
+	"iam/internal/pkg/code"
+	metav1 "iam/pkg/api/meta/v1"
 	"iam/pkg/api/user"
+	"iam/pkg/errors"
+	"regexp"
 )
 
+// 用户名唯一索引冲突
+var duplicateUserName = regexp.MustCompile(`Duplicate entry '.*' for key '(user\.)?idx_name'`)
+
 type userStore struct {
 	db *gorm.DB
 }
@@ -16,27 +23,70 @@ func newUsers(ds *gorm.DB) *userStore {
 
 // CreateUser 添加 user
 func (store *userStore) CreateUser(ctx context.Context, user *user.User) error {
+	err := store.db.WithContext(ctx).Create(&user).Error
+	if err != nil {
+		if duplicateUserName.MatchString(err.Error()) {
+			return errors.WrapC(err, code.ErrUserAlreadyExist, "user %s already exist", user.Name)
+		}
+
+		return errors.WrapC(err, code.ErrDatabase, "create user failed")
+	}
 
-	return store.db.Create(&user).Error
+	return nil
 }
 
-// DeleteUser 删除 user
+// DeleteUser 删除 user, 该用户相关的密钥和策略由 user_BEFORE_DELETE 触发器进行删除
 func (store *userStore) DeleteUser(ctx context.Context, userId uint64) error {
-	// 删除该用户相关的策略,
-	return nil
+	err := store.db.WithContext(ctx).Where("id = ?", userId).Delete(&user.User{}).Error
+
+	return errors.WrapC(err, code.ErrDatabase, "delete user failed")
 }
 
 // UpdateUser 更新用户信息
 func (store *userStore) UpdateUser(ctx context.Context, user *user.User) error {
-	return store.db.Save(user).Error
+	err := store.db.WithContext(ctx).Save(user).Error
+
+	return errors.WrapC(err, code.ErrDatabase, "update user failed")
 }
 
-func (store *userStore) GetUser(ctx context.Context, userId uint64) (user *user.User, err error) {
-	err = store.db.Where("id = ? and status = 1", userId).Take(user).Error
-	return
+func (store *userStore) GetUser(ctx context.Context, userId uint64) (*user.User, error) {
+	userInfo := &user.User{}
+	err := store.db.WithContext(ctx).Where("id = ? and status = 1", userId).Take(userInfo).Error
+	if err != nil {
+		return nil, wrapNotFound(err, code.ErrUserNotFound, "user %d not found", userId)
+	}
+
+	return userInfo, nil
 }
 
-func (store *userStore) GetUserByName(ctx context.Context, username string) (user *user.User, err error) {
-	err = store.db.Where("username = ? and status = 1", username).Take(user).Error
-	return
+func (store *userStore) GetUserByName(ctx context.Context, username string) (*user.User, error) {
+	userInfo := &user.User{}
+	err := store.db.WithContext(ctx).Where("name = ? and status = 1", username).Take(userInfo).Error
+	if err != nil {
+		return nil, wrapNotFound(err, code.ErrUserNotFound, "user %s not found", username)
+	}
+
+	return userInfo, nil
+}
+
+// List 根据 offset/limit 分页获取用户, Count 为符合条件的总数
+func (store *userStore) List(ctx context.Context, opts metav1.ListOptions) (*user.UserList, error) {
+	ret := &user.UserList{}
+
+	offset, limit := unpointerPage(opts)
+
+	d := store.db.WithContext(ctx).Model(&user.User{}).Where("status = 1")
+
+	var count int64
+	if err := d.Count(&count).Error; err != nil {
+		return nil, errors.WrapC(err, code.ErrDatabase, "count users failed")
+	}
+
+	err := d.Offset(offset).Limit(limit).Order("id desc").Find(&ret.Items).Error
+	if err != nil {
+		return nil, errors.WrapC(err, code.ErrDatabase, "list users failed")
+	}
+	ret.Count = int(count)
+
+	return ret, nil
 }
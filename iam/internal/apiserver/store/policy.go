@@ -0,0 +1,17 @@
+package store
+
+import (
+	"context"
+	metav1 "iam/pkg/api/meta/v1"
+	"iam/pkg/api/policy"
+)
+
+// PolicyStore 策略相关的db操作
+type PolicyStore interface {
+	Create(ctx context.Context, policy *policy.Policy) error
+	Update(ctx context.Context, policy *policy.Policy) error
+	Delete(ctx context.Context, username, name string) error
+	DeleteCollection(ctx context.Context, username string, names []string) error // 批量删除
+	Get(ctx context.Context, username, name string) (*policy.Policy, error)
+	List(ctx context.Context, username string, opts metav1.ListOptions) (*policy.PolicyList, error) // username 为空时获取全部用户的策略
+}
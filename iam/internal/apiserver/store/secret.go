@@ -0,0 +1,17 @@
+package store
+
+import (
+	"context"
+	metav1 "iam/pkg/api/meta/v1"
+	"iam/pkg/api/secret"
+)
+
+// SecretStore 密钥相关的db操作
+type SecretStore interface {
+	Create(ctx context.Context, secret *secret.Secret) error
+	Update(ctx context.Context, secret *secret.Secret) error
+	Delete(ctx context.Context, username, name string) error
+	Get(ctx context.Context, username, name string) (*secret.Secret, error)
+	List(ctx context.Context, username string, opts metav1.ListOptions) (*secret.SecretList, error) // username 为空时获取全部用户的密钥
+	Count(ctx context.Context, username string) (int64, error)                                      // 获取某个用户的密钥个数
+}
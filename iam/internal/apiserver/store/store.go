@@ -6,6 +6,10 @@ var client Factory
 
 type Factory interface {
 	User() UserStore
+	Secrets() SecretStore
+	Policies() PolicyStore
+	Clients() ClientStore
+	Identities() IdentityStore
 	Close() error
 }
 
@@ -2,6 +2,7 @@ package store
 
 import (
 	"context"
+	metav1 "iam/pkg/api/meta/v1"
 	"iam/pkg/api/user"
 )
 
@@ -12,4 +13,5 @@ type UserStore interface {
 	UpdateUser(ctx context.Context, user *user.User) error
 	GetUser(ctx context.Context, userId uint64) (*user.User, error)
 	GetUserByName(ctx context.Context, username string) (*user.User, error)
+	List(ctx context.Context, opts metav1.ListOptions) (*user.UserList, error) // 分页获取用户
 }
@@ -2,6 +2,8 @@ package analytics
 
 import (
 	"encoding/json"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
 	"github.com/sirupsen/logrus"
 	"iam/pkg/cache"
 	"sync"
@@ -19,18 +21,65 @@ const analyticsKey = "iam-authorization-analytics"
 
 var analytics *Analytics
 
+// 缓冲 chan 中等待写入 redis 的记录数
+var _ = promauto.NewGaugeFunc(prometheus.GaugeOpts{
+	Name: "iam_analytics_queue_depth",
+	Help: "Number of analytics records waiting to be written to redis.",
+}, func() float64 {
+	if a := GetAnalytics(); a != nil {
+		return float64(len(a.recordsChan))
+	}
+
+	return 0
+})
+
+// 授权结论的类型, 供统计使用, Conclusion 为包含策略 ID 的描述
+const (
+	ConclusionAllow      = "allow"       // 有策略允许
+	ConclusionForcedDeny = "forced_deny" // 有策略强制拒绝
+	ConclusionNoMatch    = "no_match"    // 没有策略允许
+)
+
 // AnalyticsRecord 写入记录
 type AnalyticsRecord struct {
-	CreateTime int64     `json:"create_time"`
-	Username   string    `json:"username"`
-	Effect     string    `json:"effect"`
-	Conclusion string    `json:"conclusion"`
-	Request    string    `json:"request"`
-	Policies   string    `json:"policies"`
-	Deciders   string    `json:"deciders"`
-	ExpireAt   time.Time `json:"expireAt"`
+	CreateTime     int64     `json:"create_time"`
+	Username       string    `json:"username"`
+	Effect         string    `json:"effect"`
+	Conclusion     string    `json:"conclusion"`
+	ConclusionCode string    `json:"conclusion_code"`
+	Request        string    `json:"request"`
+	Policies       string    `json:"policies"`
+	Deciders       string    `json:"deciders"`
+	ExpireAt       time.Time `json:"expireAt"`
 }
 
+// 丢弃和写入 redis 的记录数
+var (
+	droppedRecords = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "iam_analytics_records_dropped_total",
+		Help: "Total number of analytics records dropped, by reason.",
+	}, []string{"reason"})
+	flushedRecords = promauto.NewCounter(prometheus.CounterOpts{
+		Name: "iam_analytics_records_flushed_total",
+		Help: "Total number of analytics records written to redis.",
+	})
+)
+
+// 丢弃记录的原因
+const (
+	dropReasonBufferFull = "buffer_full" // 缓冲 chan 已满
+	dropReasonRedisDown  = "redis_down"  // redis 长时间不可用, 重试缓冲区已满
+	dropReasonShutdown   = "shutdown"    // 退出时仍无法写入 redis
+	dropReasonStopped    = "stopped"     // Stop 之后仍有记录写入
+)
+
+const (
+	// maxPendingBatches 写入 redis 失败时, 每个 worker 最多保留 workBufferSize 的多少倍进行重试
+	maxPendingBatches = 10
+	// finalFlushRetries 退出时写入 redis 的最大重试次数
+	finalFlushRetries = 3
+)
+
 // Analytics 匹配记录认证是否通过的记录
 type Analytics struct {
 	store                 cache.AnalyticsHandler // 操作redis的一些内容
@@ -39,7 +88,11 @@ type Analytics struct {
 	workBufferSize        int                    // buffer 个数 -> redis
 	maxSyncTime           int                    // 最大多少ms进行同步
 	storageExpirationTime time.Duration          // 过期时间
+	detailedRecording     bool                   // 是否记录请求、策略等详细内容
+	dropPolicy            string                 // 缓冲 chan 满时的处理策略
+	blockTimeout          time.Duration          // dropPolicy 为 block 时最多等待的时间
 	stop                  uint32                 // chan关闭
+	stopMu                sync.RWMutex           // 避免 Stop 关闭 chan 时仍有记录写入
 	poolWg                sync.WaitGroup
 }
 
@@ -51,13 +104,20 @@ func (a *Analytics) SetExpiration(expire int) {
 }
 
 func NewAnalytics(opt AnalyticsOptions) *Analytics {
+	workBufferSize := opt.RecordsBufferSize / opt.PoolSize
+	if workBufferSize < 1 {
+		workBufferSize = 1
+	}
 
 	analytics = &Analytics{
 		recordsChan:           make(chan *AnalyticsRecord, opt.RecordsBufferSize),
 		poolSize:              opt.PoolSize,
-		workBufferSize:        opt.RecordsBufferSize / opt.PoolSize,
+		workBufferSize:        workBufferSize,
 		maxSyncTime:           opt.MaxSyncTime,
 		storageExpirationTime: opt.StorageExpirationTime,
+		detailedRecording:     opt.EnableDetailedRecording,
+		dropPolicy:            opt.DropPolicy,
+		blockTimeout:          opt.BlockTimeout,
 	}
 	return analytics
 }
@@ -66,85 +126,156 @@ func (a *Analytics) SetStore(store cache.AnalyticsHandler) {
 	a.store = store
 }
 
+// DetailedRecording 是否需要记录请求、策略和决策策略等详细内容
+func (a *Analytics) DetailedRecording() bool {
+	return a.detailedRecording
+}
+
 // GetAnalytics 获取全局消费日志结构
 func GetAnalytics() *Analytics {
 	return analytics
 }
 
 func (a *Analytics) Start() {
-	// 判断redis此时是否能够正常连接
-
 	atomic.SwapUint32(&a.stop, 0)
 
 	for i := 0; i < a.poolSize; i++ {
+		a.poolWg.Add(1)
 		go a.workConsumption()
 	}
-
 }
 
+// Stop 关闭 chan, 等待所有 worker 将剩余的记录写入 redis 后返回
 func (a *Analytics) Stop() {
-	atomic.SwapUint32(&a.stop, 1)
-	close(a.recordsChan)
+	a.stopMu.Lock()
+	if atomic.SwapUint32(&a.stop, 1) == 0 {
+		close(a.recordsChan)
+	}
+	a.stopMu.Unlock()
+
 	a.poolWg.Wait()
 }
 
+// SendRecord 将记录写入缓冲 chan, chan 满时根据 dropPolicy 处理, 不会无限阻塞授权请求
 func (a *Analytics) SendRecord(record *AnalyticsRecord) error {
+	a.stopMu.RLock()
+	defer a.stopMu.RUnlock()
+
 	// 判断chan是否关闭
 	if atomic.LoadUint32(&a.stop) > 0 {
+		droppedRecords.WithLabelValues(dropReasonStopped).Inc()
 		return nil
 	}
-	a.recordsChan <- record
+	if record.ExpireAt.IsZero() {
+		record.ExpireAt = time.Now().Add(a.storageExpirationTime)
+	}
+
+	select {
+	case a.recordsChan <- record:
+		return nil
+	default:
+	}
+
+	switch a.dropPolicy {
+	case DropOldest:
+		// 丢弃最旧的一条后再尝试一次, 仍然失败时丢弃当前记录
+		select {
+		case <-a.recordsChan:
+			droppedRecords.WithLabelValues(dropReasonBufferFull).Inc()
+		default:
+		}
+		select {
+		case a.recordsChan <- record:
+			return nil
+		default:
+		}
+	case Block:
+		timer := time.NewTimer(a.blockTimeout)
+		defer timer.Stop()
+		select {
+		case a.recordsChan <- record:
+			return nil
+		case <-timer.C:
+		}
+	}
+
+	droppedRecords.WithLabelValues(dropReasonBufferFull).Inc()
+
 	return nil
 }
 
 // 根据参数进行创建消费
 func (a *Analytics) workConsumption() {
-
-	lastSentTS := time.Now()
-	buffers := make([][]byte, 0)
-
-	a.poolWg.Add(1)
 	defer a.poolWg.Done()
 
-	for {
+	ticker := time.NewTicker(time.Duration(a.maxSyncTime) * time.Millisecond)
+	defer ticker.Stop()
 
-		var readyToSend bool
+	buffers := make([][]byte, 0, a.workBufferSize)
+	for {
 		select {
-
 		case record, ok := <-a.recordsChan:
-			// 说明改chan已经关闭，需要将该 buffers 进行存储下
+			// 说明该chan已经关闭，需要将剩余的 buffers 进行存储后退出
 			if !ok {
-				a.store.AppendAnalytics(analyticsKey, buffers)
-				break
+				a.finalFlush(buffers)
+				return
 			}
 
 			bytes, err := json.Marshal(record)
 			if err != nil {
 				logrus.Errorf("marshal record err:%v", err)
-
-			} else {
-				buffers = append(buffers, bytes)
+				continue
 			}
+			buffers = append(buffers, bytes)
 
 			if len(buffers) >= a.workBufferSize {
-				readyToSend = true
+				buffers = a.flush(buffers)
 			}
 
-		case <-time.After(time.Duration(a.maxSyncTime) * time.Millisecond):
-			readyToSend = true
+		case <-ticker.C:
+			buffers = a.flush(buffers)
 		}
+	}
+}
 
-		// 个数>xx个 || 时间超过多少
-		timeSub := time.Now().Sub(lastSentTS).Milliseconds()
+// flush 将 buffers 写入 redis, 返回未写入成功需要重试的记录.
+// redis 不可用时不进行写入, 重试的记录超过上限时丢弃最旧的
+func (a *Analytics) flush(buffers [][]byte) [][]byte {
+	if len(buffers) == 0 {
+		return buffers
+	}
 
-		if len(buffers) > 0 && (readyToSend || timeSub >= int64(a.maxSyncTime)) {
-			a.store.AppendAnalytics(analyticsKey, buffers)
-			buffers = buffers[:0]
-			lastSentTS = time.Now()
+	if a.store.Connect() {
+		err := a.store.AppendAnalytics(analyticsKey, buffers)
+		if err == nil {
+			flushedRecords.Add(float64(len(buffers)))
+			return buffers[:0]
 		}
+		logrus.Warnf("append %d analytics records to redis failed, will retry: %v", len(buffers), err)
+	}
 
+	if limit := a.workBufferSize * maxPendingBatches; len(buffers) > limit {
+		dropped := len(buffers) - limit
+		droppedRecords.WithLabelValues(dropReasonRedisDown).Add(float64(dropped))
+		buffers = append(buffers[:0], buffers[dropped:]...)
 	}
 
+	return buffers
+}
+
+// finalFlush 退出前写入剩余的记录, 失败时进行有限次数的重试
+func (a *Analytics) finalFlush(buffers [][]byte) {
+	for i := 0; i < finalFlushRetries && len(buffers) > 0; i++ {
+		if i > 0 {
+			time.Sleep(time.Duration(a.maxSyncTime) * time.Millisecond)
+		}
+		buffers = a.flush(buffers)
+	}
+
+	if len(buffers) > 0 {
+		logrus.Errorf("drop %d analytics records on shutdown", len(buffers))
+		droppedRecords.WithLabelValues(dropReasonShutdown).Add(float64(len(buffers)))
+	}
 }
 
 /*
@@ -14,14 +14,25 @@ type AnalyticsOptions struct {
 	StorageExpirationTime   time.Duration `json:"storage-expiration-time"   mapstructure:"storage-expiration-time"`   // 过期时间
 	Enable                  bool          `json:"enable"                    mapstructure:"enable"`                    // 是否启用缓冲日志
 	EnableDetailedRecording bool          `json:"enable-detailed-recording" mapstructure:"enable-detailed-recording"` // 启用详细记录(暂时)
+	DropPolicy              string        `json:"drop-policy"               mapstructure:"drop-policy"`               // 缓冲区满时的处理策略
+	BlockTimeout            time.Duration `json:"block-timeout"             mapstructure:"block-timeout"`             // drop-policy 为 block 时最多等待的时间
 }
 
+// 缓冲区满时的处理策略
+const (
+	DropNewest = "drop-newest" // 丢弃当前记录
+	DropOldest = "drop-oldest" // 丢弃缓冲区中最旧的记录
+	Block      = "block"       // 最多等待 BlockTimeout, 超时后丢弃当前记录
+)
+
 func NewAnalyticsOptions() *AnalyticsOptions {
 	return &AnalyticsOptions{
 		PoolSize:              3,
 		MaxSyncTime:           500,
 		RecordsBufferSize:     300,
 		StorageExpirationTime: time.Hour * 24,
+		DropPolicy:            DropNewest,
+		BlockTimeout:          100 * time.Millisecond,
 	}
 }
 
@@ -36,6 +47,10 @@ func (option *AnalyticsOptions) AddFlags(fs *pflag.FlagSet) {
 	fs.BoolVar(&option.Enable, "analytics.enable", option.Enable, "analytics enable")
 	fs.BoolVar(&option.EnableDetailedRecording, "analytics.enable-detailed-recording", option.EnableDetailedRecording,
 		"analytics enable-detailed-recording")
+	fs.StringVar(&option.DropPolicy, "analytics.drop-policy", option.DropPolicy,
+		"policy when the records buffer is full, one of drop-newest, drop-oldest, block")
+	fs.DurationVar(&option.BlockTimeout, "analytics.block-timeout", option.BlockTimeout,
+		"max time to wait for the records buffer when drop-policy is block")
 }
 
 func (option *AnalyticsOptions) Validate() []error {
@@ -48,5 +63,20 @@ func (option *AnalyticsOptions) Validate() []error {
 		errors = append(errors, fmt.Errorf("--analytics.flush-interval %v must be between 1 and 1000", option.MaxSyncTime))
 	}
 
+	switch option.DropPolicy {
+	case DropNewest, DropOldest:
+	case Block:
+		if option.BlockTimeout <= 0 {
+			errors = append(errors, fmt.Errorf("--analytics.block-timeout %v must be greater than 0", option.BlockTimeout))
+		}
+	default:
+		errors = append(errors, fmt.Errorf("--analytics.drop-policy %s must be one of %s, %s, %s",
+			option.DropPolicy, DropNewest, DropOldest, Block))
+	}
+
+	if option.Enable && option.PoolSize < 1 {
+		errors = append(errors, fmt.Errorf("--analytics.pool-size %v must be greater than 0", option.PoolSize))
+	}
+
 	return errors
 }
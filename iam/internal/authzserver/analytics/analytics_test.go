@@ -0,0 +1,118 @@
+package analytics
+
+import (
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+type fakeStore struct {
+	connected atomic.Bool
+	mu        sync.Mutex
+	values    [][]byte
+}
+
+func (s *fakeStore) Connect() bool { return s.connected.Load() }
+
+func (s *fakeStore) AppendAnalytics(_ string, values [][]byte) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, v := range values {
+		s.values = append(s.values, append([]byte(nil), v...))
+	}
+
+	return nil
+}
+
+func (s *fakeStore) count() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return len(s.values)
+}
+
+func newTestAnalytics(policy string, bufferSize int) *Analytics {
+	return NewAnalytics(AnalyticsOptions{
+		PoolSize:          1,
+		MaxSyncTime:       10,
+		RecordsBufferSize: bufferSize,
+		DropPolicy:        policy,
+		BlockTimeout:      20 * time.Millisecond,
+	})
+}
+
+func TestAnalytics_SendRecordWhenFull(t *testing.T) {
+	dropped := droppedRecords.WithLabelValues(dropReasonBufferFull)
+
+	// drop-newest: 丢弃当前记录
+	a := newTestAnalytics(DropNewest, 1)
+	before := testutil.ToFloat64(dropped)
+	assert.NoError(t, a.SendRecord(&AnalyticsRecord{Username: "a"}))
+	assert.NoError(t, a.SendRecord(&AnalyticsRecord{Username: "b"}))
+	assert.Equal(t, "a", (<-a.recordsChan).Username)
+	assert.Equal(t, before+1, testutil.ToFloat64(dropped))
+
+	// drop-oldest: 丢弃缓冲区中最旧的记录
+	a = newTestAnalytics(DropOldest, 1)
+	assert.NoError(t, a.SendRecord(&AnalyticsRecord{Username: "a"}))
+	assert.NoError(t, a.SendRecord(&AnalyticsRecord{Username: "b"}))
+	assert.Equal(t, "b", (<-a.recordsChan).Username)
+
+	// block: 等待 BlockTimeout 后丢弃
+	a = newTestAnalytics(Block, 1)
+	assert.NoError(t, a.SendRecord(&AnalyticsRecord{Username: "a"}))
+	start := time.Now()
+	assert.NoError(t, a.SendRecord(&AnalyticsRecord{Username: "b"}))
+	assert.GreaterOrEqual(t, time.Since(start), 20*time.Millisecond)
+	assert.Equal(t, "a", (<-a.recordsChan).Username)
+}
+
+func TestAnalytics_StopFlushesAndExits(t *testing.T) {
+	store := &fakeStore{}
+	store.connected.Store(true)
+
+	a := newTestAnalytics(DropNewest, 100)
+	a.maxSyncTime = 60 * 1000 // 只依赖 Stop 时的写入
+	a.SetStore(store)
+	a.Start()
+
+	for i := 0; i < 3; i++ {
+		assert.NoError(t, a.SendRecord(&AnalyticsRecord{Username: "colin"}))
+	}
+
+	done := make(chan struct{})
+	go func() {
+		a.Stop()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("Stop did not return")
+	}
+	assert.Equal(t, 3, store.count())
+
+	// Stop 之后的记录直接丢弃
+	assert.NoError(t, a.SendRecord(&AnalyticsRecord{Username: "colin"}))
+}
+
+func TestAnalytics_RetryWhenRedisDown(t *testing.T) {
+	store := &fakeStore{}
+
+	a := newTestAnalytics(DropNewest, 100)
+	a.SetStore(store)
+	a.Start()
+	defer a.Stop()
+
+	assert.NoError(t, a.SendRecord(&AnalyticsRecord{Username: "colin"}))
+	time.Sleep(50 * time.Millisecond)
+	assert.Equal(t, 0, store.count())
+
+	// redis 恢复后写入之前保留的记录
+	store.connected.Store(true)
+	assert.Eventually(t, func() bool { return store.count() == 1 }, time.Second, 10*time.Millisecond)
+}
@@ -0,0 +1,38 @@
+package authzserver
+
+import (
+	"github.com/sirupsen/logrus"
+	"iam/internal/authzserver/load/cache"
+	"iam/internal/pkg/middleware"
+	"iam/internal/pkg/middleware/auth"
+	"iam/pkg/jwks"
+)
+
+// newCacheAuth 使用 apiserver 同步到内存中的密钥进行 Bearer 认证, keys 非空时同时接受 apiserver 签发的 token
+func newCacheAuth(keys *jwks.KeySet, revocation *auth.Revocation) middleware.AuthStrategy {
+	strategy := auth.NewCacheStrategy(getSecretFunc(), keys, revocation)
+
+	return &strategy
+}
+
+func getSecretFunc() func(string) (auth.Secret, error) {
+	return func(kid string) (auth.Secret, error) {
+		cacheIns, err := cache.GetCacheInsOr(nil)
+		if err != nil || cacheIns == nil {
+			logrus.Errorf("get cache instance failed, err:%v", err)
+			return auth.Secret{}, auth.ErrMissingSecret
+		}
+
+		secret, err := cacheIns.GetSecret(kid)
+		if err != nil {
+			return auth.Secret{}, err
+		}
+
+		return auth.Secret{
+			Username: secret.Username,
+			ID:       secret.SecretId,
+			Key:      secret.SecretKey,
+			Expires:  secret.Expires,
+		}, nil
+	}
+}
@@ -15,6 +15,7 @@ func NewAuthorizer(authorizationClient AuthorizationInterface) *Authorizer {
 		warden: &ladon.Ladon{
 			Manager:     NewManager(authorizationClient),     // 管理器
 			AuditLogger: NewAuditLogger(authorizationClient), // 策略日志
+			Metric:      NewMetric(),                         // 授权结果指标
 		},
 	}
 }
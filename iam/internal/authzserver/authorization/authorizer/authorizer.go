@@ -1,8 +1,15 @@
 package authorizer
 
 import (
+	"encoding/json"
+	"fmt"
 	"github.com/ory/ladon"
+	"github.com/sirupsen/logrus"
+	"iam/internal/authzserver/analytics"
 	"iam/internal/authzserver/authorization"
+	"iam/internal/authzserver/load/cache"
+	"strings"
+	"time"
 )
 
 type PolicyGetter interface {
@@ -48,17 +55,89 @@ func (auth *Authorization) GetAll(limit int64, offset int64) (ladon.Policies, er
 	return nil, nil
 }
 
-// List 获取某个name的所有的,从 db(cache)中获取
+// List 获取某个name的所有的,从 db(cache)中获取, 用户没有策略时返回空, 由 ladon 默认拒绝
 func (auth *Authorization) List(username string) ([]*ladon.DefaultPolicy, error) {
-	return auth.getter.GetPolicy(username)
+	policies, err := auth.getter.GetPolicy(username)
+	if err == cache.ErrPolicyNotFound {
+		return nil, nil
+	}
+
+	return policies, err
 }
 
 // LogRejectedAccessRequest 将认证失败请求日志写到一个统一的chan中，进行后台消费
 func (auth *Authorization) LogRejectedAccessRequest(request *ladon.Request, pool ladon.Policies, deciders ladon.Policies) {
+	conclusion, code := "no policy allowed access", analytics.ConclusionNoMatch
+	switch {
+	case len(deciders) > 1:
+		// 最后一个决策策略为强制拒绝的策略, 之前的都是允许的策略
+		conclusion = fmt.Sprintf("policies %s allow access, but policy %s forcefully denied it",
+			joinPoliciesIDs(deciders[:len(deciders)-1]), deciders[len(deciders)-1].GetID())
+		code = analytics.ConclusionForcedDeny
+	case len(deciders) == 1:
+		conclusion = fmt.Sprintf("policy %s forcefully denied the access", deciders[0].GetID())
+		code = analytics.ConclusionForcedDeny
+	}
 
+	auth.sendRecord(request, pool, deciders, ladon.DenyAccess, conclusion, code)
 }
 
 // LogGrantedAccessRequest 将认证成功日志写到一个统一的chan中，进行后台消费
 func (auth *Authorization) LogGrantedAccessRequest(request *ladon.Request, pool ladon.Policies, deciders ladon.Policies) {
+	conclusion := fmt.Sprintf("policies %s allow access", joinPoliciesIDs(deciders))
+
+	auth.sendRecord(request, pool, deciders, ladon.AllowAccess, conclusion, analytics.ConclusionAllow)
+}
+
+// sendRecord 构建授权记录并交给 analytics, 未开启 analytics 时直接丢弃
+func (auth *Authorization) sendRecord(request *ladon.Request, pool, deciders ladon.Policies, effect, conclusion, code string) {
+	a := analytics.GetAnalytics()
+	if a == nil {
+		return
+	}
+
+	record := &analytics.AnalyticsRecord{
+		CreateTime:     time.Now().Unix(),
+		Username:       usernameOf(request),
+		Effect:         effect,
+		Conclusion:     conclusion,
+		ConclusionCode: code,
+		Request:        marshalToString(request),
+	}
+
+	// 策略内容较大, 仅在开启详细记录时写入
+	if a.DetailedRecording() {
+		record.Policies = marshalToString(pool)
+		record.Deciders = marshalToString(deciders)
+	}
+
+	_ = a.SendRecord(record)
+}
+
+func usernameOf(request *ladon.Request) string {
+	if request == nil || request.Context == nil {
+		return ""
+	}
+	username, _ := request.Context["username"].(string)
+
+	return username
+}
+
+func joinPoliciesIDs(policies ladon.Policies) string {
+	ids := make([]string, 0, len(policies))
+	for _, p := range policies {
+		ids = append(ids, p.GetID())
+	}
+
+	return strings.Join(ids, ", ")
+}
+
+func marshalToString(v interface{}) string {
+	data, err := json.Marshal(v)
+	if err != nil {
+		logrus.Errorf("marshal analytics field failed: %v", err)
+		return ""
+	}
 
+	return string(data)
 }
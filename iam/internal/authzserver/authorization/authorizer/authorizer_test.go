@@ -0,0 +1,80 @@
+package authorizer
+
+import (
+	"encoding/json"
+	"github.com/ory/ladon"
+	"github.com/stretchr/testify/assert"
+	"iam/internal/authzserver/analytics"
+	"sync"
+	"testing"
+	"time"
+)
+
+type fakeAnalyticsStore struct {
+	mu      sync.Mutex
+	records []analytics.AnalyticsRecord
+}
+
+func (s *fakeAnalyticsStore) Connect() bool { return true }
+
+func (s *fakeAnalyticsStore) AppendAnalytics(_ string, values [][]byte) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, v := range values {
+		var r analytics.AnalyticsRecord
+		_ = json.Unmarshal(v, &r)
+		s.records = append(s.records, r)
+	}
+
+	return nil
+}
+
+func (s *fakeAnalyticsStore) wait(t *testing.T, n int) []analytics.AnalyticsRecord {
+	assert.Eventually(t, func() bool {
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		return len(s.records) >= n
+	}, time.Second, 10*time.Millisecond)
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.records
+}
+
+func TestAuthorization_LogAccessRequest(t *testing.T) {
+	store := &fakeAnalyticsStore{}
+	a := analytics.NewAnalytics(analytics.AnalyticsOptions{
+		PoolSize:              1,
+		MaxSyncTime:           10,
+		RecordsBufferSize:     10,
+		StorageExpirationTime: time.Hour,
+	})
+	a.SetStore(store)
+	a.Start()
+	t.Cleanup(a.Stop) // 全局的 analytics, 结束时停止 worker, 避免影响其他测试
+
+	auth := &Authorization{}
+	request := &ladon.Request{Resource: "resources:articles", Action: "read", Context: ladon.Context{"username": "colin"}}
+	allow := &ladon.DefaultPolicy{ID: "p1", Effect: ladon.AllowAccess}
+	deny := &ladon.DefaultPolicy{ID: "p2", Effect: ladon.DenyAccess}
+
+	auth.LogGrantedAccessRequest(request, ladon.Policies{allow}, ladon.Policies{allow})
+	auth.LogRejectedAccessRequest(request, ladon.Policies{allow, deny}, ladon.Policies{allow, deny})
+
+	records := store.wait(t, 2)
+	assert.Len(t, records, 2)
+
+	assert.Equal(t, "colin", records[0].Username)
+	assert.Equal(t, ladon.AllowAccess, records[0].Effect)
+	assert.Equal(t, "policies p1 allow access", records[0].Conclusion)
+	assert.Equal(t, analytics.ConclusionAllow, records[0].ConclusionCode)
+	assert.Contains(t, records[0].Request, "resources:articles")
+	// 未开启详细记录时不写入策略
+	assert.Empty(t, records[0].Policies)
+	assert.Empty(t, records[0].Deciders)
+	assert.True(t, records[0].ExpireAt.After(time.Now()))
+
+	assert.Equal(t, ladon.DenyAccess, records[1].Effect)
+	assert.Equal(t, "policies p1 allow access, but policy p2 forcefully denied it", records[1].Conclusion)
+	assert.Equal(t, analytics.ConclusionForcedDeny, records[1].ConclusionCode)
+}
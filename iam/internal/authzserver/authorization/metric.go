@@ -0,0 +1,40 @@
+package authorization
+
+import (
+	"github.com/ory/ladon"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+// authzDecisions 授权结果计数, decision 为 allow/deny/error, 没有匹配到策略时同样记为 deny
+var authzDecisions = promauto.NewCounterVec(prometheus.CounterOpts{
+	Name: "iam_authz_decisions_total",
+	Help: "Total number of authorization decisions made by the ladon warden.",
+}, []string{"decision"})
+
+// Metric 实现 ladon.Metric, 将 ladon 的授权结果记录到 prometheus
+type Metric struct{}
+
+func NewMetric() ladon.Metric {
+	return &Metric{}
+}
+
+// RequestDeniedBy 被策略显式拒绝
+func (m *Metric) RequestDeniedBy(ladon.Request, ladon.Policy) {
+	authzDecisions.WithLabelValues("deny").Inc()
+}
+
+// RequestAllowedBy 被策略允许
+func (m *Metric) RequestAllowedBy(ladon.Request, ladon.Policies) {
+	authzDecisions.WithLabelValues("allow").Inc()
+}
+
+// RequestNoMatch 没有匹配到策略, 默认拒绝
+func (m *Metric) RequestNoMatch(ladon.Request) {
+	authzDecisions.WithLabelValues("deny").Inc()
+}
+
+// RequestProcessingError 获取策略或者匹配策略时出错
+func (m *Metric) RequestProcessingError(ladon.Request, ladon.Policy, error) {
+	authzDecisions.WithLabelValues("error").Inc()
+}
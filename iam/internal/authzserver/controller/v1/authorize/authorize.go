@@ -1,41 +1,61 @@
 package authorize
 
 import (
-	"fmt"
 	"github.com/gin-gonic/gin"
 	"github.com/ory/ladon"
 	"iam/internal/authzserver/authorization"
 	"iam/internal/authzserver/authorization/authorizer"
+	"iam/internal/pkg/code"
+	"iam/internal/pkg/middleware"
+	"iam/pkg/core"
+	"iam/pkg/errors"
 )
 
 // controller -> authorization (授权人) -> 是否通过
 
+// errMissingFields 请求中缺少 subject/action/resource
+const errMissingFields = "subject, action and resource are required"
+
 type AuthorizeCtl struct {
-	store authorizer.PolicyGetter // 根据 key 得到其拥有的所有权限
+	store        authorizer.PolicyGetter // 根据 key 得到其拥有的所有权限
+	maxBatchSize int                     // 批量授权一次最多的请求个数
 }
 
-func NewAuthorizeCtl(store authorizer.PolicyGetter) AuthorizeCtl {
-	return AuthorizeCtl{store: store}
+func NewAuthorizeCtl(store authorizer.PolicyGetter, maxBatchSize int) AuthorizeCtl {
+	return AuthorizeCtl{store: store, maxBatchSize: maxBatchSize}
 }
 
+// Authorize 根据调用方(密钥所属用户)的策略对请求进行授权, 无论是否通过都返回 200 和 authzv1.Response
 func (ctl *AuthorizeCtl) Authorize(c *gin.Context) {
 	var r ladon.Request
-	if err := c.ShouldBind(&r); err != nil {
-		// todo
-		// core.WriteResponse(c, errors.WithCode(code.ErrBind, err.Error()), nil)
+	if err := c.ShouldBindJSON(&r); err != nil {
+		core.WriteResponse(c, errors.WithCode(code.ErrBind, "%s", err.Error()), nil)
+
+		return
+	}
+
+	if !validRequest(&r) {
+		core.WriteResponse(c, errors.WithCode(code.ErrValidation, errMissingFields), nil)
 
 		return
 	}
 
 	auth := authorization.NewAuthorizer(authorizer.NewAuthorization(ctl.store))
+	rsp := auth.Authorize(withUsername(&r, c.GetString(middleware.UsernameKey)))
+
+	core.WriteResponse(c, nil, rsp)
+}
+
+func validRequest(r *ladon.Request) bool {
+	return r.Subject != "" && r.Action != "" && r.Resource != ""
+}
+
+// withUsername 将调用方的用户名放到请求上下文中, 授权时根据用户名获取策略
+func withUsername(r *ladon.Request, username string) *ladon.Request {
 	if r.Context == nil {
 		r.Context = ladon.Context{}
 	}
+	r.Context["username"] = username
 
-	r.Context["username"] = c.GetString("username")
-	rsp := auth.Authorize(&r)
-
-	fmt.Println("rsp", rsp)
-
-	// core.WriteResponse(c, nil, rsp)
+	return r
 }
@@ -0,0 +1,136 @@
+package authorize
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/ory/ladon"
+	"github.com/stretchr/testify/assert"
+	"iam/internal/authzserver/load/cache"
+	"iam/internal/pkg/code"
+	"iam/internal/pkg/middleware"
+	authzv1 "iam/pkg/api/authz/v1"
+	"iam/pkg/core"
+)
+
+type fakeGetter map[string][]*ladon.DefaultPolicy
+
+func (f fakeGetter) GetPolicy(key string) ([]*ladon.DefaultPolicy, error) {
+	if pols, ok := f[key]; ok {
+		return pols, nil
+	}
+
+	return nil, cache.ErrPolicyNotFound
+}
+
+func newTestEngine() *gin.Engine {
+	gin.SetMode(gin.TestMode)
+
+	ctl := NewAuthorizeCtl(fakeGetter{
+		"colin": {{
+			ID:        "p1",
+			Subjects:  []string{"users:<peter|ken>"},
+			Actions:   []string{"<delete|get>"},
+			Resources: []string{"resources:articles:<.*>"},
+			Effect:    ladon.AllowAccess,
+		}},
+	}, 3)
+
+	g := gin.New()
+	g.Use(func(c *gin.Context) {
+		c.Set(middleware.UsernameKey, c.GetHeader("X-Username"))
+	})
+	g.POST("/v1/authorization", ctl.Authorize)
+	g.POST("/v1/authorizations:batch", ctl.BatchAuthorize)
+
+	return g
+}
+
+func TestAuthorize(t *testing.T) {
+	g := newTestEngine()
+
+	tests := []struct {
+		name     string
+		username string
+		body     string
+		status   int
+		allowed  bool
+		code     int
+	}{
+		{"allowed", "colin", `{"subject":"users:peter","action":"delete","resource":"resources:articles:ladon"}`, http.StatusOK, true, 0},
+		{"denied action", "colin", `{"subject":"users:peter","action":"update","resource":"resources:articles:ladon"}`, http.StatusOK, false, 0},
+		{"no policies", "tom", `{"subject":"users:peter","action":"delete","resource":"resources:articles:ladon"}`, http.StatusOK, false, 0},
+		{"malformed body", "colin", `{"subject":`, http.StatusBadRequest, false, code.ErrBind},
+		{"missing fields", "colin", `{"subject":"users:peter"}`, http.StatusBadRequest, false, code.ErrValidation},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodPost, "/v1/authorization", strings.NewReader(tt.body))
+			req.Header.Set("Content-Type", "application/json")
+			req.Header.Set("X-Username", tt.username)
+			w := httptest.NewRecorder()
+			g.ServeHTTP(w, req)
+
+			assert.Equal(t, tt.status, w.Code)
+			if tt.code != 0 {
+				var rsp core.ErrResponse
+				assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &rsp))
+				assert.Equal(t, tt.code, rsp.Code)
+
+				return
+			}
+
+			var rsp authzv1.Response
+			assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &rsp))
+			assert.Equal(t, tt.allowed, rsp.Allowed)
+			assert.Equal(t, !tt.allowed, rsp.Denied)
+		})
+	}
+}
+
+func TestBatchAuthorize(t *testing.T) {
+	g := newTestEngine()
+
+	post := func(body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPost, "/v1/authorizations:batch", strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("X-Username", "colin")
+		w := httptest.NewRecorder()
+		g.ServeHTTP(w, req)
+
+		return w
+	}
+
+	w := post(`[
+		{"subject":"users:peter","action":"delete","resource":"resources:articles:ladon"},
+		{"subject":"users:peter","action":"update","resource":"resources:articles:ladon"},
+		{"subject":"users:ken"}
+	]`)
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	var rsps []authzv1.Response
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &rsps))
+	assert.Len(t, rsps, 3)
+	assert.True(t, rsps[0].Allowed)
+	assert.True(t, rsps[1].Denied)
+	assert.True(t, rsps[2].Denied)
+	assert.NotEmpty(t, rsps[2].Error)
+
+	for body, errCode := range map[string]int{
+		`{"subject":"users:peter"}`: code.ErrBind,
+		`[]`:                        code.ErrValidation,
+		`[{},{},{},{}]`:             code.ErrValidation,
+	} {
+		w = post(body)
+		assert.Equal(t, http.StatusBadRequest, w.Code, body)
+
+		var rsp core.ErrResponse
+		assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &rsp))
+		assert.Equal(t, errCode, rsp.Code, body)
+	}
+}
@@ -0,0 +1,67 @@
+package authorize
+
+import (
+	"github.com/gin-gonic/gin"
+	"github.com/ory/ladon"
+	"iam/internal/authzserver/authorization"
+	"iam/internal/authzserver/authorization/authorizer"
+	"iam/internal/pkg/code"
+	"iam/internal/pkg/middleware"
+	authzv1 "iam/pkg/api/authz/v1"
+	"iam/pkg/core"
+	"iam/pkg/errors"
+	"sync"
+)
+
+// BatchAuthorize 对一组请求进行授权, 按请求顺序返回 authzv1.Response 数组
+// 单个请求缺少字段时只在对应的结果中返回错误, 不影响其他请求
+func (ctl *AuthorizeCtl) BatchAuthorize(c *gin.Context) {
+	var requests []*ladon.Request
+	if err := c.ShouldBindJSON(&requests); err != nil {
+		core.WriteResponse(c, errors.WithCode(code.ErrBind, "%s", err.Error()), nil)
+
+		return
+	}
+
+	if len(requests) == 0 || len(requests) > ctl.maxBatchSize {
+		core.WriteResponse(c, errors.WithCode(code.ErrValidation, "batch size must be between 1 and %d", ctl.maxBatchSize), nil)
+
+		return
+	}
+
+	// 同一批请求使用同一份策略进行授权
+	auth := authorization.NewAuthorizer(authorizer.NewAuthorization(newSnapshotGetter(ctl.store)))
+	username := c.GetString(middleware.UsernameKey)
+
+	rsps := make([]*authzv1.Response, 0, len(requests))
+	for _, r := range requests {
+		if r == nil || !validRequest(r) {
+			rsps = append(rsps, &authzv1.Response{Denied: true, Error: errMissingFields})
+			continue
+		}
+
+		rsps = append(rsps, auth.Authorize(withUsername(r, username)))
+	}
+
+	core.WriteResponse(c, nil, rsps)
+}
+
+// snapshotGetter 只获取一次策略(同一批请求属于同一个用户), 保证同一批请求的授权结果不受缓存更新影响
+type snapshotGetter struct {
+	getter   authorizer.PolicyGetter
+	once     sync.Once
+	policies []*ladon.DefaultPolicy
+	err      error
+}
+
+func newSnapshotGetter(getter authorizer.PolicyGetter) *snapshotGetter {
+	return &snapshotGetter{getter: getter}
+}
+
+func (s *snapshotGetter) GetPolicy(key string) ([]*ladon.DefaultPolicy, error) {
+	s.once.Do(func() {
+		s.policies, s.err = s.getter.GetPolicy(key)
+	})
+
+	return s.policies, s.err
+}
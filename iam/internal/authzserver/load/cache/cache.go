@@ -6,22 +6,23 @@ import (
 	"github.com/ory/ladon"
 	"github.com/pkg/errors"
 	"iam/internal/authzserver/store"
-	pb "iam/pkg/proto/apiserver/v1"
+	pb "iam/internal/pkg/proto/apiserver/v1"
 	"sync"
 )
 
 var (
 	ErrSecretNotFound = fmt.Errorf("secret not found")
-	ErrPolicyNotFound = fmt.Errorf("secret not found")
+	ErrPolicyNotFound = fmt.Errorf("policy not found")
 )
 
 // cache 实现 load 接口
 
 type Cache struct {
-	lock     *sync.RWMutex
-	cli      store.Factory
-	secrets  *ristretto.Cache
-	policies *ristretto.Cache
+	lock        *sync.RWMutex
+	cli         store.Factory
+	secrets     *ristretto.Cache
+	policies    *ristretto.Cache
+	userSecrets map[string][]string // username -> secretIDs, 按用户重新加载时删除旧的密钥
 }
 
 var (
@@ -56,10 +57,11 @@ func GetCacheInsOr(cli store.Factory) (*Cache, error) {
 			}
 
 			cacheIns = &Cache{
-				secrets:  secretCache,
-				policies: policyCache,
-				lock:     new(sync.RWMutex),
-				cli:      cli,
+				secrets:     secretCache,
+				policies:    policyCache,
+				lock:        new(sync.RWMutex),
+				cli:         cli,
+				userSecrets: make(map[string][]string),
 			}
 
 		})
@@ -75,6 +77,7 @@ func (c *Cache) GetSecret(key string) (*pb.SecretInfo, error) {
 	defer c.lock.RUnlock()
 
 	value, ok := c.secrets.Get(key)
+	recordLookup("secret", ok)
 	if !ok {
 		return nil, ErrSecretNotFound
 	}
@@ -88,6 +91,7 @@ func (c *Cache) GetPolicy(key string) ([]*ladon.DefaultPolicy, error) {
 	defer c.lock.RUnlock()
 
 	value, ok := c.policies.Get(key)
+	recordLookup("policy", ok)
 	if !ok {
 		return nil, ErrPolicyNotFound
 	}
@@ -107,8 +111,10 @@ func (c *Cache) Reload() error {
 	}
 
 	c.secrets.Clear()
+	c.userSecrets = make(map[string][]string)
 	for key, val := range secrets {
 		c.secrets.Set(key, val, 1)
+		c.userSecrets[val.Username] = append(c.userSecrets[val.Username], key)
 	}
 
 	// reload policies
@@ -122,5 +128,56 @@ func (c *Cache) Reload() error {
 		c.policies.Set(key, val, 1)
 	}
 
+	// ristretto 异步写入, 等待写入完成后再对外可见
+	c.secrets.Wait()
+	c.policies.Wait()
+
+	return nil
+}
+
+// ReloadUsers 只重新加载指定用户的密钥和策略, 由变更通知触发
+// Cache 服务不支持按用户过滤, 每次只获取一次全部的密钥和策略, 再按用户进行筛选
+func (c *Cache) ReloadUsers(usernames []string) error {
+	secrets, err := c.cli.Secrets().List()
+	if err != nil {
+		return errors.Wrap(err, "list secrets failed")
+	}
+
+	policies, err := c.cli.Policies().List()
+	if err != nil {
+		return errors.Wrap(err, "list policies failed")
+	}
+
+	users := make(map[string]bool, len(usernames))
+	for _, username := range usernames {
+		users[username] = true
+	}
+
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	for username := range users {
+		for _, key := range c.userSecrets[username] {
+			c.secrets.Del(key)
+		}
+		delete(c.userSecrets, username)
+
+		if pols := policies[username]; len(pols) > 0 {
+			c.policies.Set(username, pols, 1)
+		} else {
+			c.policies.Del(username)
+		}
+	}
+
+	for key, val := range secrets {
+		if users[val.Username] {
+			c.secrets.Set(key, val, 1)
+			c.userSecrets[val.Username] = append(c.userSecrets[val.Username], key)
+		}
+	}
+
+	c.secrets.Wait()
+	c.policies.Wait()
+
 	return nil
 }
@@ -0,0 +1,90 @@
+package cache
+
+import (
+	"github.com/dgraph-io/ristretto"
+	"github.com/ory/ladon"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"iam/internal/authzserver/store"
+	pb "iam/internal/pkg/proto/apiserver/v1"
+	"sync"
+	"testing"
+)
+
+type fakeStore struct {
+	secrets  map[string]*pb.SecretInfo
+	policies map[string][]*ladon.DefaultPolicy
+	lists    int
+}
+
+func (f *fakeStore) Secrets() store.SecretStore  { return f }
+func (f *fakeStore) Policies() store.PolicyStore { return (*fakePolicies)(f) }
+
+func (f *fakeStore) List() (map[string]*pb.SecretInfo, error) {
+	f.lists++
+	return f.secrets, nil
+}
+
+type fakePolicies fakeStore
+
+func (f *fakePolicies) List() (map[string][]*ladon.DefaultPolicy, error) {
+	f.lists++
+	return f.policies, nil
+}
+
+func newTestCache(t *testing.T, cli store.Factory) *Cache {
+	newCache := func() *ristretto.Cache {
+		c, err := ristretto.NewCache(&ristretto.Config{NumCounters: 1e3, MaxCost: 1 << 20, BufferItems: 64})
+		require.NoError(t, err)
+		return c
+	}
+
+	return &Cache{
+		lock:        new(sync.RWMutex),
+		cli:         cli,
+		secrets:     newCache(),
+		policies:    newCache(),
+		userSecrets: make(map[string][]string),
+	}
+}
+
+func TestReloadUsers(t *testing.T) {
+	cli := &fakeStore{
+		secrets: map[string]*pb.SecretInfo{
+			"s1": {SecretId: "s1", Username: "colin"},
+			"s2": {SecretId: "s2", Username: "tom"},
+		},
+		policies: map[string][]*ladon.DefaultPolicy{
+			"colin": {{ID: "p1"}},
+			"tom":   {{ID: "p2"}},
+		},
+	}
+	c := newTestCache(t, cli)
+	require.NoError(t, c.Reload())
+
+	// colin 删除了 s1 和所有策略, 新增 s3; tom 的变更未通知, 保持不变
+	cli.secrets = map[string]*pb.SecretInfo{
+		"s3": {SecretId: "s3", Username: "colin"},
+		"s4": {SecretId: "s4", Username: "tom"},
+		"s5": {SecretId: "s5", Username: "jerry"},
+	}
+	cli.policies = map[string][]*ladon.DefaultPolicy{"jerry": {{ID: "p3"}}}
+	cli.lists = 0
+
+	require.NoError(t, c.ReloadUsers([]string{"colin", "jerry"}))
+	assert.Equal(t, 2, cli.lists) // 密钥和策略各获取一次
+
+	for id, want := range map[string]bool{"s1": false, "s2": true, "s3": true, "s4": false, "s5": true} {
+		_, err := c.GetSecret(id)
+		assert.Equal(t, want, err == nil, id)
+	}
+
+	_, err := c.GetPolicy("colin")
+	assert.Equal(t, ErrPolicyNotFound, err)
+	pols, err := c.GetPolicy("tom")
+	assert.NoError(t, err)
+	assert.Equal(t, "p2", pols[0].ID)
+	pols, err = c.GetPolicy("jerry")
+	assert.NoError(t, err)
+	assert.Equal(t, "p3", pols[0].ID)
+}
@@ -0,0 +1,21 @@
+package cache
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+// cacheRequests 内存缓存的命中情况, cache 为 secret/policy, result 为 hit/miss
+var cacheRequests = promauto.NewCounterVec(prometheus.CounterOpts{
+	Name: "iam_authz_cache_requests_total",
+	Help: "Total number of authz cache lookups partitioned by cache and result.",
+}, []string{"cache", "result"})
+
+func recordLookup(cache string, hit bool) {
+	result := "miss"
+	if hit {
+		result = "hit"
+	}
+
+	cacheRequests.WithLabelValues(cache, result).Inc()
+}
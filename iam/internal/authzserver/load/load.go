@@ -2,62 +2,190 @@ package load
 
 import (
 	"context"
-	"log"
+	"encoding/json"
+	"github.com/go-redis/redis"
+	"github.com/sirupsen/logrus"
+	"iam/internal/pkg/notification"
+	"iam/pkg/cache"
 	"sync"
+	"time"
 )
 
 /*
 
- auth (更新等操作) 新增/删除/修改 --> 中间件 ()    --> todo 更新缓存/更新db
+ auth (更新等操作) 新增/删除/修改 --> 中间件 ()    --> 发布变更通知(用户名)
    \
     V 是否有修改 --> 批量操作(例如), 去掉某个用户的某些策略-->
     redis   ----->  authorization  --> 更新到内存库中
 
-   // 首次加载 xx 个， 后续新增/删除/更新
-
-
-
-
-
+   // 首次全量加载， 后续根据通知只重新加载对应用户的密钥和策略
+   // 定时全量加载 + redis 恢复后全量加载, 防止 redis 不可用期间丢失通知
 
    authorization --> 操作到内存中()
 
 */
 
+const (
+	defaultDebounce       = time.Second     // 收到通知后等待的时间, 合并这段时间内的所有通知
+	defaultResyncInterval = 5 * time.Minute // 定时全量加载的间隔
+	maxIncrementalUsers   = 100             // 合并后的用户数超过该值时直接全量加载
+	subscribeRetry        = 5 * time.Second // redis 订阅失败后重试的间隔
+)
+
 // Loader 将密钥和策略添加到被内存中
 type Loader interface {
-	Reload() error // 全量加载 (或者这里进行限制大小)
-	// 其他单值操作 -->
+	Reload() error                        // 全量加载
+	ReloadUsers(usernames []string) error // 只加载指定用户的密钥和策略
 }
 
 type Load struct {
 	ctx    context.Context
 	lock   *sync.RWMutex
 	loader Loader
+
+	queue          chan string // 待重新加载的用户名, "" 表示全量加载
+	debounce       time.Duration
+	resyncInterval time.Duration
 }
 
 // NewLoader 初始化
 func NewLoader(ctx context.Context, loader Loader) *Load {
 	return &Load{
-		ctx:    ctx,
-		lock:   new(sync.RWMutex),
-		loader: loader,
+		ctx:            ctx,
+		lock:           new(sync.RWMutex),
+		loader:         loader,
+		queue:          make(chan string, 1024),
+		debounce:       defaultDebounce,
+		resyncInterval: defaultResyncInterval,
 	}
 }
 
 func (l *Load) Start() {
 
 	// 订阅redis key进行后台加载到缓存中
-	go startPubSubLoop()
-
-	// todo 全量加载，进行优化
+	go l.startPubSubLoop()
+	go l.reloadQueueLoop()
+	go l.resyncLoop()
 
 	// 刚开始时候全量加载
 	l.DoReload()
 }
 
-func startPubSubLoop() {
+// startPubSubLoop 订阅变更通知, redis 不可用时定时重试
+func (l *Load) startPubSubLoop() {
+	cacheStore := cache.RedisCluster{}
+
+	for {
+		err := cacheStore.StartPubSubHandler(l.ctx, notification.RedisPubSubChannel, l.handleRedisEvent)
+		if l.ctx.Err() != nil {
+			return
+		}
+		if err != nil && err != cache.ErrRedisIsDown {
+			logrus.Errorf("redis subscribe %s failed, err:%v", notification.RedisPubSubChannel, err)
+		}
+
+		select {
+		case <-l.ctx.Done():
+			return
+		case <-time.After(subscribeRetry):
+		}
+	}
+}
+
+func (l *Load) handleRedisEvent(v interface{}) {
+	switch msg := v.(type) {
+	case *redis.Subscription:
+		logrus.Infof("redis %s %s", msg.Kind, msg.Channel)
+	case *redis.Message:
+		var notice notification.Notification
+		if err := json.Unmarshal([]byte(msg.Payload), &notice); err != nil {
+			logrus.Errorf("unmarshal notification %s failed, err:%v", msg.Payload, err)
+			return
+		}
+
+		logrus.Debugf("receive notification %s, username:%s", notice.Command, notice.Username)
+		l.notify(notice.Username)
+	}
+}
+
+// notify 将需要重新加载的用户加入队列
+func (l *Load) notify(username string) {
+	select {
+	case l.queue <- username:
+	case <-l.ctx.Done():
+	}
+}
+
+// reloadQueueLoop 合并 debounce 时间内的通知, 统一进行重新加载
+func (l *Load) reloadQueueLoop() {
+	var (
+		timer   <-chan time.Time
+		pending = make(map[string]struct{})
+	)
+
+	for {
+		select {
+		case <-l.ctx.Done():
+			return
+		case username := <-l.queue:
+			pending[username] = struct{}{}
+			if timer == nil {
+				timer = time.After(l.debounce)
+			}
+		case <-timer:
+			l.reload(pending)
+			pending = make(map[string]struct{})
+			timer = nil
+		}
+	}
+}
+
+func (l *Load) reload(users map[string]struct{}) {
+	if _, ok := users[""]; ok || len(users) > maxIncrementalUsers {
+		l.DoReload()
+		return
+	}
+
+	usernames := make([]string, 0, len(users))
+	for username := range users {
+		usernames = append(usernames, username)
+	}
+
+	l.lock.Lock()
+	defer l.lock.Unlock()
+
+	if err := l.loader.ReloadUsers(usernames); err != nil {
+		logrus.Errorf("failed to reload users %v: %s", usernames, err.Error())
+		return
+	}
+
+	logrus.Debugf("reload users %v succ", usernames)
+}
 
+// resyncLoop 定时全量加载, 并在 redis 恢复连接后全量加载一次, 补偿 redis 不可用期间丢失的通知
+func (l *Load) resyncLoop() {
+	resync := time.NewTicker(l.resyncInterval)
+	defer resync.Stop()
+
+	check := time.NewTicker(time.Second)
+	defer check.Stop()
+
+	connected := cache.Connected()
+	for {
+		select {
+		case <-l.ctx.Done():
+			return
+		case <-resync.C:
+			l.notify("")
+		case <-check.C:
+			up := cache.Connected()
+			if up && !connected {
+				logrus.Info("redis reconnected, resync all secrets and policies")
+				l.notify("")
+			}
+			connected = up
+		}
+	}
 }
 
 func (l *Load) DoReload() {
@@ -65,8 +193,9 @@ func (l *Load) DoReload() {
 	defer l.lock.Unlock()
 
 	if err := l.loader.Reload(); err != nil {
-		log.Printf("faild to refresh target storage: %s\n", err.Error())
+		logrus.Errorf("faild to refresh target storage: %s", err.Error())
+		return
 	}
 
-	log.Println("refresh target storage succ")
+	logrus.Info("refresh target storage succ")
 }
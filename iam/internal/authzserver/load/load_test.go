@@ -0,0 +1,97 @@
+package load
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/go-redis/redis"
+	"github.com/stretchr/testify/assert"
+)
+
+type fakeLoader struct {
+	mu      sync.Mutex
+	full    int
+	calls   int // ReloadUsers 的调用次数
+	reloads []string
+}
+
+func (f *fakeLoader) Reload() error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.full++
+
+	return nil
+}
+
+func (f *fakeLoader) ReloadUsers(usernames []string) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.calls++
+	f.reloads = append(f.reloads, usernames...)
+
+	return nil
+}
+
+func (f *fakeLoader) counts() (int, []string) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	return f.full, append([]string(nil), f.reloads...)
+}
+
+func newTestLoad(t *testing.T) (*Load, *fakeLoader) {
+	ctx, cancel := context.WithCancel(context.Background())
+	t.Cleanup(cancel)
+
+	loader := &fakeLoader{}
+	l := NewLoader(ctx, loader)
+	l.debounce = 20 * time.Millisecond
+	go l.reloadQueueLoop()
+
+	return l, loader
+}
+
+func TestDebounceReloadUser(t *testing.T) {
+	l, loader := newTestLoad(t)
+
+	for i := 0; i < 5; i++ {
+		l.handleRedisEvent(&redis.Message{Payload: `{"command":"PolicyChanged","username":"colin"}`})
+	}
+	l.handleRedisEvent(&redis.Message{Payload: `{"command":"SecretChanged","username":"tom"}`})
+	l.handleRedisEvent(&redis.Message{Payload: `invalid`})
+
+	assert.Eventually(t, func() bool {
+		_, reloads := loader.counts()
+		return len(reloads) == 2
+	}, time.Second, 5*time.Millisecond)
+
+	full, reloads := loader.counts()
+	assert.Equal(t, 0, full)
+	assert.ElementsMatch(t, []string{"colin", "tom"}, reloads)
+	assert.Equal(t, 1, loader.calls) // 同一批通知只加载一次
+}
+
+func TestFullReload(t *testing.T) {
+	l, loader := newTestLoad(t)
+
+	l.notify("colin")
+	l.notify("")
+	assert.Eventually(t, func() bool {
+		full, _ := loader.counts()
+		return full == 1
+	}, time.Second, 5*time.Millisecond)
+
+	for i := 0; i <= maxIncrementalUsers; i++ {
+		l.notify(fmt.Sprintf("user-%d", i))
+	}
+	assert.Eventually(t, func() bool {
+		full, _ := loader.counts()
+		return full == 2
+	}, time.Second, 5*time.Millisecond)
+
+	_, reloads := loader.counts()
+	assert.Empty(t, reloads)
+}
@@ -1,6 +1,7 @@
 package options
 
 import (
+	"fmt"
 	"iam/internal/authzserver/analytics"
 	genericoptions "iam/internal/pkg/options"
 	"iam/internal/pkg/server"
@@ -24,9 +25,10 @@ type Options struct {
 	Jwt              *genericoptions.JwtOptions             `json:"jwt" mapstructure:"jwt"`                  // jwt 配置
 	AnalyticsOptions *analytics.AnalyticsOptions            `json:"analytics"      mapstructure:"analytics"` // 授权日志写到redis中配置
 	RedisOptions     *genericoptions.RedisOptions           `json:"redis"          mapstructure:"redis"`
-	// Log                     *log.Options                           `json:"log"            mapstructure:"log"`
-	RPCServer string `json:"rpcserver"      mapstructure:"rpcserver"` // authz只需要调用api-server所以仅仅只需要
-	ClientCA  string `json:"client-ca-file" mapstructure:"client-ca-file"`
+	Log              *genericoptions.LogOption              `json:"log"            mapstructure:"log"`
+	RPCServer        string                                 `json:"rpcserver"      mapstructure:"rpcserver"` // authz只需要调用api-server所以仅仅只需要
+	ClientCA         string                                 `json:"client-ca-file" mapstructure:"client-ca-file"`
+	MaxBatchSize     int                                    `json:"max-batch-size" mapstructure:"max-batch-size"` // 批量授权一次最多的请求个数
 }
 
 // NewOptions 创建option的默认配置
@@ -34,6 +36,7 @@ func NewOptions() *Options {
 	return &Options{
 		RPCServer:        "127.0.0.1:8081",
 		ClientCA:         "",
+		MaxBatchSize:     100,
 		SecureServing:    genericoptions.NewSecureServing(),
 		InsecureServing:  genericoptions.NewInsecureServingOptions(),
 		Feature:          genericoptions.NewFeatureOptions(),
@@ -41,6 +44,7 @@ func NewOptions() *Options {
 		Jwt:              genericoptions.NewJwtOptions(), // 注:按正常来说这里应该是走
 		AnalyticsOptions: analytics.NewAnalyticsOptions(),
 		RedisOptions:     genericoptions.NewRedisOptions(),
+		Log:              genericoptions.NewLogOption(),
 	}
 }
 
@@ -59,11 +63,13 @@ func (o *Options) Flags() (fss pkg.NamedFlagSets) {
 	o.Jwt.AddFlags(fss.FlagSet("jwt"))
 	o.AnalyticsOptions.AddFlags(fss.FlagSet("analytics"))
 	o.RedisOptions.AddFlags(fss.FlagSet("redis"))
+	o.Log.AddFlags(fss.FlagSet("logger"))
 
 	// 其他非构建的杂项
 	fs := fss.FlagSet("misc")
 	fs.StringVar(&o.RPCServer, "rpcserver", o.RPCServer, "dial apiserver grpc addr")
-	fs.StringVar(&o.RPCServer, "client-ca-file", o.RPCServer, "dial apiserver grpc addr")
+	fs.StringVar(&o.ClientCA, "client-ca-file", o.ClientCA, "ca file used to verify apiserver grpc certificate")
+	fs.IntVar(&o.MaxBatchSize, "max-batch-size", o.MaxBatchSize, "max number of requests in one batch authorization")
 
 	return fss
 }
@@ -78,6 +84,11 @@ func (o *Options) Validate() []error {
 	errs = append(errs, o.Jwt.Validate()...)
 	errs = append(errs, o.AnalyticsOptions.Validate()...)
 	errs = append(errs, o.RedisOptions.Validate()...)
+	errs = append(errs, o.Log.Validate()...)
+
+	if o.MaxBatchSize <= 0 {
+		errs = append(errs, fmt.Errorf("--max-batch-size %d must be greater than 0", o.MaxBatchSize))
+	}
 
 	return errs
 }
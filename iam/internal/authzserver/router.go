@@ -4,37 +4,52 @@ import (
 	"github.com/gin-gonic/gin"
 	"iam/internal/authzserver/controller/v1/authorize"
 	"iam/internal/authzserver/load/cache"
+	"iam/internal/pkg/code"
+	"iam/internal/pkg/middleware/auth"
+	"iam/pkg/core"
+	"iam/pkg/errors"
+	"iam/pkg/jwks"
 	"log"
 )
 
-func initRouter(g *gin.Engine) {
+func initRouter(g *gin.Engine, maxBatchSize int, keys *jwks.KeySet, revocation *auth.Revocation) {
 	installMiddleware(g)
-	installController(g)
+	installController(g, maxBatchSize, keys, revocation)
 }
 
 func installMiddleware(g *gin.Engine) {
 	return
 }
 
-func installController(g *gin.Engine) {
+func installController(g *gin.Engine, maxBatchSize int, keys *jwks.KeySet, revocation *auth.Revocation) {
 
 	// 认证身份
-	//auth := newCacheAuth()
-	//g.NoRoute(auth.AuthFunc(), func(c *gin.Context) {
-	//	core.WriteResponse(c, errors.WithCode(code.ErrPageNotFound, "page not found."), nil)
-	//})
+	authStrategy := newCacheAuth(keys, revocation)
+	g.NoRoute(authStrategy.Auth(), func(c *gin.Context) {
+		core.WriteResponse(c, errors.WithCode(code.ErrPageNotFound, "page not found."), nil)
+	})
 
 	cacheIns, _ := cache.GetCacheInsOr(nil)
 	if cacheIns == nil {
 		log.Panicf("get nil cache instance")
 	}
 
-	apiv1 := g.Group("/v1") //  auth.AuthFunc()
+	apiv1 := g.Group("/v1", authStrategy.Auth())
 	{
-		authzController := authorize.NewAuthorizeCtl(cacheIns)
+		authzController := authorize.NewAuthorizeCtl(cacheIns, maxBatchSize)
 
 		// Router for authorization
 		apiv1.POST("/authorization", authzController.Authorize)
+
+		// gin 不支持在路径中转义 ':', 通过参数匹配 /authorizations:batch 形式的自定义方法, 参数值包含 ':'
+		apiv1.POST("/authorizations:method", func(c *gin.Context) {
+			switch c.Param("method") {
+			case ":batch":
+				authzController.BatchAuthorize(c)
+			default:
+				core.WriteResponse(c, errors.WithCode(code.ErrPageNotFound, "page not found."), nil)
+			}
+		})
 	}
 
 }
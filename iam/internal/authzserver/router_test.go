@@ -0,0 +1,114 @@
+package authzserver
+
+import (
+	"encoding/json"
+	"github.com/gin-gonic/gin"
+	"github.com/golang-jwt/jwt/v4"
+	"github.com/ory/ladon"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"iam/internal/authzserver/load/cache"
+	"iam/internal/authzserver/store"
+	"iam/internal/pkg/code"
+	pb "iam/internal/pkg/proto/apiserver/v1"
+	authzv1 "iam/pkg/api/authz/v1"
+	"iam/pkg/core"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+type fakeStore struct{}
+
+func (fakeStore) Secrets() store.SecretStore  { return fakeSecrets{} }
+func (fakeStore) Policies() store.PolicyStore { return fakePolicies{} }
+
+type fakeSecrets struct{}
+
+func (fakeSecrets) List() (map[string]*pb.SecretInfo, error) {
+	return map[string]*pb.SecretInfo{
+		"colin-secret": {SecretId: "colin-secret", Username: "colin", SecretKey: "colin-key"},
+	}, nil
+}
+
+type fakePolicies struct{}
+
+func (fakePolicies) List() (map[string][]*ladon.DefaultPolicy, error) {
+	return map[string][]*ladon.DefaultPolicy{
+		"colin": {{
+			ID:        "p1",
+			Subjects:  []string{"users:peter"},
+			Actions:   []string{"delete"},
+			Resources: []string{"resources:articles:<.*>"},
+			Effect:    ladon.AllowAccess,
+		}},
+	}, nil
+}
+
+func TestInstallController(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	cacheIns, err := cache.GetCacheInsOr(fakeStore{})
+	require.NoError(t, err)
+	require.NoError(t, cacheIns.Reload())
+
+	g := gin.New()
+	installController(g, 2, nil, nil)
+
+	token := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.MapClaims{"iat": time.Now().Unix(), "exp": time.Now().Add(time.Hour).Unix()})
+	token.Header["kid"] = "colin-secret"
+	signed, err := token.SignedString([]byte("colin-key"))
+	require.NoError(t, err)
+
+	post := func(path, body string, auth bool) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPost, path, strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		if auth {
+			req.Header.Set("Authorization", "Bearer "+signed)
+		}
+		w := httptest.NewRecorder()
+		g.ServeHTTP(w, req)
+
+		return w
+	}
+	errCode := func(w *httptest.ResponseRecorder) int {
+		var rsp core.ErrResponse
+		assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &rsp))
+		return rsp.Code
+	}
+
+	allowed := `{"subject":"users:peter","action":"delete","resource":"resources:articles:ladon"}`
+	denied := `{"subject":"users:peter","action":"update","resource":"resources:articles:ladon"}`
+
+	w := post("/v1/authorization", allowed, true)
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	var rsp authzv1.Response
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &rsp))
+	assert.True(t, rsp.Allowed)
+
+	// /authorizations:method 按 method 分发
+	w = post("/v1/authorizations:batch", "["+allowed+","+denied+"]", true)
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	var rsps []authzv1.Response
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &rsps))
+	require.Len(t, rsps, 2)
+	assert.True(t, rsps[0].Allowed)
+	assert.True(t, rsps[1].Denied)
+
+	w = post("/v1/authorizations:batch", "["+allowed+","+allowed+","+allowed+"]", true)
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	assert.Equal(t, code.ErrValidation, errCode(w))
+
+	for _, path := range []string{"/v1/authorizations:bulk", "/v1/authorizations", "/v1/authorizations:"} {
+		w = post(path, "["+allowed+"]", true)
+		assert.Equal(t, http.StatusNotFound, w.Code, path)
+		assert.Equal(t, code.ErrPageNotFound, errCode(w), path)
+	}
+
+	// 未认证时先返回 401
+	w = post("/v1/authorizations:batch", "["+allowed+"]", false)
+	assert.Equal(t, http.StatusUnauthorized, w.Code)
+	assert.Equal(t, code.ErrMissingHeader, errCode(w))
+}
@@ -2,10 +2,18 @@ package authzserver
 
 import (
 	"context"
+	"github.com/pkg/errors"
 	"iam/internal/authzserver/analytics"
 	"iam/internal/authzserver/config"
+	"iam/internal/authzserver/load"
+	"iam/internal/authzserver/load/cache"
+	"iam/internal/authzserver/store"
+	"iam/internal/authzserver/store/apiserver"
+	"iam/internal/pkg/middleware/auth"
 	genericoptions "iam/internal/pkg/options"
 	genericserver "iam/internal/pkg/server"
+	rediscache "iam/pkg/cache"
+	"iam/pkg/jwks"
 	"iam/pkg/shutdown"
 	"iam/pkg/shutdown/shutdownmanagers/posixsignal"
 	"log"
@@ -24,6 +32,9 @@ type authzServer struct {
 	redisOptions     *genericoptions.RedisOptions
 	genericAPIServer *genericserver.GenericAPIServer // 部分功能抽离到 pkg.server中，构建http服务
 	analyticsOptions *analytics.AnalyticsOptions
+	maxBatchSize     int                // 批量授权一次最多的请求个数
+	jwtKeys          *jwks.KeySet       // 验证 apiserver 签发的 token, 未配置 jwt.keys 时为空
+	revocation       *auth.Revocation   // 与 apiserver 共用 redis 中的吊销列表
 	redisCancelFunc  context.CancelFunc // redis 回调函数
 }
 
@@ -38,10 +49,22 @@ func createAuthzServer(cfg *config.Config) (*authzServer, error) {
 	gs.AddShutdownManager(posixsignal.NewPosixSignalManager()) // todo ---->
 
 	authSvc := &authzServer{
-		gs:           shutdown.New(),
-		rpcServer:    cfg.RPCServer,
-		clientCA:     cfg.ClientCA,
-		redisOptions: cfg.RedisOptions,
+		gs:               gs,
+		rpcServer:        cfg.RPCServer,
+		clientCA:         cfg.ClientCA,
+		redisOptions:     cfg.RedisOptions,
+		analyticsOptions: cfg.AnalyticsOptions,
+		maxBatchSize:     cfg.MaxBatchSize,
+		revocation:       auth.NewRevocation(&rediscache.RedisCluster{}, cfg.Jwt.RevocationFailClosed),
+	}
+
+	// 只加载公钥即可, HS256 的 jwt.key 不会下发到 authz
+	if len(cfg.Jwt.Keys) > 0 {
+		keys, err := cfg.Jwt.KeySet()
+		if err != nil {
+			return nil, errors.Wrap(err, "load jwt keys failed")
+		}
+		authSvc.jwtKeys = keys
 	}
 
 	// 加载
@@ -81,9 +104,25 @@ func buildGenericConfig(cfg *config.Config) (genericConfig *genericserver.Config
 func (svc *authzServer) PreparedServer() *preparedServer {
 
 	// 初始化redis + 初始化 将 .keep-server的策略和密钥 初始化到内存中
+	ctx, cancel := context.WithCancel(context.Background())
+	svc.redisCancelFunc = cancel
+
+	// 连接 redis, 用于订阅变更通知
+	svc.initRedisStore(ctx)
+
+	if err := svc.initialize(ctx); err != nil {
+		log.Panicf("initialize authz server failed: %s", err.Error())
+	}
+
+	// 启动授权日志的后台消费, 写入 redis 供 pump 处理
+	if svc.analyticsOptions.Enable {
+		analyticsIns := analytics.NewAnalytics(*svc.analyticsOptions)
+		analyticsIns.SetStore(&rediscache.RedisCluster{})
+		analyticsIns.Start()
+	}
 
 	// 初始化 router
-	initRouter(svc.genericAPIServer.Engine)
+	initRouter(svc.genericAPIServer.Engine, svc.maxBatchSize, svc.jwtKeys, svc.revocation)
 
 	return &preparedServer{svc}
 }
@@ -96,7 +135,9 @@ func (preSvc *preparedServer) Run() error {
 		if preSvc.analyticsOptions.Enable {
 			analytics.GetAnalytics().Stop()
 		}
-		preSvc.redisCancelFunc()
+		if preSvc.redisCancelFunc != nil {
+			preSvc.redisCancelFunc()
+		}
 
 		return nil
 	}))
@@ -110,4 +151,38 @@ func (preSvc *preparedServer) Run() error {
 	return preSvc.genericAPIServer.Run()
 }
 
-// init 密钥和策略 -->
+// initialize 通过 grpc 从 apiserver 获取密钥和策略, 加载到内存缓存中
+func (svc *authzServer) initialize(ctx context.Context) error {
+	storeIns := apiserver.GetAPIServerFactoryOrDie(svc.rpcServer, svc.clientCA)
+	store.SetClient(storeIns)
+
+	cacheIns, err := cache.GetCacheInsOr(storeIns)
+	if err != nil {
+		return errors.Wrap(err, "get cache instance failed")
+	}
+
+	load.NewLoader(ctx, cacheIns).Start()
+
+	return nil
+}
+
+// initRedisStore 初始化redis 并尝试重连
+func (svc *authzServer) initRedisStore(ctx context.Context) {
+	cfg := &rediscache.Config{
+		Host:                  svc.redisOptions.Host,
+		Port:                  svc.redisOptions.Port,
+		Addrs:                 svc.redisOptions.Addrs,
+		MasterName:            svc.redisOptions.MasterName,
+		Username:              svc.redisOptions.Username,
+		Password:              svc.redisOptions.Password,
+		Database:              svc.redisOptions.Database,
+		MaxIdle:               svc.redisOptions.MaxIdle,
+		MaxActive:             svc.redisOptions.MaxActive,
+		Timeout:               svc.redisOptions.Timeout,
+		EnableCluster:         svc.redisOptions.EnableCluster,
+		UseSSL:                svc.redisOptions.UseSSL,
+		SSLInsecureSkipVerify: svc.redisOptions.SSLInsecureSkipVerify,
+	}
+
+	go rediscache.ConnectToRedis(ctx, cfg)
+}
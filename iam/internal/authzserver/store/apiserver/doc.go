@@ -1,3 +1,3 @@
 package apiserver
 
-// 实现 store.Factory 中的所有接口
+// 实现 store.Factory 中的所有接口, 通过 apiserver 的 grpc Cache 服务分页获取全部的密钥和策略
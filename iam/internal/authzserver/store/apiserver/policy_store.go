@@ -1,8 +1,64 @@
 package apiserver
 
+import (
+	"context"
+	"encoding/json"
+	"github.com/ory/ladon"
+	"github.com/pkg/errors"
+	"github.com/sirupsen/logrus"
+	pb "iam/internal/pkg/proto/apiserver/v1"
+)
+
 // 实现获取 policy_store 的方法
 
-// List 获取所有用户的所有策略
-//func (ps *policyStore) List() (map[string][]*ladon.DefaultPolicy, error) {
-//
-//}
+type policies struct {
+	cli pb.CacheClient
+}
+
+func newPolicies(ds *datastore) *policies {
+	return &policies{ds.cli}
+}
+
+// List 获取所有用户的所有策略, 返回以 username 为 key 的 map
+func (p *policies) List() (map[string][]*ladon.DefaultPolicy, error) {
+	pols := make(map[string][]*ladon.DefaultPolicy)
+
+	var offset int64
+	for {
+		var resp *pb.ListPoliciesResponse
+		req := &pb.ListPoliciesRequest{
+			Offset: pointerInt64(offset),
+			Limit:  pointerInt64(pageSize),
+		}
+
+		err := withRetry("list policies", func() (err error) {
+			ctx, cancel := context.WithTimeout(context.Background(), requestTimeout)
+			defer cancel()
+
+			resp, err = p.cli.ListPolicies(ctx, req)
+			return err
+		})
+		if err != nil {
+			return nil, errors.Wrap(err, "list policies failed")
+		}
+
+		for _, v := range resp.Items {
+			var policy ladon.DefaultPolicy
+
+			// 解析失败的策略进行跳过, 不影响其他策略
+			if err := json.Unmarshal([]byte(v.PolicyShadow), &policy); err != nil {
+				logrus.Warnf("failed to load policy for %s, name:%s, err:%v", v.Username, v.Name, err)
+				continue
+			}
+
+			pols[v.Username] = append(pols[v.Username], &policy)
+		}
+
+		offset += int64(len(resp.Items))
+		if len(resp.Items) == 0 || offset >= resp.TotalCount {
+			break
+		}
+	}
+
+	return pols, nil
+}
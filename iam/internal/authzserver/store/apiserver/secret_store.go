@@ -1,3 +1,57 @@
 package apiserver
 
+import (
+	"context"
+	"github.com/pkg/errors"
+	pb "iam/internal/pkg/proto/apiserver/v1"
+)
+
 // 实现获取 secret_store 的方法
+
+type secrets struct {
+	cli pb.CacheClient
+}
+
+func newSecrets(ds *datastore) *secrets {
+	return &secrets{ds.cli}
+}
+
+// List 分页获取全部的密钥, 返回以 secretID 为 key 的 map (即 jwt 中的 kid)
+func (s *secrets) List() (map[string]*pb.SecretInfo, error) {
+	secrets := make(map[string]*pb.SecretInfo)
+
+	var offset int64
+	for {
+		var resp *pb.ListSecretsResponse
+		req := &pb.ListSecretsRequest{
+			Offset: pointerInt64(offset),
+			Limit:  pointerInt64(pageSize),
+		}
+
+		err := withRetry("list secrets", func() (err error) {
+			ctx, cancel := context.WithTimeout(context.Background(), requestTimeout)
+			defer cancel()
+
+			resp, err = s.cli.ListSecrets(ctx, req)
+			return err
+		})
+		if err != nil {
+			return nil, errors.Wrap(err, "list secrets failed")
+		}
+
+		for _, v := range resp.Items {
+			secrets[v.SecretId] = v
+		}
+
+		offset += int64(len(resp.Items))
+		if len(resp.Items) == 0 || offset >= resp.TotalCount {
+			break
+		}
+	}
+
+	return secrets, nil
+}
+
+func pointerInt64(v int64) *int64 {
+	return &v
+}
@@ -0,0 +1,100 @@
+package apiserver
+
+import (
+	"crypto/tls"
+	"github.com/sirupsen/logrus"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials"
+	"iam/internal/authzserver/store"
+	pb "iam/internal/pkg/proto/apiserver/v1"
+	"sync"
+	"time"
+)
+
+const (
+	pageSize = 1000 // 每次分页获取的个数
+
+	// apiserver 不可用时(例如 authz 先于 apiserver 启动)进行退避重试
+	maxRetries     = 5
+	initialBackoff = time.Second
+	maxBackoff     = 30 * time.Second
+
+	requestTimeout = 10 * time.Second // 单次请求的超时时间, 超时后由 withRetry 重试
+)
+
+// datastore 实现 store.Factory
+type datastore struct {
+	cli pb.CacheClient
+}
+
+func (ds *datastore) Secrets() store.SecretStore {
+	return newSecrets(ds)
+}
+
+func (ds *datastore) Policies() store.PolicyStore {
+	return newPolicies(ds)
+}
+
+var (
+	apiServerFactory store.Factory
+	once             sync.Once
+)
+
+// GetAPIServerFactoryOrDie 根据 rpcserver 地址和 ca 证书创建 grpc client, clientCA 为空时使用系统证书
+func GetAPIServerFactoryOrDie(address string, clientCA string) store.Factory {
+	once.Do(func() {
+		var (
+			err   error
+			conn  *grpc.ClientConn
+			creds credentials.TransportCredentials
+		)
+
+		if clientCA != "" {
+			creds, err = credentials.NewClientTLSFromFile(clientCA, "")
+			if err != nil {
+				logrus.Panicf("credentials.NewClientTLSFromFile err: %v", err)
+			}
+		} else {
+			creds = credentials.NewTLS(&tls.Config{})
+		}
+
+		// 非阻塞连接, apiserver 未启动时由调用方进行重试
+		conn, err = grpc.Dial(address, grpc.WithTransportCredentials(creds))
+		if err != nil {
+			logrus.Panicf("connect to grpc server %s failed: %v", address, err)
+		}
+
+		apiServerFactory = &datastore{pb.NewCacheClient(conn)}
+		logrus.Infof("connected to grpc server, address: %s", address)
+	})
+
+	if apiServerFactory == nil {
+		logrus.Panicf("failed to get apiserver store factory")
+	}
+
+	return apiServerFactory
+}
+
+var sleep = time.Sleep
+
+// withRetry 执行 fn, 失败时按照指数退避进行重试
+func withRetry(name string, fn func() error) error {
+	var (
+		err     error
+		backoff = initialBackoff
+	)
+
+	for i := 0; ; i++ {
+		if err = fn(); err == nil || i >= maxRetries {
+			return err
+		}
+
+		logrus.Warnf("%s failed, retry after %s (%d/%d), err:%v", name, backoff, i+1, maxRetries, err)
+		sleep(backoff)
+
+		backoff *= 2
+		if backoff > maxBackoff {
+			backoff = maxBackoff
+		}
+	}
+}
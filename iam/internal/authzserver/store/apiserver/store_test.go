@@ -0,0 +1,96 @@
+package apiserver
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/grpc"
+	pb "iam/internal/pkg/proto/apiserver/v1"
+)
+
+type fakeCacheClient struct {
+	secrets  []*pb.SecretInfo
+	policies []*pb.PolicyInfo
+	failures int // 前 n 次调用返回错误
+	calls    int
+
+	noDeadline int // 未设置超时的调用次数
+}
+
+func (f *fakeCacheClient) call(ctx context.Context) {
+	f.calls++
+	if _, ok := ctx.Deadline(); !ok {
+		f.noDeadline++
+	}
+}
+
+func (f *fakeCacheClient) ListSecrets(ctx context.Context, in *pb.ListSecretsRequest, _ ...grpc.CallOption) (*pb.ListSecretsResponse, error) {
+	f.call(ctx)
+	if f.calls <= f.failures {
+		return nil, errors.New("connection refused")
+	}
+	start, end := page(len(f.secrets), in.GetOffset(), in.GetLimit())
+
+	return &pb.ListSecretsResponse{TotalCount: int64(len(f.secrets)), Items: f.secrets[start:end]}, nil
+}
+
+func (f *fakeCacheClient) ListPolicies(ctx context.Context, in *pb.ListPoliciesRequest, _ ...grpc.CallOption) (*pb.ListPoliciesResponse, error) {
+	f.call(ctx)
+	start, end := page(len(f.policies), in.GetOffset(), in.GetLimit())
+
+	return &pb.ListPoliciesResponse{TotalCount: int64(len(f.policies)), Items: f.policies[start:end]}, nil
+}
+
+func page(total int, offset, limit int64) (int, int) {
+	start := int(offset)
+	if start > total {
+		start = total
+	}
+	end := start + int(limit)
+	if end > total {
+		end = total
+	}
+
+	return start, end
+}
+
+func TestSecretsList(t *testing.T) {
+	sleep = func(time.Duration) {}
+	defer func() { sleep = time.Sleep }()
+
+	cli := &fakeCacheClient{failures: 2}
+	for i := 0; i < pageSize+5; i++ {
+		cli.secrets = append(cli.secrets, &pb.SecretInfo{Username: "colin", SecretId: fmt.Sprintf("id-%d", i)})
+	}
+
+	secrets, err := newSecrets(&datastore{cli}).List()
+	assert.NoError(t, err)
+	assert.Len(t, secrets, pageSize+5)
+	assert.Equal(t, "id-3", secrets["id-3"].SecretId)
+	assert.Equal(t, 4, cli.calls) // 两次失败 + 两页
+	assert.Zero(t, cli.noDeadline)
+
+	cli = &fakeCacheClient{failures: maxRetries + 1}
+	_, err = newSecrets(&datastore{cli}).List()
+	assert.Error(t, err)
+}
+
+func TestPoliciesList(t *testing.T) {
+	cli := &fakeCacheClient{policies: []*pb.PolicyInfo{
+		{Name: "p1", Username: "colin", PolicyShadow: `{"id":"p1","effect":"allow","subjects":["users:colin"]}`},
+		{Name: "p2", Username: "colin", PolicyShadow: `{"id":"p2","effect":"deny"}`},
+		{Name: "p3", Username: "tom", PolicyShadow: `not json`},
+	}}
+
+	pols, err := newPolicies(&datastore{cli}).List()
+	assert.NoError(t, err)
+	assert.Len(t, pols, 1)
+	assert.Len(t, pols["colin"], 2)
+	assert.Equal(t, "allow", pols["colin"][0].Effect)
+	assert.Equal(t, "p2", pols["colin"][1].ID)
+	assert.Zero(t, cli.noDeadline)
+}
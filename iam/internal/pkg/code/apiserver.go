@@ -0,0 +1,49 @@
+package code
+
+import "net/http"
+
+// 用户错误码: 1100xx
+const (
+	// ErrUserNotFound - 404: User not found.
+	ErrUserNotFound int = iota + 110001
+
+	// ErrUserAlreadyExist - 400: User already exist.
+	ErrUserAlreadyExist
+
+	// ErrIdentityNotFound - 404: Identity not found.
+	ErrIdentityNotFound
+)
+
+// 密钥错误码: 1101xx
+const (
+	// ErrReachMaxCount - 400: Secret reach the max count.
+	ErrReachMaxCount int = iota + 110101
+
+	// ErrSecretNotFound - 404: Secret not found.
+	ErrSecretNotFound
+)
+
+// 策略错误码: 1102xx
+const (
+	// ErrPolicyNotFound - 404: Policy not found.
+	ErrPolicyNotFound int = iota + 110201
+)
+
+// OIDC 客户端错误码: 1103xx
+const (
+	// ErrClientNotFound - 404: Client not found.
+	ErrClientNotFound int = iota + 110301
+)
+
+func init() {
+	register(ErrUserNotFound, http.StatusNotFound, "User not found")
+	register(ErrUserAlreadyExist, http.StatusBadRequest, "User already exist")
+	register(ErrIdentityNotFound, http.StatusNotFound, "Identity not found")
+
+	register(ErrReachMaxCount, http.StatusBadRequest, "Secret reach the max count")
+	register(ErrSecretNotFound, http.StatusNotFound, "Secret not found")
+
+	register(ErrPolicyNotFound, http.StatusNotFound, "Policy not found")
+
+	register(ErrClientNotFound, http.StatusNotFound, "Client not found")
+}
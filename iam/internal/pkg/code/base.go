@@ -0,0 +1,77 @@
+package code
+
+import "net/http"
+
+// 通用错误码: 1000xx
+const (
+	// ErrSuccess - 200: OK.
+	ErrSuccess int = iota + 100001
+
+	// ErrUnknown - 500: Internal server error.
+	ErrUnknown
+
+	// ErrBind - 400: Error occurred while binding the request body to the struct.
+	ErrBind
+
+	// ErrValidation - 400: Validation failed.
+	ErrValidation
+
+	// ErrTokenInvalid - 401: Token invalid.
+	ErrTokenInvalid
+
+	// ErrPageNotFound - 404: Page not found.
+	ErrPageNotFound
+)
+
+// 数据库错误码: 1001xx
+const (
+	// ErrDatabase - 500: Database error.
+	ErrDatabase int = iota + 100101
+)
+
+// 认证授权错误码: 1002xx
+const (
+	// ErrEncrypt - 401: Error occurred while encrypting the user password.
+	ErrEncrypt int = iota + 100201
+
+	// ErrSignatureInvalid - 401: Signature is invalid.
+	ErrSignatureInvalid
+
+	// ErrExpired - 401: Token expired.
+	ErrExpired
+
+	// ErrInvalidAuthHeader - 401: Invalid authorization header.
+	ErrInvalidAuthHeader
+
+	// ErrMissingHeader - 401: The `Authorization` header was empty.
+	ErrMissingHeader
+
+	// ErrPasswordIncorrect - 401: Password was incorrect.
+	ErrPasswordIncorrect
+
+	// ErrPermissionDenied - 403: Permission denied.
+	ErrPermissionDenied
+
+	// ErrTokenRevoked - 401: Token has been revoked.
+	ErrTokenRevoked
+)
+
+func init() {
+	register(ErrSuccess, http.StatusOK, "OK")
+	register(ErrUnknown, http.StatusInternalServerError, "Internal server error")
+	register(ErrBind, http.StatusBadRequest, "Error occurred while binding the request body to the struct")
+	register(ErrValidation, http.StatusBadRequest, "Validation failed")
+	register(ErrTokenInvalid, http.StatusUnauthorized, "Token invalid")
+	register(ErrPageNotFound, http.StatusNotFound, "Page not found")
+
+	register(ErrDatabase, http.StatusInternalServerError, "Database error")
+
+	register(ErrEncrypt, http.StatusUnauthorized, "Error occurred while encrypting the user password")
+	register(ErrSignatureInvalid, http.StatusUnauthorized, "Signature is invalid")
+	register(ErrExpired, http.StatusUnauthorized, "Token expired")
+	register(ErrInvalidAuthHeader, http.StatusUnauthorized, "Invalid authorization header")
+	register(ErrMissingHeader, http.StatusUnauthorized, "The `Authorization` header was empty")
+	register(ErrPasswordIncorrect, http.StatusUnauthorized, "Password was incorrect")
+	register(ErrPermissionDenied, http.StatusForbidden, "Permission denied")
+	register(ErrTokenRevoked, http.StatusUnauthorized, "Token has been revoked")
+}
@@ -0,0 +1,51 @@
+package code
+
+import (
+	"iam/pkg/errors"
+	"net/http"
+)
+
+// ErrCode 实现 errors.Coder 接口
+type ErrCode struct {
+	C    int    // 错误码
+	HTTP int    // 对应的 http 状态码
+	Ext  string // 对外展示的错误信息
+	Ref  string // 参考文档
+}
+
+var _ errors.Coder = &ErrCode{}
+
+func (coder ErrCode) Code() int {
+	return coder.C
+}
+
+func (coder ErrCode) Msg() string {
+	return coder.Ext
+}
+
+func (coder ErrCode) Reference() string {
+	return coder.Ref
+}
+
+func (coder ErrCode) HTTPStatus() int {
+	if coder.HTTP == 0 {
+		return http.StatusInternalServerError
+	}
+
+	return coder.HTTP
+}
+
+// register 注册错误码, 重复注册时 panic
+func register(code int, httpStatus int, message string, refs ...string) {
+	var reference string
+	if len(refs) > 0 {
+		reference = refs[0]
+	}
+
+	errors.MustRegister(&ErrCode{
+		C:    code,
+		HTTP: httpStatus,
+		Ext:  message,
+		Ref:  reference,
+	})
+}
@@ -0,0 +1,3 @@
+// Package code 定义 iam 中使用的错误码, 通过 errors.MustRegister 进行注册
+// 通用错误码 1000xx - 1002xx, apiserver 错误码 1100xx - 1102xx
+package code
@@ -0,0 +1,251 @@
+package auth
+
+import (
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"github.com/gin-gonic/gin"
+	"github.com/golang-jwt/jwt/v4"
+	"github.com/spf13/viper"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"iam/internal/pkg/code"
+	"iam/internal/pkg/middleware"
+	metav1 "iam/pkg/api/meta/v1"
+	"iam/pkg/api/user"
+	"iam/pkg/core"
+	"iam/pkg/errors"
+	"iam/pkg/jwks"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+type authCase struct {
+	name       string
+	header     string
+	wantStatus int
+	wantCode   int    // 失败时的错误码
+	wantUser   string // 成功时 context 中的用户名
+	wantMethod string // 成功时 context 中的认证方式
+}
+
+func basicHeader(username, password string) string {
+	return "Basic " + base64.StdEncoding.EncodeToString([]byte(username+":"+password))
+}
+
+func newBasicStrategy() *BasicStrategy {
+	return NewBasic(func(username, password string) bool {
+		return username == "colin" && password == "p:ss"
+	})
+}
+
+func newTestJWTStrategy(t *testing.T) *JWTStrategy {
+	keys, err := jwks.NewHMAC([]byte("test-key"))
+	require.NoError(t, err)
+
+	return newTestJWTStrategyWithKeys(keys)
+}
+
+func newTestJWTStrategyWithKeys(keys *jwks.KeySet) *JWTStrategy {
+	viper.Set("jwt.realm", "iam jwt")
+	viper.Set("jwt.timeout", time.Hour)
+	viper.Set("jwt.max-refresh", time.Hour)
+
+	return NewJWTStrategy(keys, nil)
+}
+
+// newRotatingKeys old 为轮换前的 RSA 密钥, new 为当前签发的 Ed25519 密钥, 另有一个只有公钥的 ES256 密钥
+func newRotatingKeys(t *testing.T) []*jwks.Key {
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+	_, edKey, err := ed25519.GenerateKey(rand.Reader)
+	require.NoError(t, err)
+	ecKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+
+	oldKey, err := jwks.NewKey("old", rsaKey)
+	require.NoError(t, err)
+	newKey, err := jwks.NewKey("new", edKey)
+	require.NoError(t, err)
+	ecPublic, err := jwks.NewKey("ec", &ecKey.PublicKey)
+	require.NoError(t, err)
+
+	return []*jwks.Key{oldKey, newKey, ecPublic}
+}
+
+func newKeySet(t *testing.T, signingKid string, keys []*jwks.Key) *jwks.KeySet {
+	ks, err := jwks.New(signingKid, keys...)
+	require.NoError(t, err)
+
+	return ks
+}
+
+func jwtToken(t *testing.T, strategy *JWTStrategy, username string) string {
+	token, _, err := strategy.TokenGenerator(&user.User{ObjectMeta: metav1.ObjectMeta{Name: username}})
+	require.NoError(t, err)
+
+	return token
+}
+
+func cacheToken(t *testing.T, kid, key string, exp time.Time) string {
+	token := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.MapClaims{
+		"exp": exp.Unix(),
+		"iat": time.Now().Unix(),
+	})
+	token.Header["kid"] = kid
+
+	signed, err := token.SignedString([]byte(key))
+	require.NoError(t, err)
+
+	return signed
+}
+
+func runAuthCases(t *testing.T, strategy middleware.AuthStrategy, cases []authCase) {
+	gin.SetMode(gin.TestMode)
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			g := gin.New()
+
+			var gotUser, gotMethod string
+			g.GET("/", strategy.Auth(), func(c *gin.Context) {
+				gotUser = c.GetString(middleware.UsernameKey)
+				gotMethod = c.GetString(middleware.AuthMethodKey)
+				c.Status(http.StatusOK)
+			})
+
+			req := httptest.NewRequest(http.MethodGet, "/", nil)
+			if tc.header != "" {
+				req.Header.Set("Authorization", tc.header)
+			}
+			w := httptest.NewRecorder()
+			g.ServeHTTP(w, req)
+
+			assert.Equal(t, tc.wantStatus, w.Code)
+			if tc.wantStatus == http.StatusOK {
+				assert.Equal(t, tc.wantUser, gotUser)
+				assert.Equal(t, tc.wantMethod, gotMethod)
+				return
+			}
+
+			var rsp core.ErrResponse
+			require.NoError(t, json.Unmarshal(w.Body.Bytes(), &rsp))
+			assert.Equal(t, tc.wantCode, rsp.Code)
+			if tc.wantStatus == http.StatusUnauthorized {
+				assert.NotEmpty(t, w.Header().Values("WWW-Authenticate"))
+			}
+		})
+	}
+}
+
+func TestBasicStrategy(t *testing.T) {
+	runAuthCases(t, newBasicStrategy(), []authCase{
+		{name: "ok", header: basicHeader("colin", "p:ss"), wantStatus: http.StatusOK, wantUser: "colin", wantMethod: AuthMethodBasic},
+		{name: "wrong password", header: basicHeader("colin", "bad"), wantStatus: http.StatusUnauthorized, wantCode: code.ErrPasswordIncorrect},
+		{name: "missing header", wantStatus: http.StatusUnauthorized, wantCode: code.ErrMissingHeader},
+		{name: "not base64", header: "Basic !!!", wantStatus: http.StatusUnauthorized, wantCode: code.ErrInvalidAuthHeader},
+		{name: "no colon", header: "Basic " + base64.StdEncoding.EncodeToString([]byte("colin")), wantStatus: http.StatusUnauthorized, wantCode: code.ErrInvalidAuthHeader},
+		{name: "wrong scheme", header: "Bearer xxx", wantStatus: http.StatusUnauthorized, wantCode: code.ErrInvalidAuthHeader},
+	})
+}
+
+// signedWith 使用 keys 签发带有额外 claim 的 token, 模拟 OIDC 签发的 token
+func signedWith(t *testing.T, keys *jwks.KeySet, extra jwt.MapClaims) string {
+	claims := jwt.MapClaims{"sub": "colin", "iat": time.Now().Unix(), "exp": time.Now().Add(time.Hour).Unix()}
+	for key, value := range extra {
+		claims[key] = value
+	}
+
+	token, err := keys.Sign(claims)
+	require.NoError(t, err)
+	return token
+}
+
+func TestJWTStrategy(t *testing.T) {
+	strategy := newTestJWTStrategy(t)
+	keys := strategy.keys
+
+	runAuthCases(t, strategy, []authCase{
+		{name: "ok", header: "Bearer " + jwtToken(t, strategy, "colin"), wantStatus: http.StatusOK, wantUser: "colin", wantMethod: AuthMethodJWT},
+		{name: "missing header", wantStatus: http.StatusUnauthorized, wantCode: code.ErrMissingHeader},
+		{name: "bad token", header: "Bearer not.a.token", wantStatus: http.StatusUnauthorized, wantCode: code.ErrTokenInvalid},
+		{name: "other key", header: "Bearer " + cacheToken(t, "kid", "other-key", time.Now().Add(time.Hour)), wantStatus: http.StatusUnauthorized, wantCode: code.ErrTokenInvalid},
+		{name: "with aud", header: "Bearer " + signedWith(t, keys, jwt.MapClaims{"aud": "web-app"}), wantStatus: http.StatusUnauthorized, wantCode: code.ErrTokenInvalid},
+		{name: "with client_id", header: "Bearer " + signedWith(t, keys, jwt.MapClaims{"client_id": "colin"}), wantStatus: http.StatusUnauthorized, wantCode: code.ErrTokenInvalid},
+		{name: "with typ", header: "Bearer " + signedWith(t, keys, jwt.MapClaims{"typ": "session"}), wantStatus: http.StatusUnauthorized, wantCode: code.ErrTokenInvalid},
+	})
+}
+
+func TestJWTStrategyKeyRotation(t *testing.T) {
+	keys := newRotatingKeys(t)
+	oldToken := jwtToken(t, newTestJWTStrategyWithKeys(newKeySet(t, "old", keys)), "colin")
+
+	// 轮换后使用新的密钥签发, 旧密钥签发的 token 在有效期内仍然可用
+	strategy := newTestJWTStrategyWithKeys(newKeySet(t, "new", keys))
+	newToken := jwtToken(t, strategy, "tom")
+
+	parsed, _, err := new(jwt.Parser).ParseUnverified(newToken, jwt.MapClaims{})
+	require.NoError(t, err)
+	assert.Equal(t, "new", parsed.Header["kid"])
+	assert.Equal(t, "EdDSA", parsed.Method.Alg())
+
+	runAuthCases(t, strategy, []authCase{
+		{name: "new key", header: "Bearer " + newToken, wantStatus: http.StatusOK, wantUser: "tom", wantMethod: AuthMethodJWT},
+		{name: "old key", header: "Bearer " + oldToken, wantStatus: http.StatusOK, wantUser: "colin", wantMethod: AuthMethodJWT},
+		{name: "other keys", header: "Bearer " + jwtToken(t, newTestJWTStrategyWithKeys(newKeySet(t, "old", newRotatingKeys(t))), "colin"), wantStatus: http.StatusUnauthorized, wantCode: code.ErrTokenInvalid},
+		{name: "hmac token", header: "Bearer " + jwtToken(t, newTestJWTStrategy(t), "colin"), wantStatus: http.StatusUnauthorized, wantCode: code.ErrTokenInvalid},
+	})
+}
+
+func TestCacheStrategy(t *testing.T) {
+	secrets := map[string]Secret{
+		"kid-ok":      {Username: "colin", ID: "kid-ok", Key: "key-ok"},
+		"kid-expired": {Username: "colin", ID: "kid-expired", Key: "key-expired", Expires: time.Now().Add(-time.Hour).Unix()},
+		"kid-future":  {Username: "tom", ID: "kid-future", Key: "key-future", Expires: time.Now().Add(time.Hour).Unix()},
+	}
+	keys := newKeySet(t, "new", newRotatingKeys(t))
+	strategy := NewCacheStrategy(func(kid string) (Secret, error) {
+		secret, ok := secrets[kid]
+		if !ok {
+			return Secret{}, errors.New("secret not found")
+		}
+		return secret, nil
+	}, keys, nil)
+	issued := jwtToken(t, newTestJWTStrategyWithKeys(keys), "jerry")
+
+	future := time.Now().Add(time.Hour)
+	runAuthCases(t, &strategy, []authCase{
+		{name: "never expires", header: "Bearer " + cacheToken(t, "kid-ok", "key-ok", future), wantStatus: http.StatusOK, wantUser: "colin", wantMethod: AuthMethodCache},
+		{name: "expires in future", header: "Bearer " + cacheToken(t, "kid-future", "key-future", future), wantStatus: http.StatusOK, wantUser: "tom", wantMethod: AuthMethodCache},
+		{name: "secret expired", header: "Bearer " + cacheToken(t, "kid-expired", "key-expired", future), wantStatus: http.StatusUnauthorized, wantCode: code.ErrExpired},
+		{name: "token expired", header: "Bearer " + cacheToken(t, "kid-ok", "key-ok", time.Now().Add(-time.Minute)), wantStatus: http.StatusUnauthorized, wantCode: code.ErrExpired},
+		{name: "wrong key", header: "Bearer " + cacheToken(t, "kid-ok", "bad-key", future), wantStatus: http.StatusUnauthorized, wantCode: code.ErrSignatureInvalid},
+		{name: "unknown kid", header: "Bearer " + cacheToken(t, "kid-none", "key-ok", future), wantStatus: http.StatusUnauthorized, wantCode: code.ErrSignatureInvalid},
+		{name: "missing header", wantStatus: http.StatusUnauthorized, wantCode: code.ErrMissingHeader},
+		{name: "wrong scheme", header: basicHeader("colin", "p:ss"), wantStatus: http.StatusUnauthorized, wantCode: code.ErrInvalidAuthHeader},
+		{name: "apiserver token", header: "Bearer " + issued, wantStatus: http.StatusOK, wantUser: "jerry", wantMethod: AuthMethodJWT},
+		{name: "oidc access token", header: "Bearer " + signedWith(t, keys, jwt.MapClaims{"aud": "job", "client_id": "job", "typ": "access_token"}), wantStatus: http.StatusUnauthorized, wantCode: code.ErrTokenInvalid},
+		{name: "apiserver token other keys", header: "Bearer " + jwtToken(t, newTestJWTStrategyWithKeys(newKeySet(t, "new", newRotatingKeys(t))), "jerry"), wantStatus: http.StatusUnauthorized, wantCode: code.ErrSignatureInvalid},
+	})
+}
+
+func TestAutoStrategy(t *testing.T) {
+	jwtStrategy := newTestJWTStrategy(t)
+	auto := NewAutoStrategy(newBasicStrategy(), jwtStrategy)
+
+	runAuthCases(t, auto, []authCase{
+		{name: "basic", header: basicHeader("colin", "p:ss"), wantStatus: http.StatusOK, wantUser: "colin", wantMethod: AuthMethodBasic},
+		{name: "bearer", header: "Bearer " + jwtToken(t, jwtStrategy, "tom"), wantStatus: http.StatusOK, wantUser: "tom", wantMethod: AuthMethodJWT},
+		{name: "basic wrong password", header: basicHeader("colin", "bad"), wantStatus: http.StatusUnauthorized, wantCode: code.ErrPasswordIncorrect},
+		{name: "bearer bad token", header: "Bearer bad", wantStatus: http.StatusUnauthorized, wantCode: code.ErrTokenInvalid},
+		{name: "missing header", wantStatus: http.StatusUnauthorized, wantCode: code.ErrMissingHeader},
+		{name: "no credentials", header: "Basic", wantStatus: http.StatusUnauthorized, wantCode: code.ErrInvalidAuthHeader},
+		{name: "unsupported scheme", header: "Digest xxx", wantStatus: http.StatusUnauthorized, wantCode: code.ErrInvalidAuthHeader},
+	})
+}
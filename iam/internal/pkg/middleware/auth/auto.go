@@ -1,14 +1,25 @@
 package auth
 
 import (
+	"fmt"
 	"github.com/gin-gonic/gin"
+	"iam/internal/pkg/code"
 	"iam/internal/pkg/middleware"
+	"iam/pkg/core"
+	"iam/pkg/errors"
 	"strings"
 )
 
 const (
 	AuthorizationBasic  = "Basic"
 	AuthorizationBearer = "Bearer"
+
+	// 写入 middleware.AuthMethodKey 的认证方式
+	AuthMethodBasic = "basic"
+	AuthMethodJWT   = "jwt"
+	AuthMethodCache = "cache"
+
+	authRealm = "iam"
 )
 
 // 根据 Authorization 头部为 Basic和Bearer 来进行分别使用对应的验证方式
@@ -31,35 +42,53 @@ func (auto *AutoStrategy) Auth() gin.HandlerFunc {
 
 	return func(c *gin.Context) {
 
-		auth := c.Request.Header.Get("Authorization")
-		auths := strings.Split(auth, " ")
-		if len(auths) == 0 {
-			// Authorization key 不符合条件
+		header := c.Request.Header.Get("Authorization")
+		if header == "" {
+			abortUnauthorized(c, errors.WithCode(code.ErrMissingHeader, "the `Authorization` header was empty"),
+				AuthorizationBasic, AuthorizationBearer)
+			return
+		}
 
-			c.Abort()
+		auths := strings.SplitN(header, " ", 2)
+		if len(auths) != 2 {
+			abortUnauthorized(c, errors.WithCode(code.ErrInvalidAuthHeader, "Authorization header format must be Basic or Bearer"),
+				AuthorizationBasic, AuthorizationBearer)
 			return
 		}
 
 		authPolicy := &middleware.AuthPolicy{}
-		switch auths[0] {
-
-		case AuthorizationBasic:
+		switch {
+		case strings.EqualFold(auths[0], AuthorizationBasic):
 			authPolicy.SetPolicy(auto.basic)
 
-		case AuthorizationBearer:
+		case strings.EqualFold(auths[0], AuthorizationBearer):
 			authPolicy.SetPolicy(auto.jwt)
 
 		default:
 			// 未支持的 Authorization 类型
-
-			c.Abort()
+			abortUnauthorized(c, errors.WithCode(code.ErrInvalidAuthHeader, "unsupported authorization type %s", auths[0]),
+				AuthorizationBasic, AuthorizationBearer)
 			return
 		}
 
-		// 执行
-		authPolicy.AuthFunc()
-		c.Next()
+		// 执行对应策略, 由策略决定是否 Abort
+		authPolicy.AuthFunc()(c)
+	}
+
+}
 
+// abortUnauthorized 设置 WWW-Authenticate 头部, 按错误码返回并终止后续处理
+func abortUnauthorized(c *gin.Context, err error, schemes ...string) {
+	for _, scheme := range schemes {
+		c.Writer.Header().Add("WWW-Authenticate", fmt.Sprintf("%s realm=%q", scheme, authRealm))
 	}
 
+	core.WriteResponse(c, err, nil)
+	c.Abort()
+}
+
+// setAuthenticated 将认证通过的用户名和认证方式写入 context
+func setAuthenticated(c *gin.Context, username, method string) {
+	c.Set(middleware.UsernameKey, username)
+	c.Set(middleware.AuthMethodKey, method)
 }
@@ -3,6 +3,8 @@ package auth
 import (
 	"encoding/base64"
 	"github.com/gin-gonic/gin"
+	"iam/internal/pkg/code"
+	"iam/pkg/errors"
 	"strings"
 )
 
@@ -22,52 +24,45 @@ func (basic *BasicStrategy) Auth() gin.HandlerFunc {
 	return func(c *gin.Context) {
 
 		basicStr := c.Request.Header.Get("Authorization")
-
 		if basicStr == "" {
-			// code basic is empty
-
-			c.Abort()
+			abortUnauthorized(c, errors.WithCode(code.ErrMissingHeader, "the `Authorization` header was empty"), AuthorizationBasic)
 			return
 		}
 
-		// Basic username:password
-
-		auth := strings.SplitN(basicStr, " ", 2)
-		if len(auth) != 2 {
-			// basic len not inconformity
-
-			c.Abort()
+		username, password, ok := parseBasic(basicStr)
+		if !ok {
+			abortUnauthorized(c, errors.WithCode(code.ErrInvalidAuthHeader, "Authorization header format must be Basic {base64(username:password)}"), AuthorizationBasic)
 			return
 		}
 
-		if auth[0] != "Basic" {
-			// auth type is not basic
-
-			c.Abort()
+		if !basic.verify(username, password) {
+			abortUnauthorized(c, errors.WithCode(code.ErrPasswordIncorrect, "username or password is incorrect"), AuthorizationBasic)
 			return
 		}
 
-		bytes, err := base64.StdEncoding.DecodeString(auth[1])
-		if err != nil {
-			// base auth decode failed
-
-			c.Abort()
-			return
-		}
+		setAuthenticated(c, username, AuthMethodBasic)
 
-		infoStr := string(bytes)
-		info := strings.Split(infoStr, ":")
+		c.Next()
+	}
 
-		if len(info) != 2 || basic.verify(info[0], info[1]) {
-			// code 构建 info len or username/password verification failure
+}
 
-			c.Abort()
-			return
-		}
+// parseBasic 解析 Basic base64(username:password), 密码中允许出现 ':'
+func parseBasic(header string) (username, password string, ok bool) {
+	auth := strings.SplitN(header, " ", 2)
+	if len(auth) != 2 || !strings.EqualFold(auth[0], AuthorizationBasic) {
+		return "", "", false
+	}
 
-		// 返回对应的信息
-		c.Next()
+	bytes, err := base64.StdEncoding.DecodeString(strings.TrimSpace(auth[1]))
+	if err != nil {
+		return "", "", false
+	}
 
+	info := strings.SplitN(string(bytes), ":", 2)
+	if len(info) != 2 || info[0] == "" {
+		return "", "", false
 	}
 
+	return info[0], info[1], true
 }
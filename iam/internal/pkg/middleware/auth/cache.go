@@ -1,11 +1,13 @@
 package auth
 
 import (
-	"errors"
 	"fmt"
 	"github.com/gin-gonic/gin"
 	"github.com/golang-jwt/jwt/v4"
-	"iam/internal/pkg/middleware"
+	"iam/internal/pkg/code"
+	"iam/pkg/errors"
+	"iam/pkg/jwks"
+	"strings"
 	"time"
 )
 
@@ -22,12 +24,16 @@ type Secret struct {
 	Expires  int64
 }
 
+// CacheStrategy HMAC 签名的 token 使用内存中用户的密钥验证,
+// 非对称算法签名的 token 为 apiserver 签发, 使用 keys 中对应 kid 的公钥验证
 type CacheStrategy struct {
-	get func(kid string) (Secret, error) // 根据kid的得到对应的密钥信息
+	get        func(kid string) (Secret, error) // 根据kid的得到对应的密钥信息
+	keys       *jwks.KeySet                     // 为空时不接受 apiserver 签发的 token
+	revocation *Revocation                      // 与 apiserver 共用的吊销列表, 为空时不检查
 }
 
-func NewCacheStrategy(getSecret func(kid string) (Secret, error)) CacheStrategy {
-	return CacheStrategy{getSecret}
+func NewCacheStrategy(getSecret func(kid string) (Secret, error), keys *jwks.KeySet, revocation *Revocation) CacheStrategy {
+	return CacheStrategy{getSecret, keys, revocation}
 }
 
 func (cs *CacheStrategy) Auth() gin.HandlerFunc {
@@ -37,33 +43,34 @@ func (cs *CacheStrategy) Auth() gin.HandlerFunc {
 
 		key := c.Request.Header.Get("Authorization")
 		if key == "" {
-			// 返回错误信息 Authorization 为空
-			c.Abort()
+			abortUnauthorized(c, errors.WithCode(code.ErrMissingHeader, "the `Authorization` header was empty"), AuthorizationBearer)
 			return
 		}
 
-		var (
-			tokenStr string
-			secret   Secret
-		)
-
 		// 验证 Authorization 的 Bearer xxx 字段
-		fmt.Sscanf(key, "Bearer %s", &tokenStr)
-
-		if tokenStr == "" {
-			// 返回错误信息 token 为空
-			c.Abort()
+		auths := strings.SplitN(key, " ", 2)
+		if len(auths) != 2 || !strings.EqualFold(auths[0], AuthorizationBearer) || strings.TrimSpace(auths[1]) == "" {
+			abortUnauthorized(c, errors.WithCode(code.ErrInvalidAuthHeader, "Authorization header format must be Bearer {token}"), AuthorizationBearer)
 			return
 		}
 
+		var (
+			secret Secret
+			issued bool // 是否为 apiserver 签发的 token
+		)
+
 		// 进行解析token
 		claims := &jwt.MapClaims{}
 
-		parsedT, err := jwt.ParseWithClaims(tokenStr, claims, func(token *jwt.Token) (interface{}, error) {
+		parsedT, err := jwt.ParseWithClaims(strings.TrimSpace(auths[1]), claims, func(token *jwt.Token) (interface{}, error) {
 
-			// 验证 tokenStr 值的算法的HMAC签名
+			// 非 HMAC 签名时使用 apiserver 的公钥验证
 			if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
-				return nil, fmt.Errorf("unexpected signing method: %v", token.Header["alg"])
+				if cs.keys == nil {
+					return nil, fmt.Errorf("unexpected signing method: %v", token.Header["alg"])
+				}
+				issued = true
+				return cs.keys.Keyfunc(token)
 			}
 
 			kid, ok := token.Header["kid"].(string)
@@ -73,27 +80,52 @@ func (cs *CacheStrategy) Auth() gin.HandlerFunc {
 			var err error
 			secret, err = cs.get(kid)
 			if err != nil {
-				return "", err
+				return nil, ErrMissingSecret
 			}
 
 			return []byte(secret.Key), nil
 		})
 
+		// token 本身的 exp 已过期
+		if errors.Is(err, jwt.ErrTokenExpired) {
+			abortUnauthorized(c, errors.WrapC(err, code.ErrExpired, "token is expired"), AuthorizationBearer)
+			return
+		}
+
 		// 存在错误 || token无效
 		if err != nil || !parsedT.Valid {
-			c.Abort()
-			// 构建 token 无效的msg
+			if err == nil {
+				err = errors.New("token is invalid")
+			}
+			abortUnauthorized(c, errors.WrapC(err, code.ErrSignatureInvalid, "token is invalid"), AuthorizationBearer)
+			return
+		}
+
+		username, method := secret.Username, AuthMethodCache
+		if issued {
+			username, method = claimString(*claims, "sub"), AuthMethodJWT
+			if !isLoginToken(*claims) {
+				abortUnauthorized(c, errors.WithCode(code.ErrTokenInvalid, "token is not a login token"), AuthorizationBearer)
+				return
+			}
+			if username == "" {
+				abortUnauthorized(c, errors.WithCode(code.ErrTokenInvalid, "token has no subject"), AuthorizationBearer)
+				return
+			}
+		}
+
+		// 验证密钥是否过期, 0 表示永不过期
+		if !issued && secret.Expires != 0 && secret.Expires < time.Now().Unix() {
+			abortUnauthorized(c, errors.WithCode(code.ErrExpired, "secret %s expired", secret.ID), AuthorizationBearer)
 			return
 		}
 
-		// 验证是否过期
-		if secret.Expires >= time.Now().Unix() {
-			// 过期 msg
-			c.Abort()
+		if cs.revocation.IsRevoked(claimString(*claims, "jti"), username, claimFloat64(*claims, "iat")) {
+			abortUnauthorized(c, errors.WithCode(code.ErrTokenRevoked, "token has been revoked"), AuthorizationBearer)
 			return
 		}
 
-		c.Set(middleware.UsernameKey, secret.Username) // 设置人名
+		setAuthenticated(c, username, method)
 
 		c.Next()
 	}
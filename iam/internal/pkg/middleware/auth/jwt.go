@@ -1,23 +1,31 @@
 package auth
 
 import (
-	"encoding/base64"
-	"fmt"
+	"context"
 	ginJwt "github.com/appleboy/gin-jwt/v2"
 	"github.com/gin-gonic/gin"
+	"github.com/gin-gonic/gin/binding"
+	"github.com/golang-jwt/jwt/v4"
 	"github.com/sirupsen/logrus"
 	"github.com/spf13/viper"
 	"iam/internal/apiserver/store"
+	"iam/internal/pkg/code"
 	"iam/internal/pkg/middleware"
 	"iam/pkg/api/user"
+	"iam/pkg/core"
+	"iam/pkg/errors"
+	"iam/pkg/jwks"
+	"iam/pkg/util/idutil"
 	"net/http"
-	"strings"
 	"time"
 )
 
 const (
 	Realm           = ""
 	APIServerIssuer = ".keep-server"
+
+	tokenRevokedKey = "auth_token_revoked"
+	tokenTypeKey    = "auth_token_type_invalid"
 )
 
 // 登录所需
@@ -26,31 +34,154 @@ type loginInfo struct {
 	Password string `form:"password" json:"password" binding:"required,password"`
 }
 
+// federatedLogin 使用上游 IdP 签发的 id token 登录
+type federatedLogin struct {
+	IDToken string `json:"idToken"`
+}
+
+// FederatedAuthenticator 验证上游 IdP 签发的 id token, 返回关联的本地用户
+type FederatedAuthenticator interface {
+	Authenticate(ctx context.Context, idToken string) (*user.User, error)
+}
+
+// JWTStrategy gin-jwt 只支持 HS 和 RS 算法且不写入 kid, 签发统一由 keys 完成, 验证时按 kid 选择密钥
 type JWTStrategy struct {
 	ginJwt.GinJWTMiddleware
+	keys       *jwks.KeySet
+	revocation *Revocation // 为空时不检查吊销
+}
+
+func (j *JWTStrategy) Auth() gin.HandlerFunc {
+	return j.MiddlewareFunc()
+}
+
+// NewJWTStrategy 初始化 gin jwt 验证, keys 中需要包含签发密钥
+func NewJWTStrategy(keys *jwks.KeySet, revocation *Revocation) *JWTStrategy {
+	return &JWTStrategy{*NewGinGwt(keys, revocation), keys, revocation}
+}
+
+// SetFederation 登录时同时接受上游 IdP 签发的 id token
+func (j *JWTStrategy) SetFederation(federation FederatedAuthenticator) {
+	j.Authenticator = authenticator(federation)
+}
+
+// LoginHandler 登录成功后使用签发密钥生成 token
+func (j *JWTStrategy) LoginHandler(c *gin.Context) {
+	data, err := j.Authenticator(c)
+	if err != nil {
+		j.Unauthorized(c, http.StatusUnauthorized, j.HTTPStatusMessageFunc(err, c))
+		return
+	}
+
+	token, expire, err := j.TokenGenerator(data)
+	if err != nil {
+		logrus.Errorf("sign token failed: %v", err)
+		j.Unauthorized(c, http.StatusUnauthorized, j.HTTPStatusMessageFunc(ginJwt.ErrFailedTokenCreation, c))
+		return
+	}
+
+	j.LoginResponse(c, http.StatusOK, token, expire)
+}
+
+// RefreshHandler 使用当前的签发密钥重新签发, 轮换后旧 kid 的 token 刷新即换成新 kid
+func (j *JWTStrategy) RefreshHandler(c *gin.Context) {
+	// OIDC 签发的 token 只能通过 /oauth2/token 刷新, 没有 orig_iat 时 gin-jwt 会 panic
+	if token, _ := j.ParseToken(c); token != nil {
+		if claims, ok := token.Claims.(jwt.MapClaims); ok {
+			if _, ok = claims["orig_iat"].(float64); !ok || !isLoginToken(claims) {
+				j.Unauthorized(c, http.StatusUnauthorized, "token can not be refreshed")
+				return
+			}
+		}
+	}
+
+	claims, err := j.CheckIfTokenExpire(c)
+	if err != nil {
+		j.Unauthorized(c, http.StatusUnauthorized, j.HTTPStatusMessageFunc(err, c))
+		return
+	}
+
+	// 已吊销的 token 不能再刷新
+	if j.revocation.IsRevoked(claimString(claims, "jti"), claimString(claims, "sub"), claimFloat64(claims, "iat")) {
+		c.Set(tokenRevokedKey, true)
+		j.Unauthorized(c, http.StatusUnauthorized, "token has been revoked")
+		return
+	}
+
+	// 保留原始的 iat, 使用新的 jti
+	newClaims := jwt.MapClaims{}
+	for key, value := range claims {
+		newClaims[key] = value
+	}
+	newClaims["jti"] = idutil.GetUUID36("")
+
+	token, expire, err := j.sign(newClaims)
+	if err != nil {
+		logrus.Errorf("sign token failed: %v", err)
+		j.Unauthorized(c, http.StatusUnauthorized, j.HTTPStatusMessageFunc(ginJwt.ErrFailedTokenCreation, c))
+		return
+	}
+
+	j.RefreshResponse(c, http.StatusOK, token, expire)
 }
 
-func (jwt *JWTStrategy) Auth() gin.HandlerFunc {
-	return jwt.MiddlewareFunc()
+// LogoutHandler 吊销当前携带的 token, 没有携带有效的 token 时直接返回成功
+func (j *JWTStrategy) LogoutHandler(c *gin.Context) {
+	if j.revocation != nil {
+		if token, err := j.ParseToken(c); err == nil && token.Valid {
+			claims, _ := token.Claims.(jwt.MapClaims)
+			expireAt := time.Unix(claimInt64(claims, "exp"), 0)
+			if err := j.revocation.RevokeToken(claimString(claims, "jti"), expireAt); err != nil {
+				core.WriteResponse(c, errors.WrapC(err, code.ErrUnknown, "revoke token failed"), nil)
+				return
+			}
+		}
+	}
+
+	j.GinJWTMiddleware.LogoutHandler(c)
 }
 
-// NewJWTStrategy 初始化 gin jwt 验证
-func NewJWTStrategy(gjwt *ginJwt.GinJWTMiddleware) *JWTStrategy {
-	return &JWTStrategy{*gjwt}
+// TokenGenerator 根据 PayloadFunc 生成的负载签发 token
+func (j *JWTStrategy) TokenGenerator(data interface{}) (string, time.Time, error) {
+	claims := jwt.MapClaims{}
+	for key, value := range j.PayloadFunc(data) {
+		claims[key] = value
+	}
+
+	return j.sign(claims)
+}
+
+func (j *JWTStrategy) sign(claims jwt.MapClaims) (string, time.Time, error) {
+	expire := j.TimeFunc().Add(j.Timeout)
+	claims["exp"] = expire.Unix()
+	claims["orig_iat"] = j.TimeFunc().Unix()
+
+	token, err := j.keys.Sign(claims)
+	if err != nil {
+		return "", time.Time{}, err
+	}
+
+	return token, expire, nil
 }
 
 // 登录，登出，重新刷新token
 
-func NewGinGwt() *ginJwt.GinJWTMiddleware {
+// NewGinGwt 验证时通过 KeyFunc 按 kid 选择 keys 中的密钥, 轮换期间新旧密钥签发的 token 都有效
+func NewGinGwt(keys *jwks.KeySet, revocation *Revocation) *ginJwt.GinJWTMiddleware {
+
+	algorithm := jwt.SigningMethodHS256.Alg()
+	if key := keys.SigningKey(); key != nil {
+		algorithm = key.Method.Alg()
+	}
 
 	// 实现认证函数
 	gJwt, _ := ginJwt.New(&ginJwt.GinJWTMiddleware{
 		Realm:            viper.GetString("jwt.realm"),         // JWT # jwt 标识
-		SigningAlgorithm: "HS256",                              // 签名算法
-		Key:              []byte(viper.GetString("jwt.key")),   // 服务端密钥
+		SigningAlgorithm: algorithm,                            // 签名算法
+		KeyFunc:          keys.Keyfunc,                         // 按 kid 选择验证密钥
 		Timeout:          viper.GetDuration("jwt.timeout"),     // 过期
-		MaxRefresh:       viper.GetDuration("jwt.max_refresh"), // 最大重试
-		Authenticator:    authenticator(),                      // 登录
+		MaxRefresh:       viper.GetDuration("jwt.max-refresh"), // 最大重试
+		Authenticator:    authenticator(nil),                   // 登录
 		PayloadFunc:      payloadFunc(),                        // 负载
 		LoginResponse:    loginResponse(),                      // 登录返回
 		LogoutResponse: func(c *gin.Context, code int) {
@@ -59,16 +190,11 @@ func NewGinGwt() *ginJwt.GinJWTMiddleware {
 		RefreshResponse: refreshResponse(), // 重新登录返回值
 		IdentityHandler: func(c *gin.Context) interface{} {
 			claims := ginJwt.ExtractClaims(c)
-			return claims[ginJwt.IdentityKey]
-		},
-		IdentityKey:  middleware.UsernameKey,
-		Authorizator: authorizator(), // ?
-		Unauthorized: func(c *gin.Context, code int, message string) {
-			c.JSON(code, gin.H{
-				"code":    code,
-				"message": message,
-			})
+			return claims["sub"] // payloadFunc 中 sub 为用户名, 设置到 middleware.UsernameKey 中
 		},
+		IdentityKey:   middleware.UsernameKey,
+		Authorizator:  authorizator(revocation),
+		Unauthorized:  unauthorized(),
 		TokenLookup:   "header: Authorization, query: token, cookie: jwt",
 		TokenHeadName: "Bearer",
 		TimeFunc:      time.Now,
@@ -77,19 +203,83 @@ func NewGinGwt() *ginJwt.GinJWTMiddleware {
 	return gJwt
 }
 
-// data 是用户身份验证时使用 PayloadFunc 函数返回的数据
-func authorizator() func(data interface{}, c *gin.Context) bool {
+// data 为 IdentityHandler 返回的用户名, 已由 gin-jwt 设置到 middleware.UsernameKey 中
+func authorizator(revocation *Revocation) func(data interface{}, c *gin.Context) bool {
 	return func(data interface{}, c *gin.Context) bool {
-		//if _, ok := data.(string); ok {
-		//
-		//	return true
-		//}
+		username, ok := data.(string)
+		if !ok || username == "" {
+			return false
+		}
 
-		fmt.Println("data", data)
+		claims := ginJwt.ExtractClaims(c)
+		if !isLoginToken(claims) {
+			c.Set(tokenTypeKey, true)
+			return false
+		}
+		if revocation.IsRevoked(claimString(claims, "jti"), username, claimFloat64(claims, "iat")) {
+			c.Set(tokenRevokedKey, true)
+			return false
+		}
+
+		c.Set(middleware.AuthMethodKey, AuthMethodJWT)
 		return true
 	}
 }
 
+// isLoginToken apiserver 登录签发的 token 不包含 aud, client_id 和 typ.
+// OIDC 签发的 access token, id token 和会话与登录 token 使用同一组密钥, 不能作为用户的登录凭证
+func isLoginToken(claims map[string]interface{}) bool {
+	for _, key := range []string{"aud", "client_id", "typ"} {
+		if _, ok := claims[key]; ok {
+			return false
+		}
+	}
+
+	return true
+}
+
+// unauthorized 将 gin-jwt 的错误转换为带错误码的响应, 同时用于 Bearer 认证和登录失败
+func unauthorized() func(c *gin.Context, status int, message string) {
+	return func(c *gin.Context, status int, message string) {
+		// gin-jwt 已设置 WWW-Authenticate: JWT realm=xxx, 统一为 Bearer
+		c.Writer.Header().Del("WWW-Authenticate")
+
+		// authorizator 中发现 token 已吊销时 gin-jwt 返回的是 403
+		if c.GetBool(tokenRevokedKey) {
+			abortUnauthorized(c, errors.WithCode(code.ErrTokenRevoked, "token has been revoked"), AuthorizationBearer)
+			return
+		}
+		if c.GetBool(tokenTypeKey) {
+			abortUnauthorized(c, errors.WithCode(code.ErrTokenInvalid, "token is not a login token"), AuthorizationBearer)
+			return
+		}
+
+		abortUnauthorized(c, errors.WithCode(jwtErrCode(status, message), message), AuthorizationBearer)
+	}
+}
+
+// jwtErrCode 根据 gin-jwt 返回的错误信息得到错误码
+func jwtErrCode(status int, message string) int {
+	switch message {
+	case ginJwt.ErrEmptyAuthHeader.Error(), ginJwt.ErrEmptyQueryToken.Error(), ginJwt.ErrEmptyCookieToken.Error():
+		return code.ErrMissingHeader
+	case ginJwt.ErrInvalidAuthHeader.Error():
+		return code.ErrInvalidAuthHeader
+	case ginJwt.ErrExpiredToken.Error():
+		return code.ErrExpired
+	case ginJwt.ErrFailedAuthentication.Error(), ginJwt.ErrMissingLoginValues.Error():
+		return code.ErrPasswordIncorrect
+	case ginJwt.ErrForbidden.Error():
+		return code.ErrPermissionDenied
+	}
+
+	if status >= http.StatusInternalServerError {
+		return code.ErrUnknown
+	}
+
+	return code.ErrTokenInvalid
+}
+
 // 重新登录，返回值
 func refreshResponse() func(c *gin.Context, code int, token string, expire time.Time) {
 	return func(c *gin.Context, code int, token string, expire time.Time) {
@@ -119,8 +309,9 @@ func payloadFunc() func(data interface{}) ginJwt.MapClaims {
 
 		// data 实际
 		if userInfo, ok := data.(*user.User); ok {
-			claims["sub"] = userInfo.Name     // 主题名称，即用户名
-			claims["iat"] = time.Now().Unix() // 签发时间
+			claims["sub"] = userInfo.Name           // 主题名称，即用户名
+			claims["iat"] = NumericDate(time.Now()) // 签发时间
+			claims["jti"] = idutil.GetUUID36("")    // token 唯一标识, 用于吊销
 		}
 
 		return claims
@@ -128,41 +319,45 @@ func payloadFunc() func(data interface{}) ginJwt.MapClaims {
 
 }
 
-// 登录回调函数
-func authenticator() func(c *gin.Context) (interface{}, error) {
+// 登录回调函数, federation 不为空时 body 中携带 idToken 则使用上游 IdP 登录
+func authenticator(federation FederatedAuthenticator) func(c *gin.Context) (interface{}, error) {
 
 	return func(c *gin.Context) (interface{}, error) {
 
 		var info loginInfo
 		var err error
+		var userinfo *user.User
 
-		if c.Request.Header.Get("Authorization") != "" {
-			info, err = headBind(c)
+		if idToken := federatedToken(c, federation); idToken != "" {
+			userinfo, err = federation.Authenticate(c, idToken)
 			if err != nil {
-				return nil, err
+				logrus.Errorf("federated login failed: %+v", err)
+
+				return "", ginJwt.ErrFailedAuthentication
 			}
 		} else {
-			info, err = bodyBind(c)
+			if c.Request.Header.Get("Authorization") != "" {
+				info, err = headBind(c)
+			} else {
+				info, err = bodyBind(c)
+			}
 			if err != nil {
-				return nil, err
+				return "", ginJwt.ErrFailedAuthentication
 			}
-		}
-		if err != nil {
-			return "", ginJwt.ErrFailedAuthentication
-		}
 
-		// 根据 user_name 获取
-		userinfo, err := store.GetFactory().User().GetUserByName(c, info.Username)
-		if err != nil {
-			logrus.Errorf("get user_ information failed: %s", err.Error())
+			// 根据 user_name 获取
+			userinfo, err = store.GetFactory().User().GetUserByName(c, info.Username)
+			if err != nil {
+				logrus.Errorf("get user_ information failed: %s", err.Error())
 
-			return "", ginJwt.ErrFailedAuthentication
-		}
+				return "", ginJwt.ErrFailedAuthentication
+			}
 
-		// 验证密码
-		err = userinfo.Compare(info.Password)
-		if err != nil {
-			return "", err
+			// 验证密码
+			err = userinfo.Compare(info.Password)
+			if err != nil {
+				return "", ginJwt.ErrFailedAuthentication
+			}
 		}
 
 		// time.Now()
@@ -180,27 +375,33 @@ func authenticator() func(c *gin.Context) (interface{}, error) {
 // 通过 head 验证 结构为 Authorization: Basic base64编码的username:password
 func headBind(c *gin.Context) (loginInfo, error) {
 
-	basicStr := c.Request.Header.Get("Authorization")
-
-	auths := strings.SplitN(basicStr, " ", 2)
-	if len(auths) != 2 || auths[0] != "Basic" {
+	username, password, ok := parseBasic(c.Request.Header.Get("Authorization"))
+	if !ok {
 		return loginInfo{}, ginJwt.ErrFailedAuthentication
 	}
 
-	infoStr := base64.StdEncoding.EncodeToString([]byte(auths[1]))
-	info := strings.Split(infoStr, ":")
-	if len(info) != 2 {
-		return loginInfo{}, ginJwt.ErrFailedAuthentication
+	return loginInfo{username, password}, nil
+}
+
+// federatedToken 获取 body 中的 idToken, body 会被缓存, 之后仍可以通过 bodyBind 读取
+func federatedToken(c *gin.Context, federation FederatedAuthenticator) string {
+	if federation == nil || c.Request.Header.Get("Authorization") != "" {
+		return ""
+	}
+
+	var login federatedLogin
+	if err := c.ShouldBindBodyWith(&login, binding.JSON); err != nil {
+		return ""
 	}
 
-	return loginInfo{info[0], info[1]}, nil
+	return login.IDToken
 }
 
 // 通过 body
 func bodyBind(c *gin.Context) (loginInfo, error) {
 
 	info := loginInfo{}
-	err := c.ShouldBindJSON(&info)
+	err := c.ShouldBindBodyWith(&info, binding.JSON)
 	if err != nil {
 		return info, ginJwt.ErrFailedAuthentication
 	}
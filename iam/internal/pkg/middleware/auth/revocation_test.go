@@ -0,0 +1,177 @@
+package auth
+
+import (
+	"encoding/json"
+	"errors"
+	"github.com/gin-gonic/gin"
+	"github.com/golang-jwt/jwt/v4"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"iam/internal/pkg/code"
+	"iam/pkg/core"
+	"iam/pkg/jwks"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+)
+
+type fakeRevocationStore struct {
+	mu     sync.Mutex
+	values map[string]string
+	ttls   map[string]time.Duration
+}
+
+func newFakeRevocationStore() *fakeRevocationStore {
+	return &fakeRevocationStore{values: map[string]string{}, ttls: map[string]time.Duration{}}
+}
+
+func (s *fakeRevocationStore) SetKey(key, value string, expire time.Duration) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.values[key], s.ttls[key] = value, expire
+	return nil
+}
+
+func (s *fakeRevocationStore) GetMultiKey(keys []string) ([]string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	values := make([]string, 0, len(keys))
+	for _, key := range keys {
+		values = append(values, s.values[key])
+	}
+	return values, nil
+}
+
+func TestJWTStrategyRevocation(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	store := newFakeRevocationStore()
+	revocation := NewRevocation(store, false)
+	keys, err := jwks.NewHMAC([]byte("test-key"))
+	require.NoError(t, err)
+	newTestJWTStrategyWithKeys(keys) // 设置 viper 中的 jwt 配置
+	strategy := NewJWTStrategy(keys, revocation)
+
+	g := gin.New()
+	g.POST("/logout", strategy.LogoutHandler)
+	g.POST("/refresh", strategy.RefreshHandler)
+	g.GET("/", strategy.Auth(), func(c *gin.Context) { c.Status(http.StatusOK) })
+
+	do := func(method, path, token string) (int, int) {
+		req := httptest.NewRequest(method, path, nil)
+		req.Header.Set("Authorization", "Bearer "+token)
+		w := httptest.NewRecorder()
+		g.ServeHTTP(w, req)
+
+		var rsp core.ErrResponse
+		_ = json.Unmarshal(w.Body.Bytes(), &rsp)
+		return w.Code, rsp.Code
+	}
+
+	// 登出后当前 token 失效, 记录保存到 token 过期为止
+	token := jwtToken(t, strategy, "colin")
+	status, _ := do(http.MethodGet, "/", token)
+	assert.Equal(t, http.StatusOK, status)
+
+	status, _ = do(http.MethodPost, "/logout", token)
+	assert.Equal(t, http.StatusOK, status)
+	assert.Len(t, store.ttls, 1)
+	for _, ttl := range store.ttls {
+		assert.InDelta(t, time.Hour.Seconds(), ttl.Seconds(), 5)
+	}
+
+	status, errCode := do(http.MethodGet, "/", token)
+	assert.Equal(t, http.StatusUnauthorized, status)
+	assert.Equal(t, code.ErrTokenRevoked, errCode)
+
+	status, errCode = do(http.MethodPost, "/refresh", token)
+	assert.Equal(t, http.StatusUnauthorized, status)
+	assert.Equal(t, code.ErrTokenRevoked, errCode)
+
+	signAt := func(jti string, iat time.Time) string {
+		signed, err := keys.Sign(jwt.MapClaims{
+			"sub":      "colin",
+			"jti":      jti,
+			"iat":      iat.Unix(),
+			"orig_iat": iat.Unix(),
+			"exp":      time.Now().Add(time.Hour).Unix(),
+		})
+		require.NoError(t, err)
+		return signed
+	}
+
+	// 同一用户的其他 token 不受影响
+	other := signAt("other", time.Now().Add(-time.Minute))
+	status, _ = do(http.MethodGet, "/", other)
+	assert.Equal(t, http.StatusOK, status)
+
+	// 吊销用户后, 之前签发的 token 全部失效, 之后签发的仍然有效
+	require.NoError(t, revocation.RevokeUser("colin", time.Now().Add(-30*time.Second)))
+	status, errCode = do(http.MethodGet, "/", other)
+	assert.Equal(t, http.StatusUnauthorized, status)
+	assert.Equal(t, code.ErrTokenRevoked, errCode)
+
+	status, _ = do(http.MethodGet, "/", signAt("later", time.Now()))
+	assert.Equal(t, http.StatusOK, status)
+}
+
+func TestCacheStrategyRevocation(t *testing.T) {
+	revocation := NewRevocation(newFakeRevocationStore(), false)
+	strategy := NewCacheStrategy(func(kid string) (Secret, error) {
+		return Secret{Username: "colin", ID: kid, Key: "key-ok"}, nil
+	}, nil, revocation)
+
+	sign := func(jti string, iat time.Time) string {
+		token := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.MapClaims{
+			"jti": jti,
+			"iat": iat.Unix(),
+			"exp": time.Now().Add(time.Hour).Unix(),
+		})
+		token.Header["kid"] = "kid-ok"
+
+		signed, err := token.SignedString([]byte("key-ok"))
+		require.NoError(t, err)
+		return signed
+	}
+
+	revoked, old, fresh := sign("revoked", time.Now()), sign("old", time.Now().Add(-time.Hour)), sign("fresh", time.Now())
+	require.NoError(t, revocation.RevokeToken("revoked", time.Now().Add(time.Hour)))
+	require.NoError(t, revocation.RevokeUser("colin", time.Now().Add(-time.Minute)))
+
+	runAuthCases(t, &strategy, []authCase{
+		{name: "revoked jti", header: "Bearer " + revoked, wantStatus: http.StatusUnauthorized, wantCode: code.ErrTokenRevoked},
+		{name: "issued before revoke all", header: "Bearer " + old, wantStatus: http.StatusUnauthorized, wantCode: code.ErrTokenRevoked},
+		{name: "issued after revoke all", header: "Bearer " + fresh, wantStatus: http.StatusOK, wantUser: "colin", wantMethod: AuthMethodCache},
+	})
+}
+
+type brokenRevocationStore struct{}
+
+func (brokenRevocationStore) SetKey(string, string, time.Duration) error {
+	return errors.New("redis down")
+}
+func (brokenRevocationStore) GetMultiKey([]string) ([]string, error) {
+	return nil, errors.New("redis down")
+}
+
+func TestRevocationIsRevoked(t *testing.T) {
+	revocation := NewRevocation(newFakeRevocationStore(), false)
+	revokedAt := time.Unix(1700000000, 700*int64(time.Millisecond))
+	require.NoError(t, revocation.RevokeUser("colin", revokedAt))
+
+	// 同一秒内, 吊销之后签发的 token 仍然有效
+	assert.False(t, revocation.IsRevoked("", "colin", NumericDate(revokedAt.Add(200*time.Millisecond))))
+	assert.True(t, revocation.IsRevoked("", "colin", NumericDate(revokedAt.Add(-200*time.Millisecond))))
+	// 秒级的 iat 无法区分先后, 视为已吊销
+	assert.True(t, revocation.IsRevoked("", "colin", float64(revokedAt.Unix())))
+	assert.True(t, revocation.IsRevoked("", "colin", 0))
+	assert.False(t, revocation.IsRevoked("", "tom", 0))
+
+	// redis 不可用时按配置放行或拒绝
+	assert.False(t, NewRevocation(brokenRevocationStore{}, false).IsRevoked("jti", "colin", NumericDate(time.Now())))
+	assert.True(t, NewRevocation(brokenRevocationStore{}, true).IsRevoked("jti", "colin", NumericDate(time.Now())))
+}
@@ -2,7 +2,10 @@ package middleware
 
 import "github.com/gin-gonic/gin"
 
-const UsernameKey = "username"
+const (
+	UsernameKey   = "username"
+	AuthMethodKey = "auth_method" // 认证方式: basic, jwt, cache
+)
 
 // Context 公共使用string值  --> 暂时
 func Context() gin.HandlerFunc {
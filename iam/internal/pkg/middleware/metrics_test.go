@@ -0,0 +1,36 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMetrics(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	g := gin.New()
+	g.Use(Metrics())
+	g.GET("/v1/users/:name", func(c *gin.Context) {
+		assert.Equal(t, 1.0, testutil.ToFloat64(httpRequestsInFlight.WithLabelValues("/v1/users/:name", http.MethodGet)))
+		c.String(http.StatusOK, c.Param("name"))
+	})
+
+	for _, path := range []string{"/v1/users/colin", "/v1/users/tom", "/not-exist"} {
+		g.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, path, nil))
+	}
+	// 非标准方法归到同一个 label
+	for _, method := range []string{"FOO", "BAR"} {
+		g.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(method, "/v1/users/colin", nil))
+	}
+
+	assert.Equal(t, 2.0, testutil.ToFloat64(httpRequestsTotal.WithLabelValues("/v1/users/:name", http.MethodGet, "200")))
+	assert.Equal(t, 1.0, testutil.ToFloat64(httpRequestsTotal.WithLabelValues(unmatchedRoute, http.MethodGet, "404")))
+	assert.Equal(t, 0.0, testutil.ToFloat64(httpRequestsInFlight.WithLabelValues("/v1/users/:name", http.MethodGet)))
+	assert.Equal(t, 2.0, testutil.ToFloat64(httpRequestsTotal.WithLabelValues(unmatchedRoute, otherMethod, "404")))
+	assert.Equal(t, 3, testutil.CollectAndCount(httpRequestDuration))
+}
@@ -0,0 +1,54 @@
+package middleware
+
+import (
+	"encoding/json"
+	"github.com/gin-gonic/gin"
+	"github.com/sirupsen/logrus"
+	"iam/internal/pkg/notification"
+	"iam/pkg/cache"
+	"net/http"
+	"strings"
+)
+
+// Publish 用户/密钥/策略 变更成功后, 通过 redis 发布变更通知, authz 收到后重新加载对应用户的缓存
+func Publish() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		c.Next()
+
+		if c.Request.Method == http.MethodGet || c.Writer.Status() >= http.StatusMultipleChoices {
+			return
+		}
+
+		// /v1/{resource}/...
+		var resource string
+		if pathSplit := strings.Split(c.FullPath(), "/"); len(pathSplit) > 2 {
+			resource = pathSplit[2]
+		}
+
+		notice := notification.Notification{Username: c.GetString(UsernameKey)}
+		switch resource {
+		case "policies":
+			notice.Command = notification.NoticePolicyChanged
+		case "secrets":
+			notice.Command = notification.NoticeSecretChanged
+		case "users":
+			notice.Command = notification.NoticeUserChanged
+			notice.Username = c.Param("name")
+		default:
+			return
+		}
+
+		if notice.Username == "" {
+			return
+		}
+
+		message, _ := json.Marshal(notice)
+		redisStore := &cache.RedisCluster{}
+		if err := redisStore.Publish(notification.RedisPubSubChannel, string(message)); err != nil {
+			logrus.Warnf("publish %s failed, err:%v", message, err)
+			return
+		}
+
+		logrus.Debugf("publish redis message: %s", message)
+	}
+}
@@ -0,0 +1,20 @@
+// Package notification 定义 apiserver 和 authz 之间通过 redis 发布订阅传递的变更通知
+package notification
+
+// RedisPubSubChannel apiserver 发布变更通知, authz 订阅该 channel 进行缓存重新加载
+const RedisPubSubChannel = "iam.cluster.notifications"
+
+// NotificationCommand 变更类型
+type NotificationCommand string
+
+const (
+	NoticeUserChanged   NotificationCommand = "UserChanged"
+	NoticeSecretChanged NotificationCommand = "SecretChanged"
+	NoticePolicyChanged NotificationCommand = "PolicyChanged"
+)
+
+// Notification 通过 redis 发布的变更通知, Username 为空时表示需要全量加载
+type Notification struct {
+	Command  NotificationCommand `json:"command"`
+	Username string              `json:"username,omitempty"`
+}
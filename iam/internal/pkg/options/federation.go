@@ -0,0 +1,99 @@
+package options
+
+import (
+	"fmt"
+	"github.com/spf13/pflag"
+	"net/url"
+	"time"
+)
+
+// FederationOptions 使用上游 OIDC IdP 签发的 id token 登录, 上游列表只能通过配置文件设置
+type FederationOptions struct {
+	Timeout   time.Duration             `json:"timeout"   mapstructure:"timeout"` // 请求上游 discovery 和 jwks 的超时时间
+	Providers []UpstreamProviderOptions `json:"providers" mapstructure:"providers"`
+
+	JWKSRefreshInterval time.Duration `json:"jwks-refresh-interval" mapstructure:"jwks-refresh-interval"` // 定期重新获取上游的 jwks
+}
+
+// UpstreamProviderOptions 上游 IdP, 通过 id token 中的 iss 进行匹配
+type UpstreamProviderOptions struct {
+	Name     string `json:"name"      mapstructure:"name"`
+	Issuer   string `json:"issuer"    mapstructure:"issuer"`
+	ClientID string `json:"client-id" mapstructure:"client-id"` // id token 的 aud 中必须包含该值
+	JWKSURI  string `json:"jwks-uri"  mapstructure:"jwks-uri"`  // 为空时通过 <issuer>/.well-known/openid-configuration 获取
+
+	AutoProvision  bool         `json:"auto-provision"  mapstructure:"auto-provision"`  // 首次登录时自动创建本地用户
+	UsernamePrefix string       `json:"username-prefix" mapstructure:"username-prefix"` // 本地用户名前缀, 避免与其他来源的用户重名
+	Claims         ClaimMapping `json:"claims"          mapstructure:"claims"`
+	AdminGroups    []string     `json:"admin-groups"    mapstructure:"admin-groups"`   // 属于其中任意一个组时为管理员, 为空时不修改管理员标识
+	AllowedGroups  []string     `json:"allowed-groups"  mapstructure:"allowed-groups"` // 为空时不限制
+}
+
+// ClaimMapping id token 中的 claim 到 user.User 字段的映射
+type ClaimMapping struct {
+	Username string `json:"username" mapstructure:"username"`
+	Nickname string `json:"nickname" mapstructure:"nickname"`
+	Email    string `json:"email"    mapstructure:"email"`
+	Groups   string `json:"groups"   mapstructure:"groups"`
+}
+
+func NewFederationOptions() *FederationOptions {
+	return &FederationOptions{
+		Timeout:             10 * time.Second,
+		JWKSRefreshInterval: time.Hour,
+	}
+}
+
+// Complete 补全默认的 claim 映射
+func (o *UpstreamProviderOptions) Complete() {
+	if o.Claims.Username == "" {
+		o.Claims.Username = "preferred_username"
+	}
+	if o.Claims.Nickname == "" {
+		o.Claims.Nickname = "name"
+	}
+	if o.Claims.Email == "" {
+		o.Claims.Email = "email"
+	}
+	if o.Claims.Groups == "" {
+		o.Claims.Groups = "groups"
+	}
+}
+
+func (o *FederationOptions) AddFlags(fs *pflag.FlagSet) {
+	fs.DurationVar(&o.Timeout, "federation.timeout", o.Timeout,
+		"Timeout of fetching discovery documents and JWKS from upstream identity providers.")
+	fs.DurationVar(&o.JWKSRefreshInterval, "federation.jwks-refresh-interval", o.JWKSRefreshInterval,
+		"Interval of refetching JWKS from upstream identity providers, keys removed upstream are no longer trusted after it.")
+}
+
+func (o *FederationOptions) Validate() []error {
+	var errs []error
+
+	if len(o.Providers) > 0 && o.Timeout <= 0 {
+		errs = append(errs, fmt.Errorf("--federation.timeout: must be greater than 0"))
+	}
+	if len(o.Providers) > 0 && o.JWKSRefreshInterval <= 0 {
+		errs = append(errs, fmt.Errorf("--federation.jwks-refresh-interval: must be greater than 0"))
+	}
+
+	issuers := make(map[string]bool, len(o.Providers))
+	for _, p := range o.Providers {
+		if p.Name == "" {
+			errs = append(errs, fmt.Errorf("--federation.providers: name must be set"))
+		}
+		if u, err := url.Parse(p.Issuer); err != nil || !u.IsAbs() || u.Host == "" {
+			errs = append(errs, fmt.Errorf("--federation.providers: %s issuer %q must be an absolute url", p.Name, p.Issuer))
+		}
+		if issuers[p.Issuer] {
+			errs = append(errs, fmt.Errorf("--federation.providers: duplicate issuer %s", p.Issuer))
+		}
+		issuers[p.Issuer] = true
+
+		if p.ClientID == "" {
+			errs = append(errs, fmt.Errorf("--federation.providers: %s client-id must be set", p.Name))
+		}
+	}
+
+	return errs
+}
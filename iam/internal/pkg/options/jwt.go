@@ -1,8 +1,10 @@
 package options
 
 import (
+	"fmt"
 	"github.com/spf13/pflag"
 	"iam/internal/pkg/server"
+	"iam/pkg/jwks"
 	"time"
 )
 
@@ -11,6 +13,19 @@ type JwtOptions struct {
 	Key        string        `json:"key"         mapstructure:"key"` // 私钥
 	Timeout    time.Duration `json:"timeout"     mapstructure:"timeout"`
 	MaxRefresh time.Duration `json:"max-refresh" mapstructure:"max-refresh"`
+	// 配置 keys 后使用非对称密钥签发和验证 token, 否则使用 key 进行 HS256 签名
+	SigningKid string          `json:"signing-kid" mapstructure:"signing-kid"` // 签发使用的 kid, 为空时使用第一个带私钥的密钥
+	Keys       []JwtKeyOptions `json:"keys"        mapstructure:"keys"`        // 轮换期间同时保留新旧密钥, 只能通过配置文件设置
+
+	// redis 不可用, 无法查询吊销列表时是否拒绝认证, 默认放行以免 redis 故障导致所有请求失败
+	RevocationFailClosed bool `json:"revocation-fail-closed" mapstructure:"revocation-fail-closed"`
+}
+
+// JwtKeyOptions PEM 格式的密钥, 算法由密钥类型决定: RSA -> RS256, P-256 -> ES256, Ed25519 -> EdDSA
+type JwtKeyOptions struct {
+	Kid            string `json:"kid"              mapstructure:"kid"`
+	PrivateKeyFile string `json:"private-key-file" mapstructure:"private-key-file"` // 用于签发, 只配置公钥时仅用于验证
+	PublicKeyFile  string `json:"public-key-file"  mapstructure:"public-key-file"`
 }
 
 func NewJwtOptions() *JwtOptions {
@@ -38,11 +53,54 @@ func (s *JwtOptions) AddFlags(fs *pflag.FlagSet) {
 	fs.StringVar(&s.Realm, "jwt.realm", s.Realm, "Realm name to display to the user.")
 	fs.StringVar(&s.Key, "jwt.key", s.Key, "用于签署jwt令牌的私钥.")
 	fs.DurationVar(&s.Timeout, "jwt.timeout", s.Timeout, "JWT token timeout.")
+	fs.StringVar(&s.SigningKid, "jwt.signing-kid", s.SigningKid, "The kid of the key in jwt.keys used to sign tokens.")
 	fs.DurationVar(&s.MaxRefresh, "jwt.max-refresh", s.MaxRefresh,
 		"This field allows clients to refresh their token until MaxRefresh has passed.") // 这个字段允许客户端刷新他们的令牌，直到MaxRefresh通过。
+	fs.BoolVar(&s.RevocationFailClosed, "jwt.revocation-fail-closed", s.RevocationFailClosed,
+		"Reject tokens when the revocation list in redis can not be checked.")
 
 }
 
 func (s *JwtOptions) Validate() []error {
-	return []error{}
+	var errs []error
+
+	kids := make(map[string]bool, len(s.Keys))
+	for _, key := range s.Keys {
+		if key.Kid == "" {
+			errs = append(errs, fmt.Errorf("--jwt.keys: kid must be set"))
+			continue
+		}
+		if kids[key.Kid] {
+			errs = append(errs, fmt.Errorf("--jwt.keys: duplicate kid %s", key.Kid))
+		}
+		kids[key.Kid] = true
+
+		if key.PrivateKeyFile == "" && key.PublicKeyFile == "" {
+			errs = append(errs, fmt.Errorf("--jwt.keys: %s must set private-key-file or public-key-file", key.Kid))
+		}
+	}
+
+	if s.SigningKid != "" && !kids[s.SigningKid] {
+		errs = append(errs, fmt.Errorf("--jwt.signing-kid: %s is not in jwt.keys", s.SigningKid))
+	}
+
+	return errs
+}
+
+// KeySet 加载签发和验证 token 使用的密钥, 未配置 keys 时使用 key 进行 HS256 签名
+func (s *JwtOptions) KeySet() (*jwks.KeySet, error) {
+	if len(s.Keys) == 0 {
+		return jwks.NewHMAC([]byte(s.Key))
+	}
+
+	files := make([]jwks.KeyFile, 0, len(s.Keys))
+	for _, key := range s.Keys {
+		files = append(files, jwks.KeyFile{
+			ID:             key.Kid,
+			PrivateKeyFile: key.PrivateKeyFile,
+			PublicKeyFile:  key.PublicKeyFile,
+		})
+	}
+
+	return jwks.Load(s.SigningKid, files)
 }
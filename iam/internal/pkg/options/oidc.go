@@ -0,0 +1,60 @@
+package options
+
+import (
+	"fmt"
+	"github.com/spf13/pflag"
+	"net/url"
+	"time"
+)
+
+// OIDCOptions apiserver 作为 OIDC provider 的配置, 令牌使用 jwt 中的签发密钥签名
+type OIDCOptions struct {
+	Enable          bool          `json:"enable"            mapstructure:"enable"`
+	Issuer          string        `json:"issuer"            mapstructure:"issuer"` // 对外访问的地址, 例如 https://iam.example.com
+	CodeTTL         time.Duration `json:"code-ttl"          mapstructure:"code-ttl"`
+	AccessTokenTTL  time.Duration `json:"access-token-ttl"  mapstructure:"access-token-ttl"` // access token 和 id token 的有效期
+	RefreshTokenTTL time.Duration `json:"refresh-token-ttl" mapstructure:"refresh-token-ttl"`
+	SessionTTL      time.Duration `json:"session-ttl"       mapstructure:"session-ttl"` // 登录页面写入的会话有效期, 期间再次授权不需要重新登录
+}
+
+func NewOIDCOptions() *OIDCOptions {
+	return &OIDCOptions{
+		Enable:          false,
+		CodeTTL:         time.Minute,
+		AccessTokenTTL:  time.Hour,
+		RefreshTokenTTL: 30 * 24 * time.Hour,
+		SessionTTL:      8 * time.Hour,
+	}
+}
+
+func (o *OIDCOptions) AddFlags(fs *pflag.FlagSet) {
+	fs.BoolVar(&o.Enable, "oidc.enable", o.Enable, "Enable the OIDC provider endpoints.")
+	fs.StringVar(&o.Issuer, "oidc.issuer", o.Issuer, "The external url of the apiserver, used as the iss of issued tokens.")
+	fs.DurationVar(&o.CodeTTL, "oidc.code-ttl", o.CodeTTL, "Lifetime of authorization codes.")
+	fs.DurationVar(&o.AccessTokenTTL, "oidc.access-token-ttl", o.AccessTokenTTL, "Lifetime of access tokens and id tokens.")
+	fs.DurationVar(&o.RefreshTokenTTL, "oidc.refresh-token-ttl", o.RefreshTokenTTL, "Lifetime of refresh tokens.")
+	fs.DurationVar(&o.SessionTTL, "oidc.session-ttl", o.SessionTTL, "Lifetime of the login session of the authorize endpoint.")
+}
+
+func (o *OIDCOptions) Validate() []error {
+	var errs []error
+	if !o.Enable {
+		return errs
+	}
+
+	u, err := url.Parse(o.Issuer)
+	if err != nil || !u.IsAbs() || u.Host == "" || u.RawQuery != "" || u.Fragment != "" {
+		errs = append(errs, fmt.Errorf("--oidc.issuer: %q must be an absolute url without query and fragment", o.Issuer))
+	}
+
+	for name, ttl := range map[string]time.Duration{
+		"code-ttl": o.CodeTTL, "access-token-ttl": o.AccessTokenTTL,
+		"refresh-token-ttl": o.RefreshTokenTTL, "session-ttl": o.SessionTTL,
+	} {
+		if ttl <= 0 {
+			errs = append(errs, fmt.Errorf("--oidc.%s: must be greater than 0", name))
+		}
+	}
+
+	return errs
+}
@@ -43,15 +43,15 @@ func NewRedisOptions() *RedisOptions {
 func (option *RedisOptions) AddFlags(fs *pflag.FlagSet) {
 	fs.StringVar(&option.Host, "redis.host", option.Host, "flag redis host")
 	fs.IntVar(&option.Port, "redis.port", option.Port, "flag redis port")
-	fs.StringVar(&option.Username, "redis.username", option.Host, "flag redis username")
-	fs.StringVar(&option.Password, "redis.password", option.Host, "flag redis password")
+	fs.StringVar(&option.Username, "redis.username", option.Username, "flag redis username")
+	fs.StringVar(&option.Password, "redis.password", option.Password, "flag redis password")
 	fs.IntVar(&option.Database, "redis.database", option.Database, "flag redis database")
 	fs.StringSliceVar(&option.Addrs, "redis.addrs", option.Addrs, "A set of redis address(format: 127.0.0.1:6379).")
 
 	// todo 表示
-	fs.StringVar(&option.MasterName, "redis.master-name", option.Host, "flag redis master-name")
+	fs.StringVar(&option.MasterName, "redis.master-name", option.MasterName, "flag redis master-name")
 	fs.IntVar(&option.MaxIdle, "redis.optimisation-max-idle", option.MaxIdle, "flag redis optimisation-max-idle")
-	fs.IntVar(&option.MaxActive, "redis.optimisation-max-active", option.MaxIdle, "flag redis optimisation-max-active")
+	fs.IntVar(&option.MaxActive, "redis.optimisation-max-active", option.MaxActive, "flag redis optimisation-max-active")
 	fs.IntVar(&option.Timeout, "redis.timeout", option.Timeout, "flag redis timeout")
 
 	fs.BoolVar(&option.EnableCluster, "redis.enable-cluster", option.EnableCluster, "flag redis enable-cluster") // 集群开关
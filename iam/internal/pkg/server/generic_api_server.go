@@ -5,6 +5,7 @@ import (
 	"fmt"
 	"github.com/gin-contrib/pprof"
 	"github.com/gin-gonic/gin"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
 	"golang.org/x/sync/errgroup"
 	"iam/internal/pkg/middleware"
 	appCli "iam/pkg/app/cli"
@@ -52,15 +53,13 @@ func (s *GenericAPIServer) InstallAPI() {
 		pprof.Register(s.Engine)
 	}
 
-	// 整成中间件形式
+	// 请求相关指标由 middleware.Metrics 记录
 	if s.enableMetrics {
-		// ginprometheus "github.com/zsais/go-gin-prometheus"
-		//prometheus := ginprometheus.NewPrometheus("gin")
-		//prometheus.Use(s.Engine)
+		s.GET("/metrics", gin.WrapH(promhttp.Handler()))
 	}
 
 	s.GET("/version", func(c *gin.Context) {
-		core.WriteResponse(c, http.StatusOK, nil, appCli.Get()) // 获取版本信息
+		core.WriteResponse(c, nil, appCli.Get()) // 获取版本信息
 	})
 
 }
@@ -76,6 +75,10 @@ func (s *GenericAPIServer) Setup() {
 func (s *GenericAPIServer) InstallMiddlewares() {
 
 	s.Use(middleware.RequestId()) // 定义请求request
+
+	if s.enableMetrics {
+		s.Use(middleware.Metrics())
+	}
 	// s.Use(middleware.Context())
 
 	// 根据需要进行使用中间件
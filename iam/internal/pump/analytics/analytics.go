@@ -1,7 +1,63 @@
 package analytics
 
+import "time"
+
+// 授权结论的类型, 与 authzserver/analytics 中的取值保持一致
+const (
+	ConclusionAllow      = "allow"
+	ConclusionForcedDeny = "forced_deny"
+	ConclusionNoMatch    = "no_match"
+)
+
+// AnalyticsRecord authz server 写入 redis 的授权记录, 与 authzserver/analytics 中的结构保持一致
+type AnalyticsRecord struct {
+	CreateTime     int64     `json:"create_time"`
+	Username       string    `json:"username"`
+	Effect         string    `json:"effect"`
+	Conclusion     string    `json:"conclusion"`
+	ConclusionCode string    `json:"conclusion_code"`
+	Request        string    `json:"request"`
+	Policies       string    `json:"policies"`
+	Deciders       string    `json:"deciders"`
+	ExpireAt       time.Time `json:"expireAt"`
+}
+
 // AnalyticsFilters 定义分析选项
 type AnalyticsFilters struct {
-	Usernames        []string `json:"usernames"`
-	SkippedUsernames []string `json:"skip_usernames"`
+	Usernames        []string `json:"usernames"      mapstructure:"usernames"`
+	SkippedUsernames []string `json:"skip_usernames" mapstructure:"skip_usernames"`
+}
+
+// HasFilter 是否配置了过滤条件
+func (filters AnalyticsFilters) HasFilter() bool {
+	return len(filters.Usernames) > 0 || len(filters.SkippedUsernames) > 0
+}
+
+// ShouldFilter 判断该记录是否需要被过滤掉
+func (filters AnalyticsFilters) ShouldFilter(record AnalyticsRecord) bool {
+	switch {
+	case len(filters.SkippedUsernames) > 0 && contains(filters.SkippedUsernames, record.Username):
+		return true
+	case len(filters.Usernames) > 0 && !contains(filters.Usernames, record.Username):
+		return true
+	}
+
+	return false
+}
+
+// RemoveDetailedFields 去掉请求、策略等详细内容
+func (a *AnalyticsRecord) RemoveDetailedFields() {
+	a.Request = ""
+	a.Policies = ""
+	a.Deciders = ""
+}
+
+func contains(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+
+	return false
 }
@@ -1 +1,92 @@
 package options
+
+import (
+	"fmt"
+	genericoptions "iam/internal/pkg/options"
+	"iam/internal/pump/analytics"
+	pkg "iam/pkg/app/cli"
+)
+
+// PumpConfig 单个 pump 的配置, 只能通过配置文件设置
+type PumpConfig struct {
+	Type                  string                     `json:"type"                    mapstructure:"type"`    // pump 类型, 如 elasticsearch
+	Filters               analytics.AnalyticsFilters `json:"filters"                 mapstructure:"filters"` // 过滤条件
+	Timeout               int                        `json:"timeout"                 mapstructure:"timeout"` // 单次写入超时时间(秒), 0 表示使用 purge-delay
+	OmitDetailedRecording bool                       `json:"omit-detailed-recording" mapstructure:"omit-detailed-recording"`
+	Meta                  map[string]interface{}     `json:"meta"                    mapstructure:"meta"` // pump 自身的配置
+}
+
+// Options 实现 apps 对应的CliOptions接口
+type Options struct {
+	PurgeDelay            int                          `json:"purge-delay"             mapstructure:"purge-delay"` // 多少秒从 redis 中取一次数据
+	Pumps                 map[string]PumpConfig        `json:"pumps"                   mapstructure:"pumps"`
+	HealthCheckPath       string                       `json:"health-check-path"       mapstructure:"health-check-path"`
+	HealthCheckAddress    string                       `json:"health-check-address"    mapstructure:"health-check-address"`
+	OmitDetailedRecording bool                         `json:"omit-detailed-recording" mapstructure:"omit-detailed-recording"` // 对所有 pump 生效
+	ChunkSize             int64                        `json:"chunk-size"              mapstructure:"chunk-size"`              // 每次从 redis 原子取出的最大条数
+	Consumer              string                       `json:"consumer"                mapstructure:"consumer"`                // 消费者名称, 重启后必须保持不变
+	RedisOptions          *genericoptions.RedisOptions `json:"redis"                   mapstructure:"redis"`
+	Log                   *genericoptions.LogOption    `json:"log"                     mapstructure:"log"`
+}
+
+// NewOptions 创建option的默认配置
+func NewOptions() *Options {
+	return &Options{
+		PurgeDelay:         10,
+		Pumps:              map[string]PumpConfig{},
+		HealthCheckPath:    "healthz",
+		HealthCheckAddress: "0.0.0.0:7070",
+		ChunkSize:          1000,
+		RedisOptions:       genericoptions.NewRedisOptions(),
+		Log:                genericoptions.NewLogOption(),
+	}
+}
+
+// Flags 创建flag name 并初始化获取所有的flag值
+func (o *Options) Flags() (fss pkg.NamedFlagSets) {
+	o.RedisOptions.AddFlags(fss.FlagSet("redis"))
+	o.Log.AddFlags(fss.FlagSet("logger"))
+
+	// 其他非构建的杂项
+	fs := fss.FlagSet("misc")
+	fs.IntVar(&o.PurgeDelay, "purge-delay", o.PurgeDelay, "interval in seconds to read analytics records from redis")
+	fs.StringVar(&o.HealthCheckPath, "health-check-path", o.HealthCheckPath, "health check path of pump")
+	fs.StringVar(&o.HealthCheckAddress, "health-check-address", o.HealthCheckAddress, "health check address of pump")
+	fs.BoolVar(&o.OmitDetailedRecording, "omit-detailed-recording", o.OmitDetailedRecording,
+		"omit request and policies of analytics records for all pumps")
+	fs.Int64Var(&o.ChunkSize, "chunk-size", o.ChunkSize, "max number of records read from redis at a time")
+	fs.StringVar(&o.Consumer, "consumer", o.Consumer,
+		"stable name of this pump instance, e.g. the StatefulSet pod name, unacknowledged records are redelivered after restart")
+
+	return fss
+}
+
+func (o *Options) Validate() []error {
+
+	var errs = make([]error, 0)
+	errs = append(errs, o.RedisOptions.Validate()...)
+	errs = append(errs, o.Log.Validate()...)
+
+	if o.PurgeDelay <= 0 {
+		errs = append(errs, fmt.Errorf("--purge-delay %d must be greater than 0", o.PurgeDelay))
+	}
+
+	if o.ChunkSize <= 0 {
+		errs = append(errs, fmt.Errorf("--chunk-size %d must be greater than 0", o.ChunkSize))
+	}
+
+	if o.Consumer == "" {
+		errs = append(errs, fmt.Errorf("--consumer must be set"))
+	}
+
+	for name, pump := range o.Pumps {
+		if pump.Type == "" {
+			errs = append(errs, fmt.Errorf("pumps.%s.type must be set", name))
+		}
+		if pump.Timeout < 0 {
+			errs = append(errs, fmt.Errorf("pumps.%s.timeout %d must not be negative", name, pump.Timeout))
+		}
+	}
+
+	return errs
+}
@@ -1,6 +1,10 @@
 package pumps
 
-import "iam/internal/pump/analytics"
+import (
+	"fmt"
+	"github.com/mitchellh/mapstructure"
+	"iam/internal/pump/analytics"
+)
 
 // CommonPumpConfig 通用选项配置
 type CommonPumpConfig struct {
@@ -32,3 +36,16 @@ func (comConfig *CommonPumpConfig) SetOmitDetailedRecording(odr bool) {
 func (comConfig *CommonPumpConfig) GetOmitDetailedRecording() bool {
 	return comConfig.omitDetailedRecording
 }
+
+// decodeMeta 将配置文件中 pump 的 meta 解析到对应 pump 的配置中
+func decodeMeta(meta interface{}, conf interface{}) error {
+	if meta == nil {
+		return nil
+	}
+
+	if err := mapstructure.Decode(meta, conf); err != nil {
+		return fmt.Errorf("decode pump meta failed: %w", err)
+	}
+
+	return nil
+}
@@ -1,9 +1,29 @@
 package pumps
 
-import "context"
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"github.com/sirupsen/logrus"
+	"iam/internal/pump/analytics"
+	"io"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
 
 // 某一种存储方式
 
+const (
+	elasticsearchPrefix       = "elasticsearch-pump"
+	defaultElasticsearchURL   = "http://localhost:9200"
+	defaultElasticsearchIndex = "iam_analytics"
+	defaultBulkActions        = 1000
+)
+
 type ElasticsearchOperator interface {
 	processData(ctx context.Context, data []interface{}, esConf *ElasticsearchConf) error
 }
@@ -18,16 +38,18 @@ type ElasticsearchConf struct {
 	BulkConfig       ElasticsearchBulkConfig `mapstructure:"bulk_config"`
 	IndexName        string                  `mapstructure:"index_name"`
 	ElasticsearchURL string                  `mapstructure:"elasticsearch_url"`
-	DocumentType     string                  `mapstructure:"document_type"`
+	DocumentType     string                  `mapstructure:"document_type"` // 仅 es 6 及以下版本需要
 	AuthAPIKeyID     string                  `mapstructure:"auth_api_key_id"`
 	AuthAPIKey       string                  `mapstructure:"auth_api_key"`
 	Username         string                  `mapstructure:"auth_basic_username"`
 	Password         string                  `mapstructure:"auth_basic_password"`
-	EnableSniffing   bool                    `mapstructure:"use_sniffing"`
+	EnableSniffing   bool                    `mapstructure:"use_sniffing"` // 通过 http api 写入, 暂不支持
 	RollingIndex     bool                    `mapstructure:"rolling_index"`
 	DisableBulk      bool                    `mapstructure:"disable_bulk"`
 }
 
+// ElasticsearchBulkConfig 单批数据超过 BulkActions 条或 BulkSize 字节时拆分成多个 bulk 请求, 由 Workers 个协程并发写入.
+// 每次 WriteData 结束前都会写完所有数据, FlushInterval 暂不使用
 type ElasticsearchBulkConfig struct {
 	Workers       int `mapstructure:"workers"`
 	FlushInterval int `mapstructure:"flush_interval"`
@@ -35,24 +57,299 @@ type ElasticsearchBulkConfig struct {
 	BulkSize      int `mapstructure:"bulk_size"`
 }
 
-func (pump *ElasticsearchPump) New() Pump {
+// elasticsearchDocument 写入 es 的文档, @timestamp 便于在 kibana 中按时间查询
+type elasticsearchDocument struct {
+	Timestamp  time.Time `json:"@timestamp"`
+	Username   string    `json:"username"`
+	Effect     string    `json:"effect"`
+	Conclusion string    `json:"conclusion"`
+	Request    string    `json:"request,omitempty"`
+	Policies   string    `json:"policies,omitempty"`
+	Deciders   string    `json:"deciders,omitempty"`
+	ExpireAt   time.Time `json:"expireAt"`
+}
 
-	//	return ElasticsearchPump{}
-	return nil
+func (pump *ElasticsearchPump) GetName() string {
+	return "Elasticsearch Pump"
+}
+
+func (pump *ElasticsearchPump) New() Pump {
+	return &ElasticsearchPump{}
 }
 
 func (pump *ElasticsearchPump) Init(cfg interface{}) error {
+	if err := decodeMeta(cfg, &pump.esConf); err != nil {
+		return err
+	}
+
+	if pump.esConf.ElasticsearchURL == "" {
+		pump.esConf.ElasticsearchURL = defaultElasticsearchURL
+	}
+	pump.esConf.ElasticsearchURL = strings.TrimSuffix(pump.esConf.ElasticsearchURL, "/")
+	if pump.esConf.IndexName == "" {
+		pump.esConf.IndexName = defaultElasticsearchIndex
+	}
+	if pump.esConf.BulkConfig.Workers <= 0 {
+		pump.esConf.BulkConfig.Workers = 1
+	}
+	if pump.esConf.BulkConfig.BulkActions <= 0 {
+		pump.esConf.BulkConfig.BulkActions = defaultBulkActions
+	}
+	if pump.esConf.EnableSniffing {
+		logrus.Warnf("%s: use_sniffing is not supported, write to %s only", elasticsearchPrefix, pump.esConf.ElasticsearchURL)
+	}
+
+	client := &elasticsearchClient{httpClient: &http.Client{}, conf: &pump.esConf}
+	if pump.esConf.DisableBulk {
+		pump.operator = &elasticsearchSingleOperator{client}
+	} else {
+		pump.operator = &elasticsearchBulkOperator{client}
+	}
+
+	logrus.Infof("%s: index %s, url %s", elasticsearchPrefix, pump.esConf.IndexName, pump.esConf.ElasticsearchURL)
+
 	return nil
 }
 
-func (pump *ElasticsearchPump) WriteData(datas []any) error {
-	// 从redis中获取 --> 写入
+func (pump *ElasticsearchPump) WriteData(ctx context.Context, datas []any) error {
+	logrus.Debugf("%s: writing %d records", elasticsearchPrefix, len(datas))
 
-	return nil
+	return pump.operator.processData(ctx, datas, &pump.esConf)
+}
+
+// indexName 开启 RollingIndex 时按天创建索引: index_name-YYYY.MM.DD
+func (conf *ElasticsearchConf) indexName() string {
+	if conf.RollingIndex {
+		return conf.IndexName + "-" + time.Now().Format("2006.01.02")
+	}
+
+	return conf.IndexName
 }
 
-/*
-	New() Pump
-	Init(interface{}) error      //
-	WriteData(datas []any) error // 往下游系统写入数据
-*/
+func toElasticsearchDocument(data interface{}) (elasticsearchDocument, bool) {
+	record, ok := data.(analytics.AnalyticsRecord)
+	if !ok {
+		return elasticsearchDocument{}, false
+	}
+
+	return elasticsearchDocument{
+		Timestamp:  time.Unix(record.CreateTime, 0),
+		Username:   record.Username,
+		Effect:     record.Effect,
+		Conclusion: record.Conclusion,
+		Request:    record.Request,
+		Policies:   record.Policies,
+		Deciders:   record.Deciders,
+		ExpireAt:   record.ExpireAt,
+	}, true
+}
+
+// elasticsearchClient 通过 http api 访问 es
+type elasticsearchClient struct {
+	httpClient *http.Client
+	conf       *ElasticsearchConf
+}
+
+func (c *elasticsearchClient) do(ctx context.Context, method, path, contentType string, body []byte) ([]byte, error) {
+	req, err := http.NewRequestWithContext(ctx, method, c.conf.ElasticsearchURL+path, bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", contentType)
+
+	switch {
+	case c.conf.AuthAPIKey != "":
+		apiKey := base64.StdEncoding.EncodeToString([]byte(c.conf.AuthAPIKeyID + ":" + c.conf.AuthAPIKey))
+		req.Header.Set("Authorization", "ApiKey "+apiKey)
+	case c.conf.Username != "":
+		req.SetBasicAuth(c.conf.Username, c.conf.Password)
+	}
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	respBody, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode >= http.StatusMultipleChoices {
+		return nil, fmt.Errorf("elasticsearch %s %s: status %d: %s", method, path, resp.StatusCode, respBody)
+	}
+
+	return respBody, nil
+}
+
+// elasticsearchBulkOperator 通过 _bulk 接口批量写入
+type elasticsearchBulkOperator struct {
+	client *elasticsearchClient
+}
+
+type bulkResponse struct {
+	Errors bool                                   `json:"errors"`
+	Items  []map[string]bulkResponseItemOperation `json:"items"`
+}
+
+type bulkResponseItemOperation struct {
+	Status int `json:"status"`
+	Error  *struct {
+		Type   string `json:"type"`
+		Reason string `json:"reason"`
+	} `json:"error,omitempty"`
+}
+
+func (o *elasticsearchBulkOperator) processData(ctx context.Context, data []interface{}, esConf *ElasticsearchConf) error {
+	bodies, err := buildBulkBodies(data, esConf)
+	if err != nil {
+		return err
+	}
+
+	// Workers 个协程并发写入
+	var (
+		wg       sync.WaitGroup
+		mu       sync.Mutex
+		firstErr error
+	)
+	jobs := make(chan []byte)
+	for i := 0; i < esConf.BulkConfig.Workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for body := range jobs {
+				if err := o.bulk(ctx, body); err != nil {
+					mu.Lock()
+					if firstErr == nil {
+						firstErr = err
+					}
+					mu.Unlock()
+				}
+			}
+		}()
+	}
+
+	for _, body := range bodies {
+		jobs <- body
+	}
+	close(jobs)
+	wg.Wait()
+
+	return firstErr
+}
+
+func (o *elasticsearchBulkOperator) bulk(ctx context.Context, body []byte) error {
+	respBody, err := o.client.do(ctx, http.MethodPost, "/_bulk", "application/x-ndjson", body)
+	if err != nil {
+		return err
+	}
+
+	var resp bulkResponse
+	if err := json.Unmarshal(respBody, &resp); err != nil {
+		return fmt.Errorf("decode bulk response failed: %w", err)
+	}
+	if !resp.Errors {
+		return nil
+	}
+
+	// 部分文档写入失败
+	failed, reason := 0, ""
+	for _, item := range resp.Items {
+		for _, op := range item {
+			if op.Error != nil {
+				failed++
+				if reason == "" {
+					reason = op.Error.Type + ": " + op.Error.Reason
+				}
+			}
+		}
+	}
+
+	return fmt.Errorf("%d of %d documents failed to index, first error: %s", failed, len(resp.Items), reason)
+}
+
+// buildBulkBodies 按 BulkActions 和 BulkSize 拆分成多个 bulk 请求体
+func buildBulkBodies(data []interface{}, esConf *ElasticsearchConf) ([][]byte, error) {
+	meta := map[string]map[string]string{"index": {"_index": esConf.indexName()}}
+	if esConf.DocumentType != "" {
+		meta["index"]["_type"] = esConf.DocumentType
+	}
+	action, err := json.Marshal(meta)
+	if err != nil {
+		return nil, err
+	}
+
+	var (
+		bodies  [][]byte
+		buf     bytes.Buffer
+		actions int
+	)
+	flush := func() {
+		if actions > 0 {
+			bodies = append(bodies, append([]byte(nil), buf.Bytes()...))
+			buf.Reset()
+			actions = 0
+		}
+	}
+
+	for _, d := range data {
+		doc, ok := toElasticsearchDocument(d)
+		if !ok {
+			continue
+		}
+		source, err := json.Marshal(doc)
+		if err != nil {
+			logrus.Errorf("%s: marshal document failed: %v", elasticsearchPrefix, err)
+			continue
+		}
+
+		size := len(action) + len(source) + 2
+		if actions > 0 && esConf.BulkConfig.BulkSize > 0 && buf.Len()+size > esConf.BulkConfig.BulkSize {
+			flush()
+		}
+
+		buf.Write(action)
+		buf.WriteByte('\n')
+		buf.Write(source)
+		buf.WriteByte('\n')
+		actions++
+
+		if actions >= esConf.BulkConfig.BulkActions {
+			flush()
+		}
+	}
+	flush()
+
+	return bodies, nil
+}
+
+// elasticsearchSingleOperator DisableBulk 时逐条写入
+type elasticsearchSingleOperator struct {
+	client *elasticsearchClient
+}
+
+func (o *elasticsearchSingleOperator) processData(ctx context.Context, data []interface{}, esConf *ElasticsearchConf) error {
+	docType := "_doc"
+	if esConf.DocumentType != "" {
+		docType = esConf.DocumentType
+	}
+	path := "/" + esConf.indexName() + "/" + docType
+
+	for _, d := range data {
+		doc, ok := toElasticsearchDocument(d)
+		if !ok {
+			continue
+		}
+		source, err := json.Marshal(doc)
+		if err != nil {
+			logrus.Errorf("%s: marshal document failed: %v", elasticsearchPrefix, err)
+			continue
+		}
+
+		if _, err := o.client.do(ctx, http.MethodPost, path, "application/json", source); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,108 @@
+package pumps
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"github.com/stretchr/testify/assert"
+	"iam/internal/pump/analytics"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+type fakeElasticsearch struct {
+	mu       sync.Mutex
+	paths    []string
+	actions  []map[string]map[string]string
+	docs     []elasticsearchDocument
+	username string
+	failBulk bool
+}
+
+func (es *fakeElasticsearch) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	es.mu.Lock()
+	defer es.mu.Unlock()
+
+	es.paths = append(es.paths, r.URL.Path)
+	es.username, _, _ = r.BasicAuth()
+
+	scanner := bufio.NewScanner(r.Body)
+	for scanner.Scan() {
+		line := scanner.Bytes()
+		if strings.HasPrefix(string(line), `{"index"`) {
+			var action map[string]map[string]string
+			_ = json.Unmarshal(line, &action)
+			es.actions = append(es.actions, action)
+			continue
+		}
+		var doc elasticsearchDocument
+		_ = json.Unmarshal(line, &doc)
+		es.docs = append(es.docs, doc)
+	}
+
+	if es.failBulk {
+		_, _ = w.Write([]byte(`{"errors":true,"items":[{"index":{"status":400,"error":{"type":"mapper_parsing_exception","reason":"bad doc"}}}]}`))
+		return
+	}
+	_, _ = w.Write([]byte(`{"errors":false,"items":[]}`))
+}
+
+func newTestElasticsearchPump(t *testing.T, es *fakeElasticsearch, meta map[string]interface{}) Pump {
+	srv := httptest.NewServer(es)
+	t.Cleanup(srv.Close)
+
+	meta["elasticsearch_url"] = srv.URL
+	pump := (&ElasticsearchPump{}).New()
+	assert.NoError(t, pump.Init(meta))
+
+	return pump
+}
+
+func testRecords(n int) []any {
+	records := make([]any, 0, n)
+	for i := 0; i < n; i++ {
+		records = append(records, analytics.AnalyticsRecord{CreateTime: time.Now().Unix(), Username: "colin", Effect: "allow"})
+	}
+
+	return records
+}
+
+func TestElasticsearchPump_Bulk(t *testing.T) {
+	es := &fakeElasticsearch{}
+	pump := newTestElasticsearchPump(t, es, map[string]interface{}{
+		"index_name":          "iam",
+		"rolling_index":       true,
+		"auth_basic_username": "elastic",
+		"bulk_config":         map[string]interface{}{"bulk_actions": 2, "workers": 2},
+	})
+
+	assert.NoError(t, pump.WriteData(context.Background(), testRecords(5)))
+
+	// 5 条数据拆分成 3 个 bulk 请求
+	assert.Equal(t, []string{"/_bulk", "/_bulk", "/_bulk"}, es.paths)
+	assert.Len(t, es.docs, 5)
+	assert.Equal(t, "colin", es.docs[0].Username)
+	assert.Equal(t, "iam-"+time.Now().Format("2006.01.02"), es.actions[0]["index"]["_index"])
+	assert.Equal(t, "elastic", es.username)
+}
+
+func TestElasticsearchPump_BulkError(t *testing.T) {
+	es := &fakeElasticsearch{failBulk: true}
+	pump := newTestElasticsearchPump(t, es, map[string]interface{}{})
+
+	err := pump.WriteData(context.Background(), testRecords(1))
+	assert.ErrorContains(t, err, "mapper_parsing_exception")
+}
+
+func TestElasticsearchPump_DisableBulk(t *testing.T) {
+	es := &fakeElasticsearch{}
+	pump := newTestElasticsearchPump(t, es, map[string]interface{}{"index_name": "iam", "disable_bulk": true})
+
+	assert.NoError(t, pump.WriteData(context.Background(), testRecords(2)))
+	assert.Equal(t, []string{"/iam/_doc", "/iam/_doc"}, es.paths)
+	assert.Len(t, es.docs, 2)
+}
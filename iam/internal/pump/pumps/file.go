@@ -0,0 +1,288 @@
+package pumps
+
+import (
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"fmt"
+	"github.com/sirupsen/logrus"
+	"iam/internal/pump/analytics"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	filePrefix           = "file-pump"
+	defaultFilePath      = "/var/log/iam/iam-analytics.log"
+	fileRotateTimeFormat = "20060102T150405.000000000"
+)
+
+// FilePump 将授权日志以每行一个 json 的格式写入文件, 由本地的日志采集 agent 收集
+type FilePump struct {
+	CommonPumpConfig
+	conf   FileConf
+	writer *rotateWriter
+}
+
+type FileConf struct {
+	Path           string `mapstructure:"path"`
+	MaxSize        int    `mapstructure:"max_size"`        // 单个文件最大多少 MB, 0 表示不按大小切割
+	RotateInterval int    `mapstructure:"rotate_interval"` // 多少分钟切割一次, 0 表示不按时间切割
+	MaxBackups     int    `mapstructure:"max_backups"`     // 保留的历史文件个数, 0 表示全部保留
+	Compress       bool   `mapstructure:"compress"`        // 是否 gzip 压缩切割后的文件
+}
+
+func (f *FilePump) GetName() string {
+	return "File Pump"
+}
+
+func (f *FilePump) New() Pump {
+	return &FilePump{}
+}
+
+func (f *FilePump) Init(cfg interface{}) error {
+	if err := decodeMeta(cfg, &f.conf); err != nil {
+		return err
+	}
+	if f.conf.Path == "" {
+		f.conf.Path = defaultFilePath
+	}
+
+	writer, err := newRotateWriter(f.conf.Path, int64(f.conf.MaxSize)*1024*1024,
+		time.Duration(f.conf.RotateInterval)*time.Minute, f.conf.MaxBackups, f.conf.Compress)
+	if err != nil {
+		return err
+	}
+	f.writer = writer
+
+	logrus.Infof("%s: write records to %s", filePrefix, f.conf.Path)
+
+	return nil
+}
+
+func (f *FilePump) WriteData(ctx context.Context, datas []any) error {
+	logrus.Debugf("%s: writing %d records", filePrefix, len(datas))
+
+	for _, data := range datas {
+		record, ok := data.(analytics.AnalyticsRecord)
+		if !ok {
+			continue
+		}
+
+		line, err := json.Marshal(record)
+		if err != nil {
+			logrus.Errorf("%s: marshal record failed: %v", filePrefix, err)
+			continue
+		}
+
+		if _, err := f.writer.Write(append(line, '\n')); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// rotateWriter 按大小和时间切割的文件 writer, 切割后的文件名为 name-时间.ext
+type rotateWriter struct {
+	mu         sync.Mutex
+	path       string
+	maxBytes   int64
+	interval   time.Duration
+	maxBackups int
+	compress   bool
+
+	file     *os.File
+	size     int64
+	openedAt time.Time
+	wg       sync.WaitGroup // 后台压缩
+	postMu   sync.Mutex     // 串行执行压缩和清理
+}
+
+func newRotateWriter(path string, maxBytes int64, interval time.Duration, maxBackups int, compress bool) (*rotateWriter, error) {
+	w := &rotateWriter{
+		path:       path,
+		maxBytes:   maxBytes,
+		interval:   interval,
+		maxBackups: maxBackups,
+		compress:   compress,
+	}
+
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return nil, fmt.Errorf("%s: create log dir failed: %w", filePrefix, err)
+	}
+	if err := w.open(); err != nil {
+		return nil, err
+	}
+
+	return w, nil
+}
+
+func (w *rotateWriter) open() error {
+	file, err := os.OpenFile(w.path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o644)
+	if err != nil {
+		return fmt.Errorf("%s: open %s failed: %w", filePrefix, w.path, err)
+	}
+
+	info, err := file.Stat()
+	if err != nil {
+		_ = file.Close()
+		return err
+	}
+
+	w.file, w.size, w.openedAt = file, info.Size(), time.Now()
+
+	return nil
+}
+
+func (w *rotateWriter) Write(p []byte) (int, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if w.shouldRotate(int64(len(p))) {
+		if err := w.rotate(); err != nil {
+			return 0, err
+		}
+	}
+
+	n, err := w.file.Write(p)
+	w.size += int64(n)
+
+	return n, err
+}
+
+func (w *rotateWriter) shouldRotate(next int64) bool {
+	if w.size == 0 {
+		return false
+	}
+	if w.maxBytes > 0 && w.size+next > w.maxBytes {
+		return true
+	}
+
+	return w.interval > 0 && time.Since(w.openedAt) >= w.interval
+}
+
+// rotate 重命名当前文件并重新打开, 压缩和清理在后台进行
+func (w *rotateWriter) rotate() error {
+	if err := w.file.Close(); err != nil {
+		return err
+	}
+
+	ext := filepath.Ext(w.path)
+	backup := fmt.Sprintf("%s-%s%s", strings.TrimSuffix(w.path, ext), time.Now().Format(fileRotateTimeFormat), ext)
+	if err := os.Rename(w.path, backup); err != nil {
+		return fmt.Errorf("%s: rotate %s failed: %w", filePrefix, w.path, err)
+	}
+
+	if err := w.open(); err != nil {
+		return err
+	}
+
+	w.wg.Add(1)
+	go func() {
+		defer w.wg.Done()
+		w.postRotate(backup)
+	}()
+
+	return nil
+}
+
+func (w *rotateWriter) postRotate(backup string) {
+	w.postMu.Lock()
+	defer w.postMu.Unlock()
+
+	if w.compress {
+		if err := gzipFile(backup); err != nil {
+			logrus.Errorf("%s: compress %s failed: %v", filePrefix, backup, err)
+		}
+	}
+
+	if w.maxBackups > 0 {
+		w.removeOldBackups()
+	}
+}
+
+// removeOldBackups 只保留最新的 maxBackups 个历史文件
+func (w *rotateWriter) removeOldBackups() {
+	entries, err := os.ReadDir(filepath.Dir(w.path))
+	if err != nil {
+		return
+	}
+
+	type backupFile struct {
+		path string
+		at   time.Time
+	}
+	var backups []backupFile
+	for _, entry := range entries {
+		if entry.IsDir() {
+			continue
+		}
+		if at, ok := w.backupTime(entry.Name()); ok {
+			backups = append(backups, backupFile{path: filepath.Join(filepath.Dir(w.path), entry.Name()), at: at})
+		}
+	}
+
+	sort.Slice(backups, func(i, j int) bool { return backups[i].at.Before(backups[j].at) })
+	for i := 0; i < len(backups)-w.maxBackups; i++ {
+		if err := os.Remove(backups[i].path); err != nil {
+			logrus.Warnf("%s: remove %s failed: %v", filePrefix, backups[i].path, err)
+		}
+	}
+}
+
+// backupTime 解析 <base>-<time><ext>[.gz] 形式的历史文件名, 其它文件一律不认
+func (w *rotateWriter) backupTime(name string) (time.Time, bool) {
+	ext := filepath.Ext(w.path)
+	prefix := strings.TrimSuffix(filepath.Base(w.path), ext) + "-"
+	if !strings.HasPrefix(name, prefix) {
+		return time.Time{}, false
+	}
+
+	stamp := strings.TrimPrefix(name, prefix)
+	stamp = strings.TrimSuffix(stamp, ".gz")
+	if !strings.HasSuffix(stamp, ext) {
+		return time.Time{}, false
+	}
+	stamp = strings.TrimSuffix(stamp, ext)
+
+	at, err := time.Parse(fileRotateTimeFormat, stamp)
+	if err != nil {
+		return time.Time{}, false
+	}
+
+	return at, true
+}
+
+func gzipFile(path string) error {
+	src, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer src.Close()
+
+	dst, err := os.OpenFile(path+".gz", os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0o644)
+	if err != nil {
+		return err
+	}
+
+	gz := gzip.NewWriter(dst)
+	if _, err := io.Copy(gz, src); err != nil {
+		_ = dst.Close()
+		return err
+	}
+	if err := gz.Close(); err != nil {
+		_ = dst.Close()
+		return err
+	}
+	if err := dst.Close(); err != nil {
+		return err
+	}
+
+	return os.Remove(path)
+}
@@ -0,0 +1,92 @@
+package pumps
+
+import (
+	"bufio"
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"github.com/stretchr/testify/assert"
+	"iam/internal/pump/analytics"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestFilePump_WriteData(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "analytics.log")
+	pump := (&FilePump{}).New()
+	assert.NoError(t, pump.Init(map[string]interface{}{"path": path}))
+
+	assert.NoError(t, pump.WriteData(context.Background(), testRecords(2)))
+
+	file, err := os.Open(path)
+	assert.NoError(t, err)
+	defer file.Close()
+
+	lines := 0
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		var record analytics.AnalyticsRecord
+		assert.NoError(t, json.Unmarshal(scanner.Bytes(), &record))
+		assert.Equal(t, "colin", record.Username)
+		lines++
+	}
+	assert.Equal(t, 2, lines)
+}
+
+func TestRotateWriter(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "analytics.log")
+
+	w, err := newRotateWriter(path, 10, 0, 2, true)
+	assert.NoError(t, err)
+
+	// 每次写入都超过 maxBytes, 第 2 次开始每次都会切割
+	for i := 0; i < 4; i++ {
+		_, err := w.Write([]byte("0123456789\n"))
+		assert.NoError(t, err)
+	}
+	w.wg.Wait()
+
+	backups, _ := filepath.Glob(filepath.Join(dir, "analytics-*"))
+	assert.Len(t, backups, 2)
+	for _, backup := range backups {
+		assert.True(t, strings.HasSuffix(backup, ".log.gz"))
+
+		f, err := os.Open(backup)
+		assert.NoError(t, err)
+		gz, err := gzip.NewReader(f)
+		assert.NoError(t, err)
+		content := make([]byte, 11)
+		_, err = gz.Read(content)
+		assert.Equal(t, "0123456789\n", string(content))
+		_ = f.Close()
+	}
+}
+
+func TestRotateWriter_KeepUnrelatedFiles(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "audit.json")
+
+	// 和历史文件同前缀但不是切割产生的文件, 不能被清理
+	unrelated := []string{"audit-old.json", "audit-20200101.json", "audit-20200101T000000.000000000.log"}
+	for _, name := range unrelated {
+		assert.NoError(t, os.WriteFile(filepath.Join(dir, name), []byte("keep"), 0o644))
+	}
+
+	w, err := newRotateWriter(path, 10, 0, 1, false)
+	assert.NoError(t, err)
+	for i := 0; i < 4; i++ {
+		_, err := w.Write([]byte("0123456789\n"))
+		assert.NoError(t, err)
+	}
+	w.wg.Wait()
+
+	for _, name := range unrelated {
+		assert.FileExists(t, filepath.Join(dir, name))
+	}
+
+	backups, _ := filepath.Glob(filepath.Join(dir, "audit-*T*.json"))
+	assert.Len(t, backups, 1)
+}
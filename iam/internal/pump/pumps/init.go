@@ -1,5 +1,7 @@
 package pumps
 
+import "fmt"
+
 var pumpsTypes map[string]Pump // 根据情况进行选择对应的下游组件
 
 func init() {
@@ -7,7 +9,21 @@ func init() {
 
 	// 根据需要添加组件
 
-	//pumpsTypes["elasticsearch"] = &ElasticsearchPump{}
-	//pumpsTypes["kafka"] = &KafkaPump{}
+	pumpsTypes["elasticsearch"] = &ElasticsearchPump{}
+	pumpsTypes["kafka"] = &KafkaPump{}
+	pumpsTypes["mysql"] = &MysqlPump{}
+	pumpsTypes["prometheus"] = &PrometheusPump{}
+	pumpsTypes["file"] = &FilePump{}
+	pumpsTypes["syslog"] = &SyslogPump{}
+	pumpsTypes["webhook"] = &WebhookPump{}
+
+}
+
+// GetPumpByName 根据类型获取对应的 pump
+func GetPumpByName(name string) (Pump, error) {
+	if pump, ok := pumpsTypes[name]; ok && pump != nil {
+		return pump, nil
+	}
 
+	return nil, fmt.Errorf("%s pump not found", name)
 }
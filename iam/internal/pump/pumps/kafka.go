@@ -0,0 +1,213 @@
+package pumps
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/json"
+	"fmt"
+	"github.com/segmentio/kafka-go"
+	"github.com/segmentio/kafka-go/sasl"
+	"github.com/segmentio/kafka-go/sasl/plain"
+	"github.com/segmentio/kafka-go/sasl/scram"
+	"github.com/sirupsen/logrus"
+	"iam/internal/pump/analytics"
+	"os"
+	"strings"
+	"time"
+)
+
+const kafkaPrefix = "kafka-pump"
+
+// kafkaWriter 抽象 kafka 写入, 便于测试时替换
+type kafkaWriter interface {
+	WriteMessages(ctx context.Context, msgs ...kafka.Message) error
+}
+
+// KafkaPump 将授权日志写入 kafka, 以 username 作为 key, 同一用户的记录写入同一分区保证顺序
+type KafkaPump struct {
+	CommonPumpConfig
+	kafkaConf KafkaConf
+	writer    kafkaWriter
+}
+
+type KafkaConf struct {
+	Broker                []string `mapstructure:"broker"`
+	ClientID              string   `mapstructure:"client_id"`
+	Topic                 string   `mapstructure:"topic"`
+	Timeout               int      `mapstructure:"timeout"`     // 连接和读写超时时间(秒)
+	Compression           string   `mapstructure:"compression"` // none gzip snappy lz4 zstd
+	BatchSize             int      `mapstructure:"batch_size"`
+	UseSSL                bool     `mapstructure:"use_ssl"`
+	SSLCAFile             string   `mapstructure:"ssl_ca_file"`
+	SSLCertFile           string   `mapstructure:"ssl_cert_file"`
+	SSLKeyFile            string   `mapstructure:"ssl_key_file"`
+	SSLInsecureSkipVerify bool     `mapstructure:"ssl_insecure_skip_verify"`
+	SASLMechanism         string   `mapstructure:"sasl_mechanism"` // plain scram
+	Username              string   `mapstructure:"sasl_username"`
+	Password              string   `mapstructure:"sasl_password"`
+	Algorithm             string   `mapstructure:"sasl_algorithm"` // scram 使用的算法 sha-256 sha-512
+}
+
+func (k *KafkaPump) GetName() string {
+	return "Kafka Pump"
+}
+
+func (k *KafkaPump) New() Pump {
+	return &KafkaPump{}
+}
+
+func (k *KafkaPump) Init(cfg interface{}) error {
+	if err := decodeMeta(cfg, &k.kafkaConf); err != nil {
+		return err
+	}
+
+	writer, err := newKafkaWriter(&k.kafkaConf)
+	if err != nil {
+		return err
+	}
+	k.writer = writer
+
+	logrus.Infof("%s: topic %s, brokers %v", kafkaPrefix, k.kafkaConf.Topic, k.kafkaConf.Broker)
+
+	return nil
+}
+
+func (k *KafkaPump) WriteData(ctx context.Context, datas []any) error {
+	logrus.Debugf("%s: writing %d records", kafkaPrefix, len(datas))
+
+	messages := make([]kafka.Message, 0, len(datas))
+	for _, data := range datas {
+		record, ok := data.(analytics.AnalyticsRecord)
+		if !ok {
+			continue
+		}
+
+		value, err := json.Marshal(record)
+		if err != nil {
+			logrus.Errorf("%s: marshal record failed: %v", kafkaPrefix, err)
+			continue
+		}
+
+		messages = append(messages, kafka.Message{
+			Key:   []byte(record.Username),
+			Value: value,
+			Time:  time.Unix(record.CreateTime, 0),
+		})
+	}
+
+	if len(messages) == 0 {
+		return nil
+	}
+
+	return k.writer.WriteMessages(ctx, messages...)
+}
+
+// newKafkaWriter 根据配置创建 kafka writer
+func newKafkaWriter(conf *KafkaConf) (*kafka.Writer, error) {
+	if len(conf.Broker) == 0 {
+		return nil, fmt.Errorf("%s: broker must be set", kafkaPrefix)
+	}
+	if conf.Topic == "" {
+		return nil, fmt.Errorf("%s: topic must be set", kafkaPrefix)
+	}
+
+	compression, err := kafkaCompression(conf.Compression)
+	if err != nil {
+		return nil, err
+	}
+
+	timeout := 10 * time.Second
+	if conf.Timeout > 0 {
+		timeout = time.Duration(conf.Timeout) * time.Second
+	}
+
+	transport := &kafka.Transport{
+		ClientID:    conf.ClientID,
+		DialTimeout: timeout,
+	}
+
+	if conf.UseSSL {
+		if transport.TLS, err = kafkaTLSConfig(conf); err != nil {
+			return nil, err
+		}
+	}
+
+	if conf.SASLMechanism != "" {
+		if transport.SASL, err = kafkaSASLMechanism(conf); err != nil {
+			return nil, err
+		}
+	}
+
+	return &kafka.Writer{
+		Addr:         kafka.TCP(conf.Broker...),
+		Topic:        conf.Topic,
+		Balancer:     &kafka.Hash{}, // 相同 key 写入同一个分区
+		BatchSize:    conf.BatchSize,
+		BatchTimeout: 10 * time.Millisecond,
+		ReadTimeout:  timeout,
+		WriteTimeout: timeout,
+		RequiredAcks: kafka.RequireAll,
+		Compression:  compression,
+		Transport:    transport,
+	}, nil
+}
+
+func kafkaCompression(name string) (kafka.Compression, error) {
+	switch strings.ToLower(name) {
+	case "", "none":
+		return 0, nil
+	case "gzip":
+		return kafka.Gzip, nil
+	case "snappy":
+		return kafka.Snappy, nil
+	case "lz4":
+		return kafka.Lz4, nil
+	case "zstd":
+		return kafka.Zstd, nil
+	default:
+		return 0, fmt.Errorf("%s: unknown compression %s", kafkaPrefix, name)
+	}
+}
+
+func kafkaTLSConfig(conf *KafkaConf) (*tls.Config, error) {
+	tlsConfig := &tls.Config{InsecureSkipVerify: conf.SSLInsecureSkipVerify}
+
+	if conf.SSLCAFile != "" {
+		ca, err := os.ReadFile(conf.SSLCAFile)
+		if err != nil {
+			return nil, fmt.Errorf("%s: read ca file failed: %w", kafkaPrefix, err)
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(ca) {
+			return nil, fmt.Errorf("%s: no certificate found in %s", kafkaPrefix, conf.SSLCAFile)
+		}
+		tlsConfig.RootCAs = pool
+	}
+
+	if conf.SSLCertFile != "" && conf.SSLKeyFile != "" {
+		cert, err := tls.LoadX509KeyPair(conf.SSLCertFile, conf.SSLKeyFile)
+		if err != nil {
+			return nil, fmt.Errorf("%s: load client certificate failed: %w", kafkaPrefix, err)
+		}
+		tlsConfig.Certificates = []tls.Certificate{cert}
+	}
+
+	return tlsConfig, nil
+}
+
+func kafkaSASLMechanism(conf *KafkaConf) (sasl.Mechanism, error) {
+	switch strings.ToLower(conf.SASLMechanism) {
+	case "plain":
+		return plain.Mechanism{Username: conf.Username, Password: conf.Password}, nil
+	case "scram":
+		algorithm := scram.SHA256
+		if strings.ToLower(conf.Algorithm) == "sha-512" {
+			algorithm = scram.SHA512
+		}
+
+		return scram.Mechanism(algorithm, conf.Username, conf.Password)
+	default:
+		return nil, fmt.Errorf("%s: unknown sasl mechanism %s", kafkaPrefix, conf.SASLMechanism)
+	}
+}
@@ -0,0 +1,63 @@
+package pumps
+
+import (
+	"context"
+	"encoding/json"
+	"github.com/segmentio/kafka-go"
+	"github.com/stretchr/testify/assert"
+	"iam/internal/pump/analytics"
+	"testing"
+)
+
+type fakeKafkaWriter struct {
+	messages []kafka.Message
+}
+
+func (w *fakeKafkaWriter) WriteMessages(_ context.Context, msgs ...kafka.Message) error {
+	w.messages = append(w.messages, msgs...)
+	return nil
+}
+
+func TestKafkaPump_WriteData(t *testing.T) {
+	writer := &fakeKafkaWriter{}
+	pump := &KafkaPump{writer: writer}
+
+	err := pump.WriteData(context.Background(), []any{
+		analytics.AnalyticsRecord{Username: "colin", Effect: "allow"},
+		analytics.AnalyticsRecord{Username: "admin", Effect: "deny"},
+	})
+	assert.NoError(t, err)
+	assert.Len(t, writer.messages, 2)
+
+	// 以 username 作为 key
+	assert.Equal(t, "colin", string(writer.messages[0].Key))
+	assert.Equal(t, "admin", string(writer.messages[1].Key))
+
+	var record analytics.AnalyticsRecord
+	assert.NoError(t, json.Unmarshal(writer.messages[1].Value, &record))
+	assert.Equal(t, "deny", record.Effect)
+}
+
+func TestNewKafkaWriter(t *testing.T) {
+	writer, err := newKafkaWriter(&KafkaConf{
+		Broker:        []string{"127.0.0.1:9092"},
+		Topic:         "iam-analytics",
+		Compression:   "snappy",
+		BatchSize:     50,
+		SASLMechanism: "scram",
+		Algorithm:     "sha-512",
+		Username:      "iam",
+		Password:      "secret",
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, kafka.Snappy, writer.Compression)
+	assert.Equal(t, 50, writer.BatchSize)
+	assert.IsType(t, &kafka.Hash{}, writer.Balancer)
+	assert.Equal(t, "SCRAM-SHA-512", writer.Transport.(*kafka.Transport).SASL.Name())
+
+	_, err = newKafkaWriter(&KafkaConf{Broker: []string{"127.0.0.1:9092"}, Topic: "t", Compression: "brotli"})
+	assert.Error(t, err)
+
+	_, err = newKafkaWriter(&KafkaConf{Topic: "t"})
+	assert.Error(t, err)
+}
@@ -0,0 +1,176 @@
+package pumps
+
+import (
+	"context"
+	"fmt"
+	"github.com/sirupsen/logrus"
+	"gorm.io/gorm"
+	"iam/internal/pump/analytics"
+	"iam/pkg/db"
+	"time"
+)
+
+const (
+	mysqlPrefix            = "mysql-pump"
+	defaultMysqlTableName  = "iam_analytics"
+	defaultMysqlBatchSize  = 500
+	mysqlPurgeInterval     = time.Hour
+	mysqlPurgeBatchSize    = 10000 // 每次最多删除的行数, 避免大事务
+	defaultMysqlMaxConnAge = 10 * time.Second
+)
+
+// MysqlPump 将授权日志写入 mysql, 用于长期保存和合规报表
+type MysqlPump struct {
+	CommonPumpConfig
+	mysqlConf MysqlConf
+	db        *gorm.DB
+}
+
+type MysqlConf struct {
+	Host               string `mapstructure:"host"`
+	Username           string `mapstructure:"username"`
+	Password           string `mapstructure:"password"`
+	Database           string `mapstructure:"database"`
+	MaxIdleConnections int    `mapstructure:"max_idle_connections"`
+	MaxOpenConnections int    `mapstructure:"max_open_connections"`
+	TableName          string `mapstructure:"table_name"`
+	BatchSize          int    `mapstructure:"batch_size"`     // 批量插入的条数
+	RetentionDays      int    `mapstructure:"retention_days"` // 保留天数, 0 表示不清理
+}
+
+// mysqlAnalyticsRecord 表结构
+type mysqlAnalyticsRecord struct {
+	ID         uint64    `gorm:"primaryKey;autoIncrement"`
+	CreateTime time.Time `gorm:"index;not null"`
+	Username   string    `gorm:"type:varchar(255);index;not null"`
+	Effect     string    `gorm:"type:varchar(16);not null"`
+	Conclusion string    `gorm:"type:varchar(1024)"`
+	Request    string    `gorm:"type:text"`
+	Policies   string    `gorm:"type:mediumtext"`
+	Deciders   string    `gorm:"type:mediumtext"`
+	ExpireAt   time.Time
+}
+
+func (m *MysqlPump) GetName() string {
+	return "MySQL Pump"
+}
+
+func (m *MysqlPump) New() Pump {
+	return &MysqlPump{}
+}
+
+func (m *MysqlPump) Init(cfg interface{}) error {
+	if err := decodeMeta(cfg, &m.mysqlConf); err != nil {
+		return err
+	}
+	m.setDefaults()
+
+	gormDb, err := db.NewDb(db.Options{
+		Host:                  m.mysqlConf.Host,
+		Username:              m.mysqlConf.Username,
+		Password:              m.mysqlConf.Password,
+		Database:              m.mysqlConf.Database,
+		MaxIdleConnections:    m.mysqlConf.MaxIdleConnections,
+		MaxOpenConnections:    m.mysqlConf.MaxOpenConnections,
+		MaxConnectionLifeTime: defaultMysqlMaxConnAge,
+	})
+	if err != nil {
+		return fmt.Errorf("%s: connect mysql failed: %w", mysqlPrefix, err)
+	}
+	m.db = gormDb
+
+	// 自动创建表结构
+	if err := m.table().AutoMigrate(&mysqlAnalyticsRecord{}); err != nil {
+		return fmt.Errorf("%s: create table %s failed: %w", mysqlPrefix, m.mysqlConf.TableName, err)
+	}
+
+	if m.mysqlConf.RetentionDays > 0 {
+		go m.purgeLoop()
+	}
+
+	logrus.Infof("%s: table %s, retention %d days", mysqlPrefix, m.mysqlConf.TableName, m.mysqlConf.RetentionDays)
+
+	return nil
+}
+
+func (m *MysqlPump) setDefaults() {
+	if m.mysqlConf.TableName == "" {
+		m.mysqlConf.TableName = defaultMysqlTableName
+	}
+	if m.mysqlConf.BatchSize <= 0 {
+		m.mysqlConf.BatchSize = defaultMysqlBatchSize
+	}
+	if m.mysqlConf.MaxIdleConnections <= 0 {
+		m.mysqlConf.MaxIdleConnections = 10
+	}
+	if m.mysqlConf.MaxOpenConnections <= 0 {
+		m.mysqlConf.MaxOpenConnections = 20
+	}
+}
+
+func (m *MysqlPump) table() *gorm.DB {
+	return m.db.Table(m.mysqlConf.TableName)
+}
+
+func (m *MysqlPump) WriteData(ctx context.Context, datas []any) error {
+	logrus.Debugf("%s: writing %d records", mysqlPrefix, len(datas))
+
+	rows := make([]*mysqlAnalyticsRecord, 0, len(datas))
+	for _, data := range datas {
+		record, ok := data.(analytics.AnalyticsRecord)
+		if !ok {
+			continue
+		}
+
+		rows = append(rows, &mysqlAnalyticsRecord{
+			CreateTime: time.Unix(record.CreateTime, 0),
+			Username:   record.Username,
+			Effect:     record.Effect,
+			Conclusion: record.Conclusion,
+			Request:    record.Request,
+			Policies:   record.Policies,
+			Deciders:   record.Deciders,
+			ExpireAt:   record.ExpireAt,
+		})
+	}
+
+	if len(rows) == 0 {
+		return nil
+	}
+
+	return m.table().WithContext(ctx).CreateInBatches(rows, m.mysqlConf.BatchSize).Error
+}
+
+// purgeLoop 定时删除超过保留天数的记录
+func (m *MysqlPump) purgeLoop() {
+	ticker := time.NewTicker(mysqlPurgeInterval)
+	defer ticker.Stop()
+
+	for {
+		before := time.Now().AddDate(0, 0, -m.mysqlConf.RetentionDays)
+		if deleted, err := m.purgeExpired(context.Background(), before); err != nil {
+			logrus.Errorf("%s: purge records before %s failed: %v", mysqlPrefix, before, err)
+		} else if deleted > 0 {
+			logrus.Infof("%s: purged %d records before %s", mysqlPrefix, deleted, before)
+		}
+
+		<-ticker.C
+	}
+}
+
+// purgeExpired 分批删除 before 之前的记录, 返回删除的行数
+func (m *MysqlPump) purgeExpired(ctx context.Context, before time.Time) (int64, error) {
+	var total int64
+	query := fmt.Sprintf("DELETE FROM `%s` WHERE `create_time` < ? LIMIT ?", m.mysqlConf.TableName)
+	for {
+		result := m.db.WithContext(ctx).Exec(query, before, mysqlPurgeBatchSize)
+		if result.Error != nil {
+			return total, result.Error
+		}
+		total += result.RowsAffected
+
+		if result.RowsAffected < mysqlPurgeBatchSize {
+			return total, nil
+		}
+	}
+}
@@ -0,0 +1,59 @@
+package pumps
+
+import (
+	"context"
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/stretchr/testify/assert"
+	"gorm.io/driver/mysql"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+	"iam/internal/pump/analytics"
+	"testing"
+	"time"
+)
+
+func newTestMysqlPump(t *testing.T, conf MysqlConf) (*MysqlPump, sqlmock.Sqlmock) {
+	sqlDB, mock, err := sqlmock.New()
+	assert.NoError(t, err)
+	t.Cleanup(func() { _ = sqlDB.Close() })
+
+	gormDb, err := gorm.Open(mysql.New(mysql.Config{Conn: sqlDB, SkipInitializeWithVersion: true}),
+		&gorm.Config{Logger: logger.Discard, SkipDefaultTransaction: true})
+	assert.NoError(t, err)
+
+	pump := &MysqlPump{mysqlConf: conf, db: gormDb}
+	pump.setDefaults()
+
+	return pump, mock
+}
+
+func TestMysqlPump_WriteData(t *testing.T) {
+	pump, mock := newTestMysqlPump(t, MysqlConf{BatchSize: 2})
+
+	// 3 条记录分 2 批插入
+	mock.ExpectExec("INSERT INTO `iam_analytics`").WillReturnResult(sqlmock.NewResult(2, 2))
+	mock.ExpectExec("INSERT INTO `iam_analytics`").WillReturnResult(sqlmock.NewResult(3, 1))
+
+	records := make([]any, 0, 3)
+	for i := 0; i < 3; i++ {
+		records = append(records, analytics.AnalyticsRecord{CreateTime: time.Now().Unix(), Username: "colin", Effect: "allow"})
+	}
+
+	assert.NoError(t, pump.WriteData(context.Background(), records))
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestMysqlPump_PurgeExpired(t *testing.T) {
+	pump, mock := newTestMysqlPump(t, MysqlConf{TableName: "authz_history"})
+	before := time.Now().AddDate(0, 0, -30)
+
+	mock.ExpectExec("DELETE FROM `authz_history` WHERE `create_time` < \\? LIMIT \\?").
+		WithArgs(before, mysqlPurgeBatchSize).WillReturnResult(sqlmock.NewResult(0, mysqlPurgeBatchSize))
+	mock.ExpectExec("DELETE FROM `authz_history`").
+		WithArgs(before, mysqlPurgeBatchSize).WillReturnResult(sqlmock.NewResult(0, 3))
+
+	deleted, err := pump.purgeExpired(context.Background(), before)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(mysqlPurgeBatchSize+3), deleted)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
@@ -0,0 +1,187 @@
+package pumps
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"github.com/sirupsen/logrus"
+	"iam/internal/pump/analytics"
+	"net/http"
+	"strings"
+	"sync"
+)
+
+const (
+	prometheusPrefix         = "prometheus-pump"
+	defaultPrometheusAddr    = "0.0.0.0:9091"
+	defaultPrometheusPath    = "/metrics"
+	defaultPrometheusMaxUser = 1000
+	defaultPrometheusMaxRes  = 100
+	// 超过 max_usernames 或 max_resources 后新出现的值统一记为该值
+	prometheusOther = "__other__"
+	// 没有 conclusion_code 的记录
+	conclusionDeny = "deny"
+)
+
+// PrometheusPump 不转发原始记录, 而是聚合成计数器, 通过独立的 /metrics 暴露
+type PrometheusPump struct {
+	CommonPumpConfig
+	conf     PrometheusConf
+	registry *prometheus.Registry
+	records  *prometheus.CounterVec
+
+	mu        sync.Mutex
+	usernames boundedValues // 已经作为 label 的用户
+	resources boundedValues // 已经作为 label 的 resource 前缀, 由调用方决定, 同样需要限制
+}
+
+type PrometheusConf struct {
+	Addr                   string `mapstructure:"listen_address"`
+	Path                   string `mapstructure:"path"`
+	MaxUsernames           int    `mapstructure:"max_usernames"`            // username label 最多的取值个数
+	MaxResources           int    `mapstructure:"max_resources"`            // resource label 最多的取值个数
+	ResourcePrefixSegments int    `mapstructure:"resource_prefix_segments"` // 大于 0 时增加 resource label, 取 resource 以 ':' 分隔的前 n 段
+}
+
+func (p *PrometheusPump) GetName() string {
+	return "Prometheus Pump"
+}
+
+func (p *PrometheusPump) New() Pump {
+	return &PrometheusPump{}
+}
+
+func (p *PrometheusPump) Init(cfg interface{}) error {
+	if err := decodeMeta(cfg, &p.conf); err != nil {
+		return err
+	}
+	p.initMetrics()
+
+	mux := http.NewServeMux()
+	mux.Handle(p.conf.Path, promhttp.HandlerFor(p.registry, promhttp.HandlerOpts{}))
+	go func() {
+		if err := http.ListenAndServe(p.conf.Addr, mux); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			logrus.Errorf("%s: serve metrics on %s failed: %v", prometheusPrefix, p.conf.Addr, err)
+		}
+	}()
+
+	logrus.Infof("%s: serve metrics on %s%s", prometheusPrefix, p.conf.Addr, p.conf.Path)
+
+	return nil
+}
+
+// initMetrics 补全默认配置并注册指标
+func (p *PrometheusPump) initMetrics() {
+	if p.conf.Addr == "" {
+		p.conf.Addr = defaultPrometheusAddr
+	}
+	if p.conf.Path == "" {
+		p.conf.Path = defaultPrometheusPath
+	}
+	if p.conf.MaxUsernames <= 0 {
+		p.conf.MaxUsernames = defaultPrometheusMaxUser
+	}
+	if p.conf.MaxResources <= 0 {
+		p.conf.MaxResources = defaultPrometheusMaxRes
+	}
+
+	labels := []string{"username", "effect", "conclusion"}
+	if p.conf.ResourcePrefixSegments > 0 {
+		labels = append(labels, "resource")
+	}
+
+	p.usernames = boundedValues{max: p.conf.MaxUsernames, values: make(map[string]struct{})}
+	p.resources = boundedValues{max: p.conf.MaxResources, values: make(map[string]struct{})}
+	p.registry = prometheus.NewRegistry()
+	p.records = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "iam_authorization_records_total",
+		Help: "Total number of authorization records by username, effect and conclusion.",
+	}, labels)
+	p.registry.MustRegister(p.records)
+}
+
+func (p *PrometheusPump) WriteData(ctx context.Context, datas []any) error {
+	logrus.Debugf("%s: aggregating %d records", prometheusPrefix, len(datas))
+
+	for _, data := range datas {
+		record, ok := data.(analytics.AnalyticsRecord)
+		if !ok {
+			continue
+		}
+
+		labels := []string{p.usernameLabel(record.Username), record.Effect, conclusionLabel(record)}
+		if p.conf.ResourcePrefixSegments > 0 {
+			labels = append(labels, p.resourceLabel(resourcePrefix(record.Request, p.conf.ResourcePrefixSegments)))
+		}
+		p.records.WithLabelValues(labels...).Inc()
+	}
+
+	return nil
+}
+
+// boundedValues 限制 label 的取值个数, 超出后新出现的值统一记为 __other__
+type boundedValues struct {
+	max    int
+	values map[string]struct{}
+}
+
+func (b *boundedValues) label(value string) string {
+	if _, ok := b.values[value]; ok {
+		return value
+	}
+	if len(b.values) >= b.max {
+		return prometheusOther
+	}
+	b.values[value] = struct{}{}
+
+	return value
+}
+
+// usernameLabel 限制 username label 的取值个数, 避免单个租户的大量用户撑爆指标
+func (p *PrometheusPump) usernameLabel(username string) string {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	return p.usernames.label(username)
+}
+
+// resourceLabel 限制 resource label 的取值个数, resource 来自调用方的请求, 不限制时可以构造任意多的时间序列
+func (p *PrometheusPump) resourceLabel(resource string) string {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	return p.resources.label(resource)
+}
+
+// conclusionLabel 使用 authzserver 记录的 conclusion_code, 旧版本没有该字段的记录只按 effect 区分
+func conclusionLabel(record analytics.AnalyticsRecord) string {
+	switch {
+	case record.ConclusionCode == analytics.ConclusionAllow,
+		record.ConclusionCode == analytics.ConclusionForcedDeny,
+		record.ConclusionCode == analytics.ConclusionNoMatch:
+		return record.ConclusionCode
+	case record.Effect == analytics.ConclusionAllow:
+		return analytics.ConclusionAllow
+	default:
+		return conclusionDeny
+	}
+}
+
+// resourcePrefix 从序列化的 ladon 请求中取出 resource 的前 n 段, 未记录请求时返回空
+func resourcePrefix(request string, segments int) string {
+	var r struct {
+		Resource string `json:"resource"`
+	}
+	if request == "" || json.Unmarshal([]byte(request), &r) != nil {
+		return ""
+	}
+
+	parts := strings.SplitN(r.Resource, ":", segments+1)
+	if len(parts) > segments {
+		parts = parts[:segments]
+	}
+
+	return strings.Join(parts, ":")
+}
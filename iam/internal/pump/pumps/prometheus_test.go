@@ -0,0 +1,62 @@
+package pumps
+
+import (
+	"context"
+	"fmt"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+	"iam/internal/pump/analytics"
+	"testing"
+)
+
+func TestPrometheusPump_WriteData(t *testing.T) {
+	pump := &PrometheusPump{conf: PrometheusConf{MaxUsernames: 2, MaxResources: 1, ResourcePrefixSegments: 2}}
+	pump.initMetrics()
+
+	request := `{"resource":"resources:articles:ladon-introduction","action":"delete"}`
+	allow := analytics.AnalyticsRecord{Username: "colin", Effect: "allow", ConclusionCode: analytics.ConclusionAllow, Request: request}
+	noMatch := analytics.AnalyticsRecord{Effect: "deny", ConclusionCode: analytics.ConclusionNoMatch}
+	admin, tom, jerry := noMatch, noMatch, noMatch
+	admin.Username, tom.Username, jerry.Username = "admin", "tom", "jerry"
+	err := pump.WriteData(context.Background(), []any{allow, allow, admin, tom, jerry})
+	assert.NoError(t, err)
+
+	allowed := pump.records.WithLabelValues("colin", "allow", analytics.ConclusionAllow, "resources:articles")
+	assert.Equal(t, float64(2), testutil.ToFloat64(allowed))
+
+	// 超过 max_usernames 的用户合并为 __other__, 超过 max_resources 的 resource 同样合并
+	other := pump.records.WithLabelValues(prometheusOther, "deny", analytics.ConclusionNoMatch, prometheusOther)
+	assert.Equal(t, float64(2), testutil.ToFloat64(other))
+	assert.Equal(t, 3, testutil.CollectAndCount(pump.records))
+
+	// 调用方构造的 resource 不会产生新的时间序列
+	for i := 0; i < 10; i++ {
+		record := allow
+		record.Request = fmt.Sprintf(`{"resource":"r%d:x"}`, i)
+		assert.NoError(t, pump.WriteData(context.Background(), []any{record}))
+	}
+	assert.Equal(t, 4, testutil.CollectAndCount(pump.records))
+	assert.Equal(t, float64(10), testutil.ToFloat64(pump.records.WithLabelValues("colin", "allow", analytics.ConclusionAllow, prometheusOther)))
+}
+
+func TestConclusionLabel(t *testing.T) {
+	tests := map[string]analytics.AnalyticsRecord{
+		analytics.ConclusionAllow:      {Effect: "allow", ConclusionCode: analytics.ConclusionAllow},
+		analytics.ConclusionForcedDeny: {Effect: "deny", ConclusionCode: analytics.ConclusionForcedDeny},
+		analytics.ConclusionNoMatch:    {Effect: "deny", ConclusionCode: analytics.ConclusionNoMatch},
+	}
+	for want, record := range tests {
+		assert.Equal(t, want, conclusionLabel(record))
+	}
+
+	// 不依赖 conclusion 的描述, 没有 conclusion_code 时只按 effect 区分
+	assert.Equal(t, conclusionDeny, conclusionLabel(analytics.AnalyticsRecord{Effect: "deny", Conclusion: "policy p2 forcefully denied the access"}))
+	assert.Equal(t, analytics.ConclusionAllow, conclusionLabel(analytics.AnalyticsRecord{Effect: "allow"}))
+	assert.Equal(t, conclusionDeny, conclusionLabel(analytics.AnalyticsRecord{Effect: "deny", ConclusionCode: "unknown"}))
+}
+
+func TestResourcePrefix(t *testing.T) {
+	assert.Equal(t, "resources", resourcePrefix(`{"resource":"resources:articles"}`, 1))
+	assert.Equal(t, "resources:articles", resourcePrefix(`{"resource":"resources:articles"}`, 3))
+	assert.Equal(t, "", resourcePrefix("", 1))
+}
@@ -1,17 +1,20 @@
 package pumps
 
-import "iam/internal/pump/analytics"
+import (
+	"context"
+	"iam/internal/pump/analytics"
+)
 
 // Pump 分析接口，抽象成接口 , 支持不同上报服务，可提供以插件的方式
 type Pump interface {
 	GetName() string
 	New() Pump
-	Init(interface{}) error      //
-	WriteData(datas []any) error // 往下游系统写入数据
+	Init(interface{}) error                           // 根据 meta 配置进行初始化
+	WriteData(ctx context.Context, datas []any) error // 往下游系统写入数据, ctx 超时后应尽快返回
 
 	SetFilters(analytics.AnalyticsFilters) // 设置是否过滤某条数据
 	GetFilters() analytics.AnalyticsFilters
-	SetTimeout(timeout int) // 设置超时时间
+	SetTimeout(timeout int) // 设置超时时间, 单位秒
 	GetTimeout() int
 	SetOmitDetailedRecording(bool)  // 过滤掉详细的数据
 	GetOmitDetailedRecording() bool // 是否过滤详情数据
@@ -0,0 +1,82 @@
+package pumps
+
+import (
+	"bufio"
+	"context"
+	"github.com/stretchr/testify/assert"
+	"iam/internal/pump/analytics"
+	"net"
+	"regexp"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+var rfc5424 = regexp.MustCompile(`^<(\d+)>1 \S+ \S+ iam-pump \d+ authz - \{.*\}$`)
+
+func TestSyslogPump_UDP(t *testing.T) {
+	conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+	assert.NoError(t, err)
+	defer conn.Close()
+
+	pump := (&SyslogPump{}).New()
+	assert.NoError(t, pump.Init(map[string]interface{}{"network": "udp", "addr": conn.LocalAddr().String()}))
+	assert.NoError(t, pump.WriteData(context.Background(), []any{
+		analytics.AnalyticsRecord{Username: "colin", Effect: "deny"},
+	}))
+
+	buf := make([]byte, 4096)
+	n, _, err := conn.ReadFrom(buf)
+	assert.NoError(t, err)
+
+	msg := string(buf[:n])
+	matches := rfc5424.FindStringSubmatch(msg)
+	assert.Len(t, matches, 2, msg)
+	// local0(16) * 8 + notice(5)
+	assert.Equal(t, "133", matches[1])
+}
+
+func TestSyslogPump_TCP(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	assert.NoError(t, err)
+	defer ln.Close()
+
+	received := make(chan []string, 1)
+	go func() {
+		conn, err := ln.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+
+		// octet counting: "len msg"
+		reader := bufio.NewReader(conn)
+		var msgs []string
+		for i := 0; i < 2; i++ {
+			size, _ := reader.ReadString(' ')
+			n, _ := strconv.Atoi(strings.TrimSpace(size))
+			buf := make([]byte, n)
+			_, _ = reader.Read(buf)
+			msgs = append(msgs, string(buf))
+		}
+		received <- msgs
+	}()
+
+	pump := (&SyslogPump{}).New()
+	assert.NoError(t, pump.Init(map[string]interface{}{"network": "tcp", "addr": ln.Addr().String(), "facility": "auth"}))
+	assert.NoError(t, pump.WriteData(context.Background(), testRecords(2)))
+
+	msgs := <-received
+	assert.Len(t, msgs, 2)
+	for _, msg := range msgs {
+		matches := rfc5424.FindStringSubmatch(msg)
+		assert.Len(t, matches, 2, msg)
+		// auth(4) * 8 + info(6)
+		assert.Equal(t, "38", matches[1])
+	}
+}
+
+func TestSyslogPump_InvalidConfig(t *testing.T) {
+	assert.Error(t, (&SyslogPump{}).New().Init(map[string]interface{}{"network": "http"}))
+	assert.Error(t, (&SyslogPump{}).New().Init(map[string]interface{}{"facility": "nope"}))
+}
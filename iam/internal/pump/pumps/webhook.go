@@ -0,0 +1,326 @@
+package pumps
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/sirupsen/logrus"
+	"iam/internal/pump/analytics"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+)
+
+const (
+	webhookPrefix                 = "webhook-pump"
+	defaultWebhookSignatureHeader = "X-IAM-Signature"
+	defaultWebhookBatchSize       = 100
+	defaultWebhookMaxRetries      = 3
+	defaultWebhookQueueMaxFiles   = 1000
+	defaultWebhookQueueRetry      = 30 * time.Second
+	webhookInitialBackoff         = 500 * time.Millisecond
+	webhookMaxBackoff             = 10 * time.Second
+	defaultWebhookRequestTimeout  = 10 * time.Second
+)
+
+// errWebhookRejected 接收方明确拒绝(4xx), 重试没有意义
+var errWebhookRejected = errors.New("webhook rejected the request")
+
+// WebhookPump 将授权日志按批 POST 到 webhook, 失败时按指数退避重试,
+// 接收方长时间不可用时将数据写入本地有界队列, 恢复后按顺序补发
+type WebhookPump struct {
+	CommonPumpConfig
+	conf       WebhookConf
+	httpClient *http.Client
+	sleep      func(ctx context.Context, d time.Duration) error
+
+	mu sync.Mutex // 保证发送和队列的顺序
+}
+
+type WebhookConf struct {
+	URL                string            `mapstructure:"url"`
+	Headers            map[string]string `mapstructure:"headers"`
+	Secret             string            `mapstructure:"secret"`               // HMAC-SHA256 密钥, 为空时不签名
+	SignatureHeader    string            `mapstructure:"signature_header"`     // 签名 header, 值为 sha256=<hex>
+	BatchSize          int               `mapstructure:"batch_size"`           // 每次 POST 的记录数
+	MaxRetries         int               `mapstructure:"max_retries"`          // 5xx 或超时时的最大重试次数, 0 使用默认值, 小于 0 不重试
+	QueueDir           string            `mapstructure:"queue_dir"`            // 本地队列目录, 为空时不落盘
+	QueueMaxFiles      int               `mapstructure:"queue_max_files"`      // 本地队列最多保存的批次, 超过时丢弃最旧的
+	QueueRetryInterval int               `mapstructure:"queue_retry_interval"` // 多少秒尝试补发一次本地队列
+}
+
+func (w *WebhookPump) GetName() string {
+	return "Webhook Pump"
+}
+
+func (w *WebhookPump) New() Pump {
+	return &WebhookPump{}
+}
+
+func (w *WebhookPump) Init(cfg interface{}) error {
+	if err := decodeMeta(cfg, &w.conf); err != nil {
+		return err
+	}
+	if err := w.setDefaults(); err != nil {
+		return err
+	}
+
+	if w.conf.QueueDir != "" {
+		if err := os.MkdirAll(w.conf.QueueDir, 0o755); err != nil {
+			return fmt.Errorf("%s: create queue dir failed: %w", webhookPrefix, err)
+		}
+		go w.drainLoop(time.Duration(w.conf.QueueRetryInterval) * time.Second)
+	}
+
+	logrus.Infof("%s: post records to %s", webhookPrefix, w.conf.URL)
+
+	return nil
+}
+
+func (w *WebhookPump) setDefaults() error {
+	if w.conf.URL == "" {
+		return fmt.Errorf("%s: url must be set", webhookPrefix)
+	}
+	if w.conf.SignatureHeader == "" {
+		w.conf.SignatureHeader = defaultWebhookSignatureHeader
+	}
+	if w.conf.BatchSize <= 0 {
+		w.conf.BatchSize = defaultWebhookBatchSize
+	}
+	if w.conf.MaxRetries < 0 {
+		w.conf.MaxRetries = 0
+	} else if w.conf.MaxRetries == 0 {
+		w.conf.MaxRetries = defaultWebhookMaxRetries
+	}
+	if w.conf.QueueMaxFiles <= 0 {
+		w.conf.QueueMaxFiles = defaultWebhookQueueMaxFiles
+	}
+	if w.conf.QueueRetryInterval <= 0 {
+		w.conf.QueueRetryInterval = int(defaultWebhookQueueRetry / time.Second)
+	}
+
+	w.httpClient = &http.Client{Timeout: defaultWebhookRequestTimeout}
+	if w.sleep == nil {
+		w.sleep = sleepWithContext
+	}
+
+	return nil
+}
+
+func (w *WebhookPump) WriteData(ctx context.Context, datas []any) error {
+	logrus.Debugf("%s: writing %d records", webhookPrefix, len(datas))
+
+	bodies, err := w.buildBodies(datas)
+	if err != nil {
+		return err
+	}
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	// 先补发队列中的数据, 保证顺序
+	w.drainQueue(ctx)
+
+	var firstErr error
+	for i, body := range bodies {
+		// 队列未清空说明接收方仍不可用, 直接入队
+		if w.queueLen() == 0 {
+			err := w.sendWithRetry(ctx, body)
+			if err == nil {
+				continue
+			}
+			if errors.Is(err, errWebhookRejected) {
+				logrus.Errorf("%s: drop batch: %v", webhookPrefix, err)
+				firstErr = err
+				continue
+			}
+			logrus.Warnf("%s: send batch failed, spill to queue: %v", webhookPrefix, err)
+		}
+
+		if err := w.enqueue(body); err != nil {
+			// 无法落盘时剩余的批次都会丢失
+			return fmt.Errorf("%s: spill %d batches failed: %w", webhookPrefix, len(bodies)-i, err)
+		}
+	}
+
+	return firstErr
+}
+
+// buildBodies 按 BatchSize 拆分并序列化
+func (w *WebhookPump) buildBodies(datas []any) ([][]byte, error) {
+	records := make([]analytics.AnalyticsRecord, 0, len(datas))
+	for _, data := range datas {
+		if record, ok := data.(analytics.AnalyticsRecord); ok {
+			records = append(records, record)
+		}
+	}
+
+	bodies := make([][]byte, 0, len(records)/w.conf.BatchSize+1)
+	for start := 0; start < len(records); start += w.conf.BatchSize {
+		end := start + w.conf.BatchSize
+		if end > len(records) {
+			end = len(records)
+		}
+
+		body, err := json.Marshal(records[start:end])
+		if err != nil {
+			return nil, err
+		}
+		bodies = append(bodies, body)
+	}
+
+	return bodies, nil
+}
+
+// sendWithRetry 5xx、429 和网络错误时按指数退避重试, 不超过 ctx 的超时时间
+func (w *WebhookPump) sendWithRetry(ctx context.Context, body []byte) error {
+	backoff := webhookInitialBackoff
+	var err error
+	for attempt := 0; attempt <= w.conf.MaxRetries; attempt++ {
+		if attempt > 0 {
+			if sleepErr := w.sleep(ctx, backoff); sleepErr != nil {
+				return fmt.Errorf("%w (last error: %v)", sleepErr, err)
+			}
+			backoff *= 2
+			if backoff > webhookMaxBackoff {
+				backoff = webhookMaxBackoff
+			}
+		}
+
+		if err = w.send(ctx, body); err == nil || errors.Is(err, errWebhookRejected) {
+			return err
+		}
+	}
+
+	return err
+}
+
+func (w *WebhookPump) send(ctx context.Context, body []byte) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, w.conf.URL, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	for k, v := range w.conf.Headers {
+		req.Header.Set(k, v)
+	}
+	if w.conf.Secret != "" {
+		req.Header.Set(w.conf.SignatureHeader, "sha256="+signWebhookBody(w.conf.Secret, body))
+	}
+
+	resp, err := w.httpClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	_, _ = io.Copy(io.Discard, resp.Body)
+
+	switch {
+	case resp.StatusCode < http.StatusMultipleChoices:
+		return nil
+	case resp.StatusCode >= http.StatusInternalServerError || resp.StatusCode == http.StatusTooManyRequests:
+		return fmt.Errorf("webhook returned status %d", resp.StatusCode)
+	default:
+		return fmt.Errorf("%w: status %d", errWebhookRejected, resp.StatusCode)
+	}
+}
+
+// signWebhookBody 对 body 进行 HMAC-SHA256 签名, 返回 hex 编码
+func signWebhookBody(secret string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(body)
+
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// ---------- 本地队列: 每个批次一个文件, 文件名为写入时间, 按文件名顺序补发 ----------
+
+func (w *WebhookPump) drainLoop(interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for range ticker.C {
+		w.mu.Lock()
+		w.drainQueue(context.Background())
+		w.mu.Unlock()
+	}
+}
+
+// drainQueue 按顺序补发队列中的批次, 每个批次只尝试一次, 失败时停止
+func (w *WebhookPump) drainQueue(ctx context.Context) {
+	for _, file := range w.queueFiles() {
+		body, err := os.ReadFile(file)
+		if err != nil {
+			logrus.Errorf("%s: read queued batch %s failed: %v", webhookPrefix, file, err)
+			_ = os.Remove(file)
+			continue
+		}
+
+		err = w.send(ctx, body)
+		if err != nil && !errors.Is(err, errWebhookRejected) {
+			return
+		}
+		if err != nil {
+			logrus.Errorf("%s: drop queued batch %s: %v", webhookPrefix, file, err)
+		}
+		_ = os.Remove(file)
+	}
+}
+
+func (w *WebhookPump) enqueue(body []byte) error {
+	if w.conf.QueueDir == "" {
+		return errors.New("queue_dir is not configured")
+	}
+
+	name := filepath.Join(w.conf.QueueDir, strconv.FormatInt(time.Now().UnixNano(), 10)+".json")
+	if err := os.WriteFile(name, body, 0o644); err != nil {
+		return err
+	}
+
+	// 超过上限时丢弃最旧的批次
+	files := w.queueFiles()
+	for i := 0; i < len(files)-w.conf.QueueMaxFiles; i++ {
+		logrus.Warnf("%s: queue is full, drop the oldest batch %s", webhookPrefix, files[i])
+		_ = os.Remove(files[i])
+	}
+
+	return nil
+}
+
+func (w *WebhookPump) queueLen() int {
+	return len(w.queueFiles())
+}
+
+func (w *WebhookPump) queueFiles() []string {
+	if w.conf.QueueDir == "" {
+		return nil
+	}
+
+	files, _ := filepath.Glob(filepath.Join(w.conf.QueueDir, "*.json"))
+	// 文件名为纳秒时间戳, 位数相同, 可以直接按字符串排序
+	sort.Strings(files)
+
+	return files
+}
+
+func sleepWithContext(ctx context.Context, d time.Duration) error {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
@@ -0,0 +1,100 @@
+package pumps
+
+import (
+	"context"
+	"encoding/json"
+	"github.com/stretchr/testify/assert"
+	"iam/internal/pump/analytics"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+)
+
+type fakeWebhook struct {
+	mu       sync.Mutex
+	statuses []int // 依次返回的状态码, 用完后返回 200
+	batches  [][]analytics.AnalyticsRecord
+	signs    []string
+}
+
+func (f *fakeWebhook) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if len(f.statuses) > 0 {
+		status := f.statuses[0]
+		f.statuses = f.statuses[1:]
+		if status != http.StatusOK {
+			w.WriteHeader(status)
+			return
+		}
+	}
+
+	body, _ := io.ReadAll(r.Body)
+	var batch []analytics.AnalyticsRecord
+	_ = json.Unmarshal(body, &batch)
+	f.batches = append(f.batches, batch)
+	f.signs = append(f.signs, r.Header.Get("X-IAM-Signature"))
+	if r.Header.Get("X-IAM-Signature") != "sha256="+signWebhookBody("secret", body) {
+		w.WriteHeader(http.StatusUnauthorized)
+	}
+}
+
+func newTestWebhookPump(t *testing.T, hook *fakeWebhook, conf WebhookConf) *WebhookPump {
+	srv := httptest.NewServer(hook)
+	t.Cleanup(srv.Close)
+
+	conf.URL = srv.URL
+	conf.Secret = "secret"
+	pump := &WebhookPump{conf: conf, sleep: func(context.Context, time.Duration) error { return nil }}
+	assert.NoError(t, pump.setDefaults())
+
+	return pump
+}
+
+func TestWebhookPump_WriteData(t *testing.T) {
+	hook := &fakeWebhook{statuses: []int{http.StatusBadGateway, http.StatusServiceUnavailable}}
+	pump := newTestWebhookPump(t, hook, WebhookConf{BatchSize: 2})
+
+	// 前两次 5xx 后重试成功, 5 条记录分 3 批发送
+	assert.NoError(t, pump.WriteData(context.Background(), testRecords(5)))
+	assert.Len(t, hook.batches, 3)
+	assert.Len(t, hook.batches[2], 1)
+}
+
+func TestWebhookPump_Rejected(t *testing.T) {
+	hook := &fakeWebhook{statuses: []int{http.StatusBadRequest}}
+	pump := newTestWebhookPump(t, hook, WebhookConf{QueueDir: t.TempDir()})
+
+	// 4xx 不重试也不入队
+	assert.ErrorIs(t, pump.WriteData(context.Background(), testRecords(1)), errWebhookRejected)
+	assert.Empty(t, hook.batches)
+	assert.Equal(t, 0, pump.queueLen())
+}
+
+func TestWebhookPump_SpillAndDrain(t *testing.T) {
+	hook := &fakeWebhook{statuses: []int{500, 500, 500, 500, 500, 500, 500, 500}}
+	pump := newTestWebhookPump(t, hook, WebhookConf{BatchSize: 1, MaxRetries: 1, QueueDir: t.TempDir(), QueueMaxFiles: 2})
+
+	// 第 1 批重试 1 次后入队, 之后的批次直接入队, 超过上限时丢弃最旧的
+	assert.NoError(t, pump.WriteData(context.Background(), []any{
+		analytics.AnalyticsRecord{Username: "a"},
+		analytics.AnalyticsRecord{Username: "b"},
+		analytics.AnalyticsRecord{Username: "c"},
+	}))
+	assert.Equal(t, 2, pump.queueLen())
+
+	// 接收方恢复后先补发队列, 再发送新数据
+	hook.statuses = nil
+	assert.NoError(t, pump.WriteData(context.Background(), []any{analytics.AnalyticsRecord{Username: "d"}}))
+	assert.Equal(t, 0, pump.queueLen())
+
+	var usernames []string
+	for _, batch := range hook.batches {
+		usernames = append(usernames, batch[0].Username)
+	}
+	assert.Equal(t, []string{"b", "c", "d"}, usernames)
+}
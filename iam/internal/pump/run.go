@@ -0,0 +1,41 @@
+package pump
+
+import (
+	"iam/internal/pump/config"
+	"iam/internal/pump/options"
+	"iam/pkg/app"
+	"iam/pkg/logger"
+)
+
+func NewApp(basename string) *app.App {
+
+	opts := options.NewOptions()
+	// 初始化应用框架，内部将cli+config+env并进行合并，并解析到cfg中
+	application := app.NewApp(
+		"IAM Analytics Pump",
+		basename,
+		app.WithDefaultValidArgs(),
+		app.WithRunFunc(run(opts)),
+		app.WithOptions(opts),
+	)
+
+	return application
+}
+
+func run(opts *options.Options) app.RunFunc {
+	return func(basename string) error {
+
+		logger.NewLog(logger.LogCfg{
+			LogLevel: opts.Log.Level,
+		})
+
+		cfg := config.NewConfigFromOption(opts)
+		// 创建 pump 服务 并进行运行
+		server, err := createPumpServer(cfg)
+		if err != nil {
+			return err
+		}
+
+		return server.PreparedServer().Run()
+	}
+}
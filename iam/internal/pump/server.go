@@ -0,0 +1,275 @@
+package pump
+
+import (
+	"context"
+	"github.com/gin-gonic/gin"
+	"github.com/pkg/errors"
+	"github.com/sirupsen/logrus"
+	genericoptions "iam/internal/pkg/options"
+	"iam/internal/pump/analytics"
+	"iam/internal/pump/config"
+	"iam/internal/pump/options"
+	"iam/internal/pump/pumps"
+	"iam/internal/pump/store"
+	"iam/internal/pump/store/redis"
+	rediscache "iam/pkg/cache"
+	"iam/pkg/shutdown"
+	"iam/pkg/shutdown/shutdownmanagers/posixsignal"
+	"log"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+/*
+  redis(authz 写入的授权日志) --> 每 purge-delay 秒取出一批 --> 过滤 --> 并发写入各个 pump --> Ack
+*/
+
+type pumpServer struct {
+	gs                 *shutdown.GracefulShutdown
+	purgeDelay         time.Duration
+	healthCheckPath    string
+	healthCheckAddress string
+	redisOptions       *genericoptions.RedisOptions
+	analyticsStore     store.AnalyticsStore
+	pumps              []pumps.Pump
+	healthServer       *http.Server
+	ctx                context.Context // 主循环 ctx, 结束时处理最后一批数据
+	cancel             context.CancelFunc
+	redisCancelFunc    context.CancelFunc // redis 回调函数
+	done               chan struct{}      // 主循环退出
+}
+
+type preparedPumpServer struct {
+	*pumpServer
+}
+
+// createPumpServer 通过 pump 的 config 初始化 pumpServer
+func createPumpServer(cfg *config.Config) (*pumpServer, error) {
+	gs := shutdown.New()
+	gs.AddShutdownManager(posixsignal.NewPosixSignalManager())
+
+	analyticsStore := (&redis.Store{}).New()
+	if err := analyticsStore.Init(&redis.Config{Consumer: cfg.Consumer, ChunkSize: cfg.ChunkSize}); err != nil {
+		return nil, errors.Wrap(err, "init analytics store failed")
+	}
+
+	pmps, err := initPumps(cfg.Pumps, cfg.OmitDetailedRecording)
+	if err != nil {
+		return nil, err
+	}
+
+	return &pumpServer{
+		gs:                 gs,
+		purgeDelay:         time.Duration(cfg.PurgeDelay) * time.Second,
+		healthCheckPath:    "/" + strings.TrimPrefix(cfg.HealthCheckPath, "/"),
+		healthCheckAddress: cfg.HealthCheckAddress,
+		redisOptions:       cfg.RedisOptions,
+		analyticsStore:     analyticsStore,
+		pumps:              pmps,
+		done:               make(chan struct{}),
+	}, nil
+}
+
+// initPumps 根据配置创建并初始化所有 pump
+func initPumps(configs map[string]options.PumpConfig, omitDetailedRecording bool) ([]pumps.Pump, error) {
+	pmps := make([]pumps.Pump, 0, len(configs))
+	for name, pmpConfig := range configs {
+		pmpType, err := pumps.GetPumpByName(pmpConfig.Type)
+		if err != nil {
+			return nil, errors.Wrapf(err, "pump %s", name)
+		}
+
+		pmp := pmpType.New()
+		if err := pmp.Init(pmpConfig.Meta); err != nil {
+			return nil, errors.Wrapf(err, "init pump %s failed", name)
+		}
+		pmp.SetFilters(pmpConfig.Filters)
+		pmp.SetTimeout(pmpConfig.Timeout)
+		pmp.SetOmitDetailedRecording(omitDetailedRecording || pmpConfig.OmitDetailedRecording)
+
+		logrus.Infof("init pump %s(%s) success", name, pmp.GetName())
+		pmps = append(pmps, pmp)
+	}
+
+	return pmps, nil
+}
+
+func (s *pumpServer) PreparedServer() *preparedPumpServer {
+	// redis 需要在处理完最后一批数据后再关闭, 与主循环使用不同的 ctx
+	redisCtx, redisCancel := context.WithCancel(context.Background())
+	s.redisCancelFunc = redisCancel
+	s.initRedisStore(redisCtx)
+
+	s.ctx, s.cancel = context.WithCancel(context.Background())
+	s.initHealthServer()
+
+	return &preparedPumpServer{s}
+}
+
+// Run 启动健康检查和主循环, 收到退出信号后处理完最后一批数据再退出
+func (s *preparedPumpServer) Run() error {
+	s.gs.AddShutdownCallback(shutdown.ShutdownFunc(func(string) error {
+		s.cancel()
+		<-s.done
+		s.redisCancelFunc()
+
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+
+		return s.healthServer.Shutdown(ctx)
+	}))
+
+	// start shutdown managers
+	if err := s.gs.Start(); err != nil {
+		log.Fatalf("start shutdown manager failed: %s", err.Error())
+	}
+
+	go func() {
+		if err := s.healthServer.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			logrus.Fatalf("start health check server failed: %v", err)
+		}
+	}()
+
+	s.pumpLoop()
+
+	return nil
+}
+
+// pumpLoop 每 purgeDelay 从 redis 中取出数据写入 pumps, ctx 结束时再处理一次后退出
+func (s *pumpServer) pumpLoop() {
+	defer close(s.done)
+
+	ticker := time.NewTicker(s.purgeDelay)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			s.pump()
+		case <-s.ctx.Done():
+			logrus.Info("flush the last batch of analytics records before exit")
+			s.pump()
+			return
+		}
+	}
+}
+
+// pump 取出一批数据写入所有 pump, 全部 pump 结束(成功/失败/超时)后进行确认.
+// 单个 pump 写入失败不会重新投递, 避免其他 pump 重复写入
+func (s *pumpServer) pump() {
+	records, err := s.analyticsStore.GetKeysAndDel()
+	if err != nil {
+		logrus.Warnf("get analytics records from %s failed: %v", s.analyticsStore.GetName(), err)
+		return
+	}
+	if len(records) == 0 {
+		return
+	}
+
+	logrus.Debugf("purge %d analytics records", len(records))
+	writeToPumps(s.pumps, records, s.purgeDelay)
+
+	if err := s.analyticsStore.Ack(); err != nil {
+		logrus.Errorf("ack analytics records failed: %v", err)
+	}
+}
+
+// writeToPumps 并发写入所有 pump
+func writeToPumps(pmps []pumps.Pump, records []any, purgeDelay time.Duration) {
+	var wg sync.WaitGroup
+	for _, pmp := range pmps {
+		wg.Add(1)
+		go func(pmp pumps.Pump) {
+			defer wg.Done()
+			execPumpWriting(pmp, records, purgeDelay)
+		}(pmp)
+	}
+	wg.Wait()
+}
+
+// execPumpWriting 过滤数据后写入 pump, 超时时间未设置时使用 purgeDelay
+func execPumpWriting(pmp pumps.Pump, records []any, purgeDelay time.Duration) {
+	data := filterRecords(records, pmp.GetFilters(), pmp.GetOmitDetailedRecording())
+	if len(data) == 0 {
+		return
+	}
+
+	timeout := purgeDelay
+	if pmp.GetTimeout() > 0 {
+		timeout = time.Duration(pmp.GetTimeout()) * time.Second
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	errCh := make(chan error, 1)
+	go func() {
+		errCh <- pmp.WriteData(ctx, data)
+	}()
+
+	select {
+	case err := <-errCh:
+		if err != nil {
+			logrus.Errorf("write %d records to pump %s failed: %v", len(data), pmp.GetName(), err)
+		}
+	case <-ctx.Done():
+		logrus.Warnf("write %d records to pump %s timeout after %s", len(data), pmp.GetName(), timeout)
+	}
+}
+
+// filterRecords 根据 pump 的配置过滤记录, 每个 pump 得到的都是记录的副本
+func filterRecords(records []any, filters analytics.AnalyticsFilters, omitDetailedRecording bool) []any {
+	data := make([]any, 0, len(records))
+	for _, r := range records {
+		record, ok := r.(analytics.AnalyticsRecord)
+		if !ok {
+			continue
+		}
+		if filters.HasFilter() && filters.ShouldFilter(record) {
+			continue
+		}
+		if omitDetailedRecording {
+			record.RemoveDetailedFields()
+		}
+		data = append(data, record)
+	}
+
+	return data
+}
+
+// initHealthServer 健康检查
+func (s *pumpServer) initHealthServer() {
+	engine := gin.New()
+	engine.Use(gin.Recovery())
+	engine.GET(s.healthCheckPath, func(c *gin.Context) {
+		c.JSON(http.StatusOK, "ok")
+	})
+
+	s.healthServer = &http.Server{
+		Addr:    s.healthCheckAddress,
+		Handler: engine,
+	}
+}
+
+// initRedisStore 初始化redis 并尝试重连
+func (s *pumpServer) initRedisStore(ctx context.Context) {
+	cfg := &rediscache.Config{
+		Host:                  s.redisOptions.Host,
+		Port:                  s.redisOptions.Port,
+		Addrs:                 s.redisOptions.Addrs,
+		MasterName:            s.redisOptions.MasterName,
+		Username:              s.redisOptions.Username,
+		Password:              s.redisOptions.Password,
+		Database:              s.redisOptions.Database,
+		MaxIdle:               s.redisOptions.MaxIdle,
+		MaxActive:             s.redisOptions.MaxActive,
+		Timeout:               s.redisOptions.Timeout,
+		EnableCluster:         s.redisOptions.EnableCluster,
+		UseSSL:                s.redisOptions.UseSSL,
+		SSLInsecureSkipVerify: s.redisOptions.SSLInsecureSkipVerify,
+	}
+
+	go rediscache.ConnectToRedis(ctx, cfg)
+}
@@ -0,0 +1,69 @@
+package pump
+
+import (
+	"context"
+	"github.com/stretchr/testify/assert"
+	"iam/internal/pump/analytics"
+	"iam/internal/pump/pumps"
+	"sync"
+	"testing"
+	"time"
+)
+
+type fakePump struct {
+	pumps.CommonPumpConfig
+	delay time.Duration
+
+	mu   sync.Mutex
+	data []any
+}
+
+func (p *fakePump) GetName() string        { return "fake" }
+func (p *fakePump) New() pumps.Pump        { return &fakePump{} }
+func (p *fakePump) Init(interface{}) error { return nil }
+func (p *fakePump) written() []any         { p.mu.Lock(); defer p.mu.Unlock(); return p.data }
+func (p *fakePump) WriteData(ctx context.Context, data []any) error {
+	select {
+	case <-time.After(p.delay):
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.data = append(p.data, data...)
+
+	return nil
+}
+
+func TestFilterRecords(t *testing.T) {
+	records := []any{
+		analytics.AnalyticsRecord{Username: "colin", Request: "r", Policies: "p", Deciders: "d"},
+		analytics.AnalyticsRecord{Username: "admin", Request: "r"},
+	}
+
+	data := filterRecords(records, analytics.AnalyticsFilters{SkippedUsernames: []string{"admin"}}, true)
+	assert.Equal(t, []any{analytics.AnalyticsRecord{Username: "colin"}}, data)
+
+	data = filterRecords(records, analytics.AnalyticsFilters{Usernames: []string{"admin"}}, false)
+	assert.Equal(t, []any{records[1]}, data)
+
+	// 过滤时不能修改原始数据
+	assert.Equal(t, "p", records[0].(analytics.AnalyticsRecord).Policies)
+}
+
+func TestWriteToPumps(t *testing.T) {
+	fast := &fakePump{}
+	slow := &fakePump{delay: time.Minute}
+	slow.SetTimeout(0)
+
+	records := []any{analytics.AnalyticsRecord{Username: "colin"}}
+
+	start := time.Now()
+	writeToPumps([]pumps.Pump{fast, slow}, records, 50*time.Millisecond)
+
+	// 慢的 pump 超时后不再等待
+	assert.Less(t, time.Since(start), time.Second)
+	assert.Len(t, fast.written(), 1)
+	assert.Empty(t, slow.written())
+}
@@ -0,0 +1,210 @@
+package redis
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/sirupsen/logrus"
+	"iam/internal/pump/analytics"
+	"iam/internal/pump/store"
+	"iam/pkg/cache"
+	"time"
+)
+
+/*
+  authz server RPUSH --> iam-authorization-analytics
+                              |  lua: LRANGE + RPUSH + LTRIM (每次最多 chunk-size 条)
+                              v
+                  {iam-authorization-analytics}.inflight.<consumer>  --> pumps --> Ack: DEL
+
+  每个消费者定期续期 {iam-authorization-analytics}.lease.<consumer>, 并登记到 {iam-authorization-analytics}.consumers 中.
+  消费者被缩容不再启动时, 租约过期, 其他消费者将它的 in-flight list 移动到自己的 in-flight list 中重新投递.
+*/
+
+const (
+	// DefaultAnalyticsKey authz server 写入授权记录的 key
+	DefaultAnalyticsKey = "iam-authorization-analytics"
+	defaultChunkSize    = 1000
+	maxChunksPerBatch   = 10 // 写入速度高于消费速度时, 避免一次取出过多记录
+	defaultLeaseTTL     = 5 * time.Minute
+)
+
+// Config redis store 配置
+type Config struct {
+	KeyName   string        // 读取的 list
+	Consumer  string        // 消费者名称, 区分多个 pump 实例的 in-flight list, 重启后必须保持不变, 如 StatefulSet 的 pod 名称
+	ChunkSize int64         // 每次原子移动的最大条数
+	LeaseTTL  time.Duration // 消费者租约的有效期, 需要大于两次读取的间隔
+}
+
+// listStore Store 使用的 redis 操作, 由 cache.RedisCluster 实现
+type listStore interface {
+	GetList(key string) ([]string, error)
+	MoveListHead(src, dst string, count int64) ([]string, error)
+	DeleteKey(key string) error
+	SetKey(key, value string, expire time.Duration) error
+	AddSetMember(key, member string) error
+	GetSetMembers(key string) ([]string, error)
+	ClaimList(lease, src, dst, set, member string) (int64, error)
+}
+
+// Store 从 redis list 中读取授权记录.
+// 读取时记录被原子地移动到当前消费者的 in-flight list 中, Ack 后才删除,
+// pump 在处理过程中崩溃时, 重启后会重新投递 in-flight list 中的记录.
+type Store struct {
+	cluster     listStore
+	key         string
+	consumer    string
+	inflightKey string
+	chunkSize   int64
+	leaseTTL    time.Duration
+	claimedAt   time.Time // 上次接管其他消费者的时间
+}
+
+var _ store.AnalyticsStore = &Store{}
+
+func (s *Store) New() store.AnalyticsStore {
+	return &Store{}
+}
+
+func (s *Store) GetName() string {
+	return "redis"
+}
+
+func (s *Store) Init(cfg interface{}) error {
+	conf, ok := cfg.(*Config)
+	if !ok || conf == nil {
+		return fmt.Errorf("invalid redis store config: %T", cfg)
+	}
+
+	s.key = conf.KeyName
+	if s.key == "" {
+		s.key = DefaultAnalyticsKey
+	}
+
+	// hostname 在 Deployment 中每次重启都会变化, 崩溃前的 in-flight list 无法再被自己读取
+	if conf.Consumer == "" {
+		return fmt.Errorf("redis store consumer must be set")
+	}
+	s.consumer = conf.Consumer
+	s.inflightKey = s.inflightKeyOf(s.consumer)
+
+	s.leaseTTL = conf.LeaseTTL
+	if s.leaseTTL <= 0 {
+		s.leaseTTL = defaultLeaseTTL
+	}
+
+	if s.cluster == nil {
+		s.cluster = &cache.RedisCluster{}
+	}
+
+	s.chunkSize = conf.ChunkSize
+	if s.chunkSize <= 0 {
+		s.chunkSize = defaultChunkSize
+	}
+
+	return nil
+}
+
+// 使用 hash tag 保证集群模式下与 key 位于同一个 slot, 设置了 KeyPrefix 时由 RedisCluster 将前缀放入 {} 中
+func (s *Store) inflightKeyOf(consumer string) string {
+	return fmt.Sprintf("{%s}.inflight.%s", s.key, consumer)
+}
+
+func (s *Store) leaseKeyOf(consumer string) string {
+	return fmt.Sprintf("{%s}.lease.%s", s.key, consumer)
+}
+
+func (s *Store) consumersKey() string {
+	return fmt.Sprintf("{%s}.consumers", s.key)
+}
+
+// GetKeysAndDel 取出待处理的记录. 上一批未 Ack 时优先重新返回上一批
+func (s *Store) GetKeysAndDel() ([]any, error) {
+	if err := s.renewLease(); err != nil {
+		return nil, err
+	}
+	// Init 时 redis 尚未连接, 首次读取时接管已失效的消费者, 之后每个租约周期检查一次
+	if time.Since(s.claimedAt) >= s.leaseTTL {
+		s.claimOrphans()
+	}
+
+	pending, err := s.cluster.GetList(s.inflightKey)
+	if err != nil {
+		return nil, err
+	}
+	if len(pending) > 0 {
+		logrus.Warnf("redeliver %d unacknowledged analytics records", len(pending))
+		return decodeRecords(pending), nil
+	}
+
+	var values []string
+	for i := 0; i < maxChunksPerBatch; i++ {
+		items, err := s.cluster.MoveListHead(s.key, s.inflightKey, s.chunkSize)
+		if err != nil {
+			// 已移动的记录仍在 in-flight list 中, 下一次会重新投递
+			return nil, err
+		}
+		values = append(values, items...)
+
+		if int64(len(items)) < s.chunkSize {
+			break
+		}
+	}
+
+	return decodeRecords(values), nil
+}
+
+// renewLease 续期当前消费者的租约并登记, 租约有效时其他消费者不会接管 in-flight list
+func (s *Store) renewLease() error {
+	if err := s.cluster.SetKey(s.leaseKeyOf(s.consumer), "1", s.leaseTTL); err != nil {
+		return err
+	}
+
+	return s.cluster.AddSetMember(s.consumersKey(), s.consumer)
+}
+
+// claimOrphans 将租约已过期的消费者的 in-flight list 移动到当前消费者的 in-flight list 中, 随后重新投递.
+// 检查租约和移动在同一个 lua 脚本中完成, 多个消费者同时接管时只有一个能取到记录
+func (s *Store) claimOrphans() {
+	consumers, err := s.cluster.GetSetMembers(s.consumersKey())
+	if err != nil {
+		logrus.Warnf("get analytics consumers failed: %v", err)
+		return
+	}
+	s.claimedAt = time.Now()
+
+	for _, consumer := range consumers {
+		if consumer == s.consumer {
+			continue
+		}
+
+		n, err := s.cluster.ClaimList(s.leaseKeyOf(consumer), s.inflightKeyOf(consumer), s.inflightKey, s.consumersKey(), consumer)
+		if err != nil {
+			logrus.Warnf("claim in-flight records of consumer %s failed: %v", consumer, err)
+			continue
+		}
+		if n > 0 {
+			logrus.Warnf("claimed %d unacknowledged analytics records of expired consumer %s", n, consumer)
+		}
+	}
+}
+
+// Ack 删除 in-flight list
+func (s *Store) Ack() error {
+	return s.cluster.DeleteKey(s.inflightKey)
+}
+
+// decodeRecords 解析记录, 无法解析的记录直接丢弃
+func decodeRecords(values []string) []any {
+	records := make([]any, 0, len(values))
+	for _, v := range values {
+		var record analytics.AnalyticsRecord
+		if err := json.Unmarshal([]byte(v), &record); err != nil {
+			logrus.Errorf("decode analytics record failed: %v", err)
+			continue
+		}
+		records = append(records, record)
+	}
+
+	return records
+}
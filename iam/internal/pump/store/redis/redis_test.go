@@ -0,0 +1,235 @@
+package redis
+
+import (
+	"errors"
+	"fmt"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"iam/internal/pump/analytics"
+	"testing"
+	"time"
+)
+
+// fakeLists 在内存中模拟 redis list, 集合和租约
+type fakeLists struct {
+	lists     map[string][]string
+	sets      map[string]map[string]bool
+	leases    map[string]time.Duration
+	moves     int
+	failMoves int // 第 n 次移动时返回错误, 0 表示不出错
+}
+
+func (f *fakeLists) GetList(key string) ([]string, error) {
+	return append([]string(nil), f.lists[key]...), nil
+}
+
+func (f *fakeLists) MoveListHead(src, dst string, count int64) ([]string, error) {
+	f.moves++
+	if f.moves == f.failMoves {
+		return nil, errors.New("connection reset")
+	}
+
+	n := int(count)
+	if n > len(f.lists[src]) {
+		n = len(f.lists[src])
+	}
+	items := append([]string(nil), f.lists[src][:n]...)
+	f.lists[src] = f.lists[src][n:]
+	f.lists[dst] = append(f.lists[dst], items...)
+
+	return items, nil
+}
+
+func (f *fakeLists) DeleteKey(key string) error {
+	delete(f.lists, key)
+	return nil
+}
+
+func (f *fakeLists) SetKey(key, _ string, expire time.Duration) error {
+	f.leases[key] = expire
+	return nil
+}
+
+func (f *fakeLists) AddSetMember(key, member string) error {
+	if f.sets[key] == nil {
+		f.sets[key] = map[string]bool{}
+	}
+	f.sets[key][member] = true
+	return nil
+}
+
+func (f *fakeLists) GetSetMembers(key string) ([]string, error) {
+	members := make([]string, 0, len(f.sets[key]))
+	for member := range f.sets[key] {
+		members = append(members, member)
+	}
+	return members, nil
+}
+
+func (f *fakeLists) ClaimList(lease, src, dst, set, member string) (int64, error) {
+	if _, ok := f.leases[lease]; ok {
+		return -1, nil
+	}
+
+	n := len(f.lists[src])
+	f.lists[dst] = append(f.lists[dst], f.lists[src]...)
+	delete(f.lists, src)
+	delete(f.sets[set], member)
+	return int64(n), nil
+}
+
+func (f *fakeLists) push(key string, from, to int) {
+	for i := from; i < to; i++ {
+		f.lists[key] = append(f.lists[key], fmt.Sprintf(`{"username":"user-%d"}`, i))
+	}
+}
+
+func newFakeLists() *fakeLists {
+	return &fakeLists{lists: map[string][]string{}, sets: map[string]map[string]bool{}, leases: map[string]time.Duration{}}
+}
+
+func newTestStore(t *testing.T, chunkSize int64) (*Store, *fakeLists) {
+	lists := newFakeLists()
+	s := &Store{cluster: lists}
+	require.NoError(t, s.Init(&Config{Consumer: "pump-0", ChunkSize: chunkSize}))
+
+	return s, lists
+}
+
+func usernames(records []any) []string {
+	names := make([]string, 0, len(records))
+	for _, r := range records {
+		names = append(names, r.(analytics.AnalyticsRecord).Username)
+	}
+
+	return names
+}
+
+func TestStore_Init(t *testing.T) {
+	s := &Store{}
+	assert.Error(t, s.Init(nil))
+	assert.Error(t, s.Init(&Config{})) // 必须指定稳定的消费者名称
+
+	assert.NoError(t, s.Init(&Config{Consumer: "pump-0"}))
+	assert.Equal(t, DefaultAnalyticsKey, s.key)
+	assert.Equal(t, "{iam-authorization-analytics}.inflight.pump-0", s.inflightKey)
+	assert.Equal(t, int64(defaultChunkSize), s.chunkSize)
+}
+
+func TestDecodeRecords(t *testing.T) {
+	records := decodeRecords([]string{
+		`{"username":"colin","effect":"allow"}`,
+		`not json`,
+	})
+
+	assert.Len(t, records, 1)
+	assert.Equal(t, analytics.AnalyticsRecord{Username: "colin", Effect: "allow"}, records[0])
+}
+
+func TestStore_Drain(t *testing.T) {
+	s, lists := newTestStore(t, 10)
+	lists.push(s.key, 0, 25)
+
+	records, err := s.GetKeysAndDel()
+	require.NoError(t, err)
+	assert.Len(t, records, 25)
+	assert.Equal(t, "user-0", usernames(records)[0])
+	assert.Equal(t, "user-24", usernames(records)[24])
+	assert.Empty(t, lists.lists[s.key])
+	assert.Len(t, lists.lists[s.inflightKey], 25)
+	assert.Equal(t, 3, lists.moves)
+
+	// 一次最多移动 maxChunksPerBatch 个 chunk
+	s, lists = newTestStore(t, 1)
+	lists.push(s.key, 0, maxChunksPerBatch+5)
+
+	records, err = s.GetKeysAndDel()
+	require.NoError(t, err)
+	assert.Len(t, records, maxChunksPerBatch)
+	assert.Len(t, lists.lists[s.key], 5)
+}
+
+func TestStore_RedeliverAndAck(t *testing.T) {
+	s, lists := newTestStore(t, 10)
+	lists.push(s.key, 0, 5)
+
+	records, err := s.GetKeysAndDel()
+	require.NoError(t, err)
+	first := usernames(records)
+
+	// 未 Ack 时重新投递上一批, 新写入的记录留在原 list 中
+	lists.push(s.key, 5, 8)
+	records, err = s.GetKeysAndDel()
+	require.NoError(t, err)
+	assert.Equal(t, first, usernames(records))
+	assert.Len(t, lists.lists[s.key], 3)
+
+	require.NoError(t, s.Ack())
+	assert.NotContains(t, lists.lists, s.inflightKey)
+
+	records, err = s.GetKeysAndDel()
+	require.NoError(t, err)
+	assert.Equal(t, []string{"user-5", "user-6", "user-7"}, usernames(records))
+
+	require.NoError(t, s.Ack())
+	records, err = s.GetKeysAndDel()
+	require.NoError(t, err)
+	assert.Empty(t, records)
+}
+
+func TestStore_MoveFailure(t *testing.T) {
+	s, lists := newTestStore(t, 2)
+	lists.push(s.key, 0, 5)
+	lists.failMoves = 2
+
+	// 第二次移动失败, 已移动的记录保留在 in-flight list 中, 下一次重新投递
+	_, err := s.GetKeysAndDel()
+	assert.Error(t, err)
+	assert.Len(t, lists.lists[s.inflightKey], 2)
+
+	records, err := s.GetKeysAndDel()
+	require.NoError(t, err)
+	assert.Equal(t, []string{"user-0", "user-1"}, usernames(records))
+	assert.Len(t, lists.lists[s.key], 3)
+}
+
+func TestStore_ClaimOrphans(t *testing.T) {
+	lists := newFakeLists()
+	newStore := func(consumer string) *Store {
+		s := &Store{cluster: lists}
+		require.NoError(t, s.Init(&Config{Consumer: consumer, ChunkSize: 10}))
+		return s
+	}
+
+	// pump-1 取出记录后崩溃, 之后被缩容
+	crashed := newStore("pump-1")
+	lists.push(crashed.key, 0, 3)
+	_, err := crashed.GetKeysAndDel()
+	require.NoError(t, err)
+	assert.Len(t, lists.lists[crashed.inflightKey], 3)
+	assert.Equal(t, defaultLeaseTTL, lists.leases[crashed.leaseKeyOf("pump-1")])
+
+	// 租约有效时不会被接管
+	s := newStore("pump-0")
+	lists.push(s.key, 3, 5)
+	records, err := s.GetKeysAndDel()
+	require.NoError(t, err)
+	assert.Equal(t, []string{"user-3", "user-4"}, usernames(records))
+	require.NoError(t, s.Ack())
+
+	// 租约过期后, 下一个租约周期将 pump-1 的记录移动到 pump-0 中重新投递
+	delete(lists.leases, crashed.leaseKeyOf("pump-1"))
+	records, err = s.GetKeysAndDel()
+	require.NoError(t, err)
+	assert.Empty(t, records)
+
+	s.claimedAt = time.Now().Add(-defaultLeaseTTL)
+	records, err = s.GetKeysAndDel()
+	require.NoError(t, err)
+	assert.Equal(t, []string{"user-0", "user-1", "user-2"}, usernames(records))
+	assert.NotContains(t, lists.lists, crashed.inflightKey)
+	assert.Equal(t, map[string]bool{"pump-0": true}, lists.sets[s.consumersKey()])
+
+	require.NoError(t, s.Ack())
+	assert.NotContains(t, lists.lists, s.inflightKey)
+}
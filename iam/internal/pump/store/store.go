@@ -3,9 +3,10 @@ package store
 // AnalyticsStore 从 redis 中获取值
 type AnalyticsStore interface {
 	New() AnalyticsStore
-	Init(cfg interface{})
+	Init(cfg interface{}) error
 	GetName() string
-	GetKeysAndDel() (val []any, err error) // 从redis中获取所有值，然后进行
+	GetKeysAndDel() (val []any, err error) // 从redis中取出待处理的记录, 取出的记录在 Ack 前不会被删除
+	Ack() error                            // 确认上一次取出的记录已处理完成
 }
 
 // 从 store 中获取往 pumpus 里面进行推送并写入
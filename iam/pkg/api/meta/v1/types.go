@@ -73,9 +73,10 @@ type ObjectMeta struct {
 	// DeletedAt gorm.DeletedAt `json:"-" gorm:"column:deletedAt;index:idx_deletedAt"`
 }
 
+// ListOptions 分页查询参数
 type ListOptions struct {
-	Limit  int `json:"limit"`
-	Offset int `json:"offset"`
+	Limit  int `json:"limit" form:"limit"`
+	Offset int `json:"offset" form:"offset"`
 }
 
 type ListMeta struct {
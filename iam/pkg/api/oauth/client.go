@@ -0,0 +1,199 @@
+package oauth
+
+import (
+	"golang.org/x/crypto/bcrypt"
+	"gorm.io/gorm"
+	metav1 "iam/pkg/api/meta/v1"
+	"iam/pkg/util/idutil"
+	"iam/pkg/validation"
+	"iam/pkg/validation/field"
+	"net/url"
+	"strings"
+)
+
+// 支持的授权类型
+const (
+	GrantAuthorizationCode = "authorization_code"
+	GrantRefreshToken      = "refresh_token"
+	GrantClientCredentials = "client_credentials"
+)
+
+// 支持的 scope
+const (
+	ScopeOpenID  = "openid"
+	ScopeProfile = "profile"
+	ScopeEmail   = "email"
+)
+
+var (
+	supportedGrantTypes = []string{GrantAuthorizationCode, GrantRefreshToken, GrantClientCredentials}
+	supportedScopes     = []string{ScopeOpenID, ScopeProfile, ScopeEmail}
+)
+
+// Client OIDC 客户端, 属于某一个用户, 接入 IAM 单点登录的应用
+type Client struct {
+	metav1.ObjectMeta `json:"metadata,omitempty"` // 通用
+	Username          string                      `json:"username" gorm:"column:username" validate:"omitempty"`
+	ClientID          string                      `json:"clientID" gorm:"column:clientID" validate:"omitempty"`
+	// ClientSecret 数据库中保存 bcrypt 后的值, 仅在创建时返回一次明文
+	ClientSecret string   `json:"clientSecret,omitempty" gorm:"column:clientSecret" validate:"omitempty"`
+	Public       bool     `json:"public" gorm:"column:public" validate:"omitempty"` // 公共客户端(SPA, 移动端)没有密钥, 只能依靠 PKCE
+	RedirectURIs []string `json:"redirectURIs" gorm:"-" validate:"omitempty"`
+	GrantTypes   []string `json:"grantTypes" gorm:"-" validate:"omitempty"` // 为空时为 authorization_code 和 refresh_token
+	Scopes       []string `json:"scopes" gorm:"-" validate:"omitempty"`     // 允许申请的 scope, 为空时为全部
+	Description  string   `json:"description" gorm:"column:description" validate:"description"`
+
+	// 以空格分隔保存到 db 中. DO NOT modify directly.
+	RedirectURIsShadow string `json:"-" gorm:"column:redirectURIs" validate:"omitempty"`
+	GrantTypesShadow   string `json:"-" gorm:"column:grantTypes" validate:"omitempty"`
+	ScopesShadow       string `json:"-" gorm:"column:scopes" validate:"omitempty"`
+}
+
+func (c *Client) TableName() string {
+	return "oauth_client"
+}
+
+type ClientList struct {
+	// Standard list metadata.
+	metav1.ListMeta `json:",inline"`
+
+	Items []*Client `json:"items"`
+}
+
+// BeforeSave 创建/更新前, 将列表字段保存到 shadow 中
+func (c *Client) BeforeSave(tx *gorm.DB) error {
+	c.RedirectURIsShadow = strings.Join(c.RedirectURIs, " ")
+	c.GrantTypesShadow = strings.Join(c.GrantTypes, " ")
+	c.ScopesShadow = strings.Join(c.Scopes, " ")
+
+	return nil
+}
+
+// AfterCreate 创建新数据后，进行添加 InstanceID
+func (c *Client) AfterCreate(tx *gorm.DB) error {
+	c.InstanceID = idutil.GetInstanceID(c.ID, "client-")
+
+	return tx.Save(c).Error
+}
+
+// AfterFind 查询后, 从 shadow 中解析列表字段
+func (c *Client) AfterFind(tx *gorm.DB) error {
+	c.RedirectURIs = strings.Fields(c.RedirectURIsShadow)
+	c.GrantTypes = strings.Fields(c.GrantTypesShadow)
+	c.Scopes = strings.Fields(c.ScopesShadow)
+
+	return nil
+}
+
+// Default 补全默认的授权类型和 scope
+func (c *Client) Default() {
+	if len(c.GrantTypes) == 0 {
+		c.GrantTypes = []string{GrantAuthorizationCode, GrantRefreshToken}
+	}
+	if len(c.Scopes) == 0 {
+		c.Scopes = append([]string(nil), supportedScopes...)
+	}
+}
+
+// HasGrantType 是否允许使用该授权类型
+func (c *Client) HasGrantType(grantType string) bool {
+	return contains(c.GrantTypes, grantType)
+}
+
+// HasRedirectURI 回调地址必须与注册的地址完全一致
+func (c *Client) HasRedirectURI(uri string) bool {
+	return contains(c.RedirectURIs, uri)
+}
+
+// AllowScope 是否允许申请该 scope
+func (c *Client) AllowScope(scope string) bool {
+	return contains(c.Scopes, scope)
+}
+
+// HashSecret 将明文密钥替换为 bcrypt 后的值
+func (c *Client) HashSecret() error {
+	hashed, err := bcrypt.GenerateFromPassword([]byte(c.ClientSecret), bcrypt.DefaultCost)
+	if err != nil {
+		return err
+	}
+	c.ClientSecret = string(hashed)
+
+	return nil
+}
+
+// CompareSecret 验证客户端密钥, 公共客户端没有密钥, 始终失败
+func (c *Client) CompareSecret(secret string) error {
+	if c.Public || c.ClientSecret == "" {
+		return bcrypt.ErrMismatchedHashAndPassword
+	}
+
+	return bcrypt.CompareHashAndPassword([]byte(c.ClientSecret), []byte(secret))
+}
+
+// Validate 验证客户端对象是否有效
+func (c *Client) Validate() field.ErrorList {
+	val := validation.NewValidator(c)
+	allErrs := val.Validate()
+
+	grantPath := field.NewPath("grantTypes")
+	for i, grantType := range c.GrantTypes {
+		if !contains(supportedGrantTypes, grantType) {
+			allErrs = append(allErrs, field.NotSupported(grantPath.Index(i), grantType, supportedGrantTypes))
+		}
+	}
+	if c.Public && c.HasGrantType(GrantClientCredentials) {
+		allErrs = append(allErrs, field.Invalid(grantPath, c.GrantTypes, "public client can not use client_credentials"))
+	}
+
+	scopePath := field.NewPath("scopes")
+	for i, scope := range c.Scopes {
+		if !contains(supportedScopes, scope) {
+			allErrs = append(allErrs, field.NotSupported(scopePath.Index(i), scope, supportedScopes))
+		}
+	}
+
+	redirectPath := field.NewPath("redirectURIs")
+	if c.HasGrantType(GrantAuthorizationCode) && len(c.RedirectURIs) == 0 {
+		allErrs = append(allErrs, field.Required(redirectPath, "at least one redirect uri is required for authorization_code"))
+	}
+	for i, uri := range c.RedirectURIs {
+		if msg := validateRedirectURI(uri); msg != "" {
+			allErrs = append(allErrs, field.Invalid(redirectPath.Index(i), uri, msg))
+		}
+	}
+
+	return allErrs
+}
+
+// validateRedirectURI 回调地址必须为不带 fragment 的绝对地址, 除本机外只允许 https
+func validateRedirectURI(uri string) string {
+	u, err := url.Parse(uri)
+	if err != nil || !u.IsAbs() || u.Host == "" {
+		return "must be an absolute url"
+	}
+	if u.Fragment != "" || strings.Contains(uri, "#") {
+		return "must not contain a fragment"
+	}
+
+	switch u.Scheme {
+	case "https":
+	case "http":
+		if host := u.Hostname(); host != "localhost" && host != "127.0.0.1" && host != "::1" {
+			return "must use https except for localhost"
+		}
+	default:
+		return "scheme must be https or http"
+	}
+
+	return ""
+}
+
+func contains(items []string, item string) bool {
+	for _, v := range items {
+		if v == item {
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,52 @@
+package oauth
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+const clientJSON = `{"metadata":{"name":"wiki"},"redirectURIs":["https://wiki.example.com/callback","http://localhost:8080/cb"]}`
+
+func TestClientValidate(t *testing.T) {
+	var c Client
+	assert.NoError(t, json.Unmarshal([]byte(clientJSON), &c))
+	c.Default()
+	assert.Empty(t, c.Validate())
+	assert.Equal(t, []string{GrantAuthorizationCode, GrantRefreshToken}, c.GrantTypes)
+
+	c.RedirectURIs = []string{"http://wiki.example.com/callback", "https://wiki.example.com/#frag", "/callback"}
+	assert.Len(t, c.Validate(), 3)
+
+	c.RedirectURIs = nil
+	c.Public = true
+	c.GrantTypes = []string{GrantAuthorizationCode, GrantClientCredentials, "password"}
+	c.Scopes = []string{"admin"}
+	// 缺少回调地址, 公共客户端使用 client_credentials, 不支持的授权类型和 scope
+	assert.Len(t, c.Validate(), 4)
+}
+
+func TestClientShadow(t *testing.T) {
+	var c Client
+	assert.NoError(t, json.Unmarshal([]byte(clientJSON), &c))
+	c.Default()
+	assert.NoError(t, c.BeforeSave(nil))
+
+	got := Client{RedirectURIsShadow: c.RedirectURIsShadow, GrantTypesShadow: c.GrantTypesShadow, ScopesShadow: c.ScopesShadow}
+	assert.NoError(t, got.AfterFind(nil))
+	assert.Equal(t, c.RedirectURIs, got.RedirectURIs)
+	assert.Equal(t, c.GrantTypes, got.GrantTypes)
+	assert.True(t, got.AllowScope(ScopeEmail))
+}
+
+func TestClientSecret(t *testing.T) {
+	c := Client{ClientSecret: "secret"}
+	assert.NoError(t, c.HashSecret())
+	assert.NotEqual(t, "secret", c.ClientSecret)
+	assert.NoError(t, c.CompareSecret("secret"))
+	assert.Error(t, c.CompareSecret("wrong"))
+
+	c.Public = true
+	assert.Error(t, c.CompareSecret("secret"))
+}
@@ -0,0 +1,119 @@
+package policy
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/ory/ladon"
+	"github.com/ory/ladon/compiler"
+	"gorm.io/gorm"
+	metav1 "iam/pkg/api/meta/v1"
+	"iam/pkg/util/idutil"
+	"iam/pkg/validation"
+	"iam/pkg/validation/field"
+)
+
+// AuthzPolicy 对 ladon.DefaultPolicy 进行包装
+type AuthzPolicy struct {
+	ladon.DefaultPolicy
+}
+
+// String 序列化为 json 字符串, 存储到 policyShadow 中
+func (ap AuthzPolicy) String() string {
+	data, _ := json.Marshal(ap)
+
+	return string(data)
+}
+
+// Policy 授权策略, 属于某一个用户, 供 authz 服务进行验证
+type Policy struct {
+	metav1.ObjectMeta `json:"metadata,omitempty"` // 通用
+	Username          string                      `json:"username" gorm:"column:username" validate:"omitempty"`
+	Policy            AuthzPolicy                 `json:"policy,omitempty" gorm:"-" validate:"omitempty"`
+
+	// PolicyShadow 为 Policy 序列化后的值, 存储到 db 中. DO NOT modify directly.
+	PolicyShadow string `json:"-" gorm:"column:policyShadow" validate:"omitempty"`
+}
+
+func (p *Policy) TableName() string {
+	return "policy"
+}
+
+type PolicyList struct {
+	// Standard list metadata.
+	metav1.ListMeta `json:",inline"`
+
+	Items []*Policy `json:"items"`
+}
+
+// BeforeSave 创建/更新前, 将 Policy 序列化到 PolicyShadow 中
+func (p *Policy) BeforeSave(tx *gorm.DB) error {
+	p.Policy.ID = p.Name
+	p.PolicyShadow = p.Policy.String()
+
+	return nil
+}
+
+// AfterCreate 创建新数据后，进行添加 InstanceID
+func (p *Policy) AfterCreate(tx *gorm.DB) error {
+	p.InstanceID = idutil.GetInstanceID(p.ID, "policy-")
+
+	return tx.Save(p).Error
+}
+
+// AfterFind 查询后, 将 PolicyShadow 解析到 Policy 中
+func (p *Policy) AfterFind(tx *gorm.DB) error {
+	if p.PolicyShadow == "" {
+		return nil
+	}
+
+	if err := json.Unmarshal([]byte(p.PolicyShadow), &p.Policy); err != nil {
+		return fmt.Errorf("failed to unmarshal policyShadow: %w", err)
+	}
+
+	return nil
+}
+
+// Validate 验证策略对象是否有效, 包括 subjects/actions/resources 正则以及 effect
+func (p *Policy) Validate() field.ErrorList {
+	val := validation.NewValidator(p)
+	allErrs := val.Validate()
+
+	policyPath := field.NewPath("policy")
+
+	switch p.Policy.Effect {
+	case ladon.AllowAccess, ladon.DenyAccess:
+	default:
+		allErrs = append(allErrs, field.NotSupported(policyPath.Child("effect"), p.Policy.Effect,
+			[]string{ladon.AllowAccess, ladon.DenyAccess}))
+	}
+
+	allErrs = append(allErrs, validateTemplates(policyPath.Child("subjects"), p.Policy.Subjects)...)
+	allErrs = append(allErrs, validateTemplates(policyPath.Child("actions"), p.Policy.Actions)...)
+	allErrs = append(allErrs, validateTemplates(policyPath.Child("resources"), p.Policy.Resources)...)
+
+	// conditions 的类型在 json 解析时已经校验, 此处仅校验是否存在空值
+	for key, cond := range p.Policy.Conditions {
+		if cond == nil {
+			allErrs = append(allErrs, field.Required(policyPath.Child("conditions").Key(key), "condition can not be empty"))
+		}
+	}
+
+	return allErrs
+}
+
+// validateTemplates 验证 ladon 模板(<.*>)是否能够编译为正则
+func validateTemplates(fldPath *field.Path, templates []string) field.ErrorList {
+	allErrs := field.ErrorList{}
+
+	if len(templates) == 0 {
+		allErrs = append(allErrs, field.Required(fldPath, "at least one item is required"))
+	}
+
+	for i, tpl := range templates {
+		if _, err := compiler.CompileRegex(tpl, '<', '>'); err != nil {
+			allErrs = append(allErrs, field.Invalid(fldPath.Index(i), tpl, err.Error()))
+		}
+	}
+
+	return allErrs
+}
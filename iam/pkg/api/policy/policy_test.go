@@ -0,0 +1,49 @@
+package policy
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+const policyJSON = `{"metadata":{"name":"authztest"},
+ "policy":{
+      "description":"One policy to rule them all.",
+      "subjects":["users:<peter|ken>","users:maria","groups:admins"],
+      "actions":["delete","<create|update>"],
+      "effect":"allow",
+      "resources":["resources:articles:<.*>","resources:printer"],
+      "conditions":{"remoteIP":{"type":"CIDRCondition","options":{"cidr":"192.168.0.1/16"}}}}}`
+
+func TestPolicyValidate(t *testing.T) {
+	var p Policy
+	assert.NoError(t, json.Unmarshal([]byte(policyJSON), &p))
+	assert.Empty(t, p.Validate())
+
+	p.Policy.Effect = "maybe"
+	p.Policy.Actions = []string{"<create"}
+	assert.Len(t, p.Validate(), 2)
+
+	p.Policy.Subjects = nil
+	assert.Len(t, p.Validate(), 3)
+}
+
+func TestPolicyShadow(t *testing.T) {
+	var p Policy
+	assert.NoError(t, json.Unmarshal([]byte(policyJSON), &p))
+	assert.NoError(t, p.BeforeSave(nil))
+
+	got := Policy{PolicyShadow: p.PolicyShadow}
+	assert.NoError(t, got.AfterFind(nil))
+	assert.Equal(t, "authztest", got.Policy.ID)
+	assert.Equal(t, p.Policy.Subjects, got.Policy.Subjects)
+	assert.Contains(t, got.Policy.Conditions, "remoteIP")
+}
+
+func TestPolicyInvalidCondition(t *testing.T) {
+	data := `{"metadata":{"name":"bad"},"policy":{"conditions":{"x":{"type":"UnknownCondition"}}}}`
+
+	var p Policy
+	assert.Error(t, json.Unmarshal([]byte(data), &p))
+}
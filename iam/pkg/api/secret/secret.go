@@ -0,0 +1,55 @@
+package secret
+
+import (
+	"gorm.io/gorm"
+	metav1 "iam/pkg/api/meta/v1"
+	"iam/pkg/util/idutil"
+	"iam/pkg/validation"
+	"iam/pkg/validation/field"
+	"time"
+)
+
+// Secret 密钥对(AK/SK), 提供给 authz 服务进行验证请求者身份
+type Secret struct {
+	metav1.ObjectMeta `json:"metadata,omitempty"` // 通用
+	Username          string                      `json:"username" gorm:"column:username" validate:"omitempty"`
+	SecretID          string                      `json:"secretID" gorm:"column:secretID" validate:"omitempty"`
+	SecretKey         string                      `json:"secretKey" gorm:"column:secretKey" validate:"omitempty"`
+	Expires           int64                       `json:"expires" gorm:"column:expires" validate:"omitempty"` // 过期时间(unix秒), 0 表示永不过期
+	Description       string                      `json:"description" gorm:"column:description" validate:"description"`
+}
+
+func (s *Secret) TableName() string {
+	return "secret"
+}
+
+type SecretList struct {
+	// Standard list metadata.
+	metav1.ListMeta `json:",inline"`
+
+	Items []*Secret `json:"items"`
+}
+
+// AfterCreate 创建新数据后，进行添加 InstanceID
+func (s *Secret) AfterCreate(tx *gorm.DB) error {
+	s.InstanceID = idutil.GetInstanceID(s.ID, "secret-")
+
+	return tx.Save(s).Error
+}
+
+// IsExpired 密钥是否已过期
+func (s *Secret) IsExpired() bool {
+	return s.Expires != 0 && s.Expires < time.Now().Unix()
+}
+
+// Validate 验证密钥对象是否有效
+func (s *Secret) Validate() field.ErrorList {
+	val := validation.NewValidator(s)
+	allErrs := val.Validate()
+
+	if s.IsExpired() {
+		allErrs = append(allErrs, field.Invalid(field.NewPath("expires"), s.Expires, "must be 0 or a future unix timestamp"))
+	}
+
+	return allErrs
+}
@@ -0,0 +1,16 @@
+package user
+
+import "time"
+
+// Identity 本地用户与上游 IdP 用户的关联, 通过 issuer + subject 唯一确定一个上游用户
+type Identity struct {
+	ID        uint64    `json:"id,omitempty" gorm:"primary_key;AUTO_INCREMENT;column:id"`
+	Username  string    `json:"username" gorm:"column:username"`
+	Issuer    string    `json:"issuer" gorm:"column:issuer"`
+	Subject   string    `json:"subject" gorm:"column:subject"`
+	CreatedAt time.Time `json:"createdAt,omitempty" gorm:"column:createdAt"`
+}
+
+func (i *Identity) TableName() string {
+	return "user_identity"
+}
@@ -58,3 +58,10 @@ func (u *User) Validate() field.ErrorList {
 	}
 	return allErrs
 }
+
+// ValidateUpdate 验证更新时用户对象是否有效, 此时密码已经过哈希处理不再校验强度
+func (u *User) ValidateUpdate() field.ErrorList {
+	val := validation.NewValidator(u)
+
+	return val.Validate()
+}
@@ -6,6 +6,8 @@ import (
 	"errors"
 	"github.com/go-redis/redis"
 	"log"
+	"strconv"
+	"strings"
 	"sync/atomic"
 	"time"
 )
@@ -63,8 +65,18 @@ func NewRedisClusterPool(isCache bool, config *Config) redis.UniversalClient {
 	}
 	var client redis.UniversalClient
 
+	// 未配置 addrs 时使用 host(:port)
+	addrs := config.Addrs
+	if len(addrs) == 0 && config.Host != "" {
+		host := config.Host
+		if !strings.Contains(host, ":") && config.Port > 0 {
+			host = host + ":" + strconv.Itoa(config.Port)
+		}
+		addrs = []string{host}
+	}
+
 	opts := RedisOpts{
-		Addrs:        config.Addrs,
+		Addrs:        addrs,
 		MasterName:   config.MasterName,
 		Password:     config.Password,
 		DB:           config.Database,
@@ -349,3 +361,241 @@ func (r *RedisCluster) Publish(psChannel, message string) error {
 
 	return nil
 }
+
+// StartPubSubHandler 订阅 channel, 收到的消息通过 callback 进行处理, ctx 结束时关闭订阅并返回
+func (r *RedisCluster) StartPubSubHandler(ctx context.Context, channel string, callback func(interface{})) error {
+
+	if err := r.up(); err != nil {
+		return err
+	}
+
+	client := r.singleton()
+	if client == nil {
+		return ErrRedisIsDown
+	}
+
+	pubsub := client.Subscribe(channel)
+	defer pubsub.Close()
+
+	// ctx 结束时关闭订阅, 使阻塞的 Receive 返回
+	done := make(chan struct{})
+	defer close(done)
+	go func() {
+		select {
+		case <-ctx.Done():
+			_ = pubsub.Close()
+		case <-done:
+		}
+	}()
+
+	for {
+		msg, err := pubsub.Receive()
+		if err != nil {
+			if ctx.Err() != nil {
+				return nil
+			}
+			return err
+		}
+
+		callback(msg)
+	}
+}
+
+// 在 key 前加上统一前缀. key 以 hash tag 开头时将前缀放到 {} 中,
+// 保证 {name}.xxx 与 name 加上前缀后仍位于同一个 slot
+func (r *RedisCluster) fixKey(key string) string {
+	if r.KeyPrefix != "" && strings.HasPrefix(key, "{") {
+		return "{" + r.KeyPrefix + key[1:]
+	}
+
+	return r.KeyPrefix + key
+}
+
+// Connect 判断此时 redis 是否可用
+func (r *RedisCluster) Connect() bool {
+	return r.up() == nil && r.singleton() != nil
+}
+
+// AppendAnalytics 通过 pipeline 将 values 批量 RPUSH 到 key 对应的 list 尾部
+func (r *RedisCluster) AppendAnalytics(key string, values [][]byte) error {
+	if len(values) == 0 {
+		return nil
+	}
+
+	if !r.Connect() {
+		return ErrRedisIsDown
+	}
+
+	fixedKey := r.fixKey(key)
+	pipe := r.singleton().Pipeline()
+	defer pipe.Close()
+
+	for _, v := range values {
+		pipe.RPush(fixedKey, v)
+	}
+
+	_, err := pipe.Exec()
+
+	return err
+}
+
+// 将 KEYS[1] 头部最多 ARGV[1] 个元素原子地移动到 KEYS[2] 尾部, 并返回被移动的元素
+// 集群模式下两个 key 需要位于同一个 slot, 例如 name 和 {name}.xxx
+var moveListHeadScript = redis.NewScript(`
+local items = redis.call('LRANGE', KEYS[1], 0, tonumber(ARGV[1]) - 1)
+if #items > 0 then
+	redis.call('RPUSH', KEYS[2], unpack(items))
+	redis.call('LTRIM', KEYS[1], #items, -1)
+end
+return items
+`)
+
+// MoveListHead 原子地将 src 头部最多 count 个元素移动到 dst 中, 返回被移动的元素
+func (r *RedisCluster) MoveListHead(src, dst string, count int64) ([]string, error) {
+	if err := r.up(); err != nil {
+		return nil, err
+	}
+
+	res, err := moveListHeadScript.Run(r.singleton(), []string{r.fixKey(src), r.fixKey(dst)}, count).Result()
+	if err != nil {
+		return nil, err
+	}
+
+	return toStrings(res), nil
+}
+
+// 租约 KEYS[1] 已过期时, 将 KEYS[2] 的所有元素原子地移动到 KEYS[3] 尾部, 并将 ARGV[1] 从集合 KEYS[4] 中移除.
+// 租约仍然存在时返回 -1, 否则返回移动的元素个数; 集群模式下所有 key 需要位于同一个 slot
+var claimListScript = redis.NewScript(`
+if redis.call('EXISTS', KEYS[1]) == 1 then
+	return -1
+end
+local items = redis.call('LRANGE', KEYS[2], 0, -1)
+for i = 1, #items, 1000 do
+	redis.call('RPUSH', KEYS[3], unpack(items, i, math.min(i + 999, #items)))
+end
+redis.call('DEL', KEYS[2])
+redis.call('SREM', KEYS[4], ARGV[1])
+return #items
+`)
+
+// ClaimList 租约 lease 过期后将 src 中的元素全部移动到 dst 中, 并将 member 从集合 set 中移除.
+// 返回移动的元素个数, 租约仍然有效时返回 -1
+func (r *RedisCluster) ClaimList(lease, src, dst, set, member string) (int64, error) {
+	if err := r.up(); err != nil {
+		return 0, err
+	}
+
+	keys := []string{r.fixKey(lease), r.fixKey(src), r.fixKey(dst), r.fixKey(set)}
+	return claimListScript.Run(r.singleton(), keys, member).Int64()
+}
+
+// AddSetMember 将 member 加入 key 对应的集合
+func (r *RedisCluster) AddSetMember(key, member string) error {
+	if err := r.up(); err != nil {
+		return err
+	}
+
+	return r.singleton().SAdd(r.fixKey(key), member).Err()
+}
+
+// GetSetMembers 获取 key 对应集合的所有元素
+func (r *RedisCluster) GetSetMembers(key string) ([]string, error) {
+	if err := r.up(); err != nil {
+		return nil, err
+	}
+
+	return r.singleton().SMembers(r.fixKey(key)).Result()
+}
+
+// GetList 获取 key 对应 list 的所有元素
+func (r *RedisCluster) GetList(key string) ([]string, error) {
+	if err := r.up(); err != nil {
+		return nil, err
+	}
+
+	return r.singleton().LRange(r.fixKey(key), 0, -1).Result()
+}
+
+// DeleteKey 删除 key
+func (r *RedisCluster) DeleteKey(key string) error {
+	if err := r.up(); err != nil {
+		return err
+	}
+
+	return r.singleton().Del(r.fixKey(key)).Err()
+}
+
+// SetKey 设置 key 的值, expire 为 0 时不过期
+func (r *RedisCluster) SetKey(key, value string, expire time.Duration) error {
+	if err := r.up(); err != nil {
+		return err
+	}
+
+	return r.singleton().Set(r.fixKey(key), value, expire).Err()
+}
+
+// 原子地获取并删除 KEYS[1], 兼容不支持 GETDEL 的 redis 版本
+var takeKeyScript = redis.NewScript(`
+local value = redis.call('GET', KEYS[1])
+if value then
+	redis.call('DEL', KEYS[1])
+end
+return value
+`)
+
+// TakeKey 获取 key 的值并删除, 用于只能使用一次的值, key 不存在时返回空字符串
+func (r *RedisCluster) TakeKey(key string) (string, error) {
+	if err := r.up(); err != nil {
+		return "", err
+	}
+
+	value, err := takeKeyScript.Run(r.singleton(), []string{r.fixKey(key)}).Result()
+	if err == redis.Nil {
+		return "", nil
+	}
+	if err != nil {
+		return "", err
+	}
+
+	s, _ := value.(string)
+	return s, nil
+}
+
+// GetMultiKey 通过 pipeline 获取多个 key 的值, 不存在的 key 对应空字符串; 集群模式下 key 可以位于不同的 slot
+func (r *RedisCluster) GetMultiKey(keys []string) ([]string, error) {
+	if err := r.up(); err != nil {
+		return nil, err
+	}
+
+	pipe := r.singleton().Pipeline()
+	defer pipe.Close()
+
+	cmds := make([]*redis.StringCmd, 0, len(keys))
+	for _, key := range keys {
+		cmds = append(cmds, pipe.Get(r.fixKey(key)))
+	}
+
+	if _, err := pipe.Exec(); err != nil && err != redis.Nil {
+		return nil, err
+	}
+
+	values := make([]string, 0, len(keys))
+	for _, cmd := range cmds {
+		values = append(values, cmd.Val())
+	}
+
+	return values, nil
+}
+
+func toStrings(res interface{}) []string {
+	items, _ := res.([]interface{})
+	values := make([]string, 0, len(items))
+	for _, item := range items {
+		if v, ok := item.(string); ok {
+			values = append(values, v)
+		}
+	}
+
+	return values
+}
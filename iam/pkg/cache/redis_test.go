@@ -0,0 +1,17 @@
+package cache
+
+import (
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestRedisCluster_fixKey(t *testing.T) {
+	r := &RedisCluster{}
+	assert.Equal(t, "analytics", r.fixKey("analytics"))
+	assert.Equal(t, "{analytics}.inflight.pump-0", r.fixKey("{analytics}.inflight.pump-0"))
+
+	// 前缀放入 hash tag 中, 与 prefix+analytics 位于同一个 slot
+	r.KeyPrefix = "iam:"
+	assert.Equal(t, "iam:analytics", r.fixKey("analytics"))
+	assert.Equal(t, "{iam:analytics}.inflight.pump-0", r.fixKey("{analytics}.inflight.pump-0"))
+}
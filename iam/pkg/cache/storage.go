@@ -1,6 +1,6 @@
 package cache
 
 type AnalyticsHandler interface {
-	Connect() bool                              // 判断是否能够连接
-	AppendAnalytics(key string, bytes [][]byte) // 存储写入到redis的值
+	Connect() bool                                    // 判断是否能够连接
+	AppendAnalytics(key string, bytes [][]byte) error // 存储写入到redis的值, 失败时由调用方决定是否重试
 }
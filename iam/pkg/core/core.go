@@ -2,30 +2,40 @@ package core
 
 import (
 	"github.com/gin-gonic/gin"
+	"github.com/sirupsen/logrus"
+	"iam/pkg/errors"
+	"net/http"
 )
 
+// ErrResponse 出现错误时返回的结构, Code 为注册的错误码
 type ErrResponse struct {
-	Code      int
-	Message   string
-	Reference string
+	Code      int    `json:"code"`
+	Message   string `json:"message"`
+	Reference string `json:"reference,omitempty"`
 }
 
-func WriteResponse(c *gin.Context, code int, err error, data interface{}) {
-
-	//if err != nil  || {
-	//
-	//	coder := errors.GetCodes(code)
-	//
-	//	//coder := errors.ParseCoder(err)
-	//	c.JSON(coder.HTTPStatus(), ErrResponse{
-	//		Code:      coder.Code(),
-	//		Message:   coder.Msg(),
-	//		Reference: coder.Reference(),
-	//	})
-	//
-	//	return
-	//}
-
-	c.JSON(code, data)
-	return
+// WriteResponse err 不为 nil 时根据错误码返回 ErrResponse 和对应的 http 状态码, 否则返回 200 和 data
+func WriteResponse(c *gin.Context, err error, data interface{}) {
+
+	if err != nil {
+		logrus.Errorf("%+v", err)
+
+		coder := errors.ParseCoder(err)
+
+		// 5xx 不对外暴露内部的错误信息
+		message := errors.Message(err)
+		if message == "" || coder.HTTPStatus() >= http.StatusInternalServerError {
+			message = coder.Msg()
+		}
+
+		c.JSON(coder.HTTPStatus(), ErrResponse{
+			Code:      coder.Code(),
+			Message:   message,
+			Reference: coder.Reference(),
+		})
+
+		return
+	}
+
+	c.JSON(http.StatusOK, data)
 }
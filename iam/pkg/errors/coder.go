@@ -24,7 +24,8 @@ type defaultCoder struct {
 	Ref string
 }
 
-var dCoder = defaultCoder{0, 0, "未知code", ""}
+// unknownCoder 未注册的错误码或者不带错误码的错误
+var unknownCoder = defaultCoder{1, 500, "An internal server error occurred", ""}
 
 func (dc defaultCoder) HTTPStatus() int {
 	return dc.HTTP
@@ -58,15 +59,15 @@ func Register(coder Coder) {
 
 // MustRegister  判断是否存在，若存在则进行panic，不进行覆盖
 func MustRegister(coder Coder) {
+	// 加锁
+	rw.Lock()
+	defer rw.Unlock()
+
 	// 判断是否存在，若存在则进行panic
 	if _, ok := codes[coder.Code()]; ok {
 		panic(fmt.Sprintf("coder code:%d exist", coder.Code()))
 	}
 
-	// 加锁
-	rw.Lock()
-	defer rw.Unlock()
-
 	codes[coder.Code()] = coder
 }
 
@@ -79,21 +80,41 @@ func GetCodes(code int) Coder {
 		return coder
 	}
 
-	return dCoder
+	return unknownCoder
 }
 
+// ParseCoder 得到 err 中最外层的错误码对应的 Coder, err 为 nil 时返回 nil, 不带错误码时返回 unknownCoder
 func ParseCoder(err error) Coder {
-	//if err == nil {
-	//	return nil
-	//}
-	//
-	//if v, ok := err.(*withCode); ok {
-	//	if coder, ok := codes[v.code]; ok {
-	//		return coder
-	//	}
-	//}
-	//
-	//return unknownCoder
-
-	return nil
+	if err == nil {
+		return nil
+	}
+
+	var w *withCode
+	if As(err, &w) {
+		return GetCodes(w.code)
+	}
+
+	return unknownCoder
+}
+
+// IsCode err 的错误链中是否存在该错误码
+func IsCode(err error, code int) bool {
+	for err != nil {
+		if w, ok := err.(*withCode); ok && w.code == code {
+			return true
+		}
+		err = Unwrap(err)
+	}
+
+	return false
+}
+
+// Message 得到 err 中最外层错误码的错误信息(不包含被包装的错误), 不带错误码时返回空
+func Message(err error) string {
+	var w *withCode
+	if As(err, &w) {
+		return w.err.Error()
+	}
+
+	return ""
 }
@@ -1,13 +1,230 @@
 package errors
 
-// stack represents a stack of program counters.
-type stack []uintptr
+import (
+	stderrors "errors"
+	"fmt"
+	"io"
+)
 
-type withCode struct {
-	err   error
-	code  int
+// 支持，通过 withCode 的方式构建成 err ,然后通过code得到coder
+// 所有的构造函数都会记录调用栈, 通过 %+v 打印
+
+// New 根据 message 创建错误
+func New(message string) error {
+	return &fundamental{
+		msg:   message,
+		stack: callers(),
+	}
+}
+
+// Errorf 根据 format 创建错误
+func Errorf(format string, args ...interface{}) error {
+	return &fundamental{
+		msg:   fmt.Sprintf(format, args...),
+		stack: callers(),
+	}
+}
+
+// fundamental 只包含错误信息和调用栈
+type fundamental struct {
+	msg string
+	*stack
+}
+
+func (f *fundamental) Error() string { return f.msg }
+
+func (f *fundamental) Format(s fmt.State, verb rune) {
+	switch verb {
+	case 'v':
+		if s.Flag('+') {
+			_, _ = io.WriteString(s, f.msg)
+			f.stack.Format(s, verb)
+			return
+		}
+		fallthrough
+	case 's':
+		_, _ = io.WriteString(s, f.msg)
+	case 'q':
+		_, _ = fmt.Fprintf(s, "%q", f.msg)
+	}
+}
+
+// WithStack 为 err 添加调用栈
+func WithStack(err error) error {
+	if err == nil {
+		return nil
+	}
+
+	return &withStack{err, callers()}
+}
+
+type withStack struct {
+	error
+	*stack
+}
+
+func (w *withStack) Cause() error { return w.error }
+
+func (w *withStack) Unwrap() error { return w.error }
+
+func (w *withStack) Format(s fmt.State, verb rune) {
+	switch verb {
+	case 'v':
+		if s.Flag('+') {
+			_, _ = fmt.Fprintf(s, "%+v", w.Cause())
+			w.stack.Format(s, verb)
+			return
+		}
+		fallthrough
+	case 's':
+		_, _ = io.WriteString(s, w.Error())
+	case 'q':
+		_, _ = fmt.Fprintf(s, "%q", w.Error())
+	}
+}
+
+// Wrap 为 err 添加 message 和调用栈, err 为 nil 时返回 nil
+func Wrap(err error, message string) error {
+	if err == nil {
+		return nil
+	}
+
+	return &withStack{
+		&withMessage{cause: err, msg: message},
+		callers(),
+	}
+}
+
+// Wrapf 为 err 添加 format 格式的 message 和调用栈, err 为 nil 时返回 nil
+func Wrapf(err error, format string, args ...interface{}) error {
+	if err == nil {
+		return nil
+	}
+
+	return &withStack{
+		&withMessage{cause: err, msg: fmt.Sprintf(format, args...)},
+		callers(),
+	}
+}
+
+// WithMessage 为 err 添加 message, 不记录调用栈
+func WithMessage(err error, message string) error {
+	if err == nil {
+		return nil
+	}
+
+	return &withMessage{cause: err, msg: message}
+}
+
+type withMessage struct {
 	cause error
+	msg   string
+}
+
+func (w *withMessage) Error() string { return w.msg + ": " + w.cause.Error() }
+
+func (w *withMessage) Cause() error { return w.cause }
+
+func (w *withMessage) Unwrap() error { return w.cause }
+
+func (w *withMessage) Format(s fmt.State, verb rune) {
+	switch verb {
+	case 'v':
+		if s.Flag('+') {
+			_, _ = fmt.Fprintf(s, "%+v\n", w.Cause())
+			_, _ = io.WriteString(s, w.msg)
+			return
+		}
+		fallthrough
+	case 's', 'q':
+		_, _ = io.WriteString(s, w.Error())
+	}
+}
+
+// withCode 带有错误码的错误, 通过 code 可以从注册的 Coder 中得到 http 状态码和对外展示的信息
+type withCode struct {
+	err   error // 错误信息
+	code  int   // 错误码
+	cause error // 被包装的错误
 	*stack
 }
 
-// 支持，通过 withCode 的方式构建成 err ,然后通过code得到coder
+// WithCode 根据错误码创建错误
+func WithCode(code int, format string, args ...interface{}) error {
+	return &withCode{
+		err:   fmt.Errorf(format, args...),
+		code:  code,
+		stack: callers(),
+	}
+}
+
+// WrapC 为 err 添加错误码和 message, err 为 nil 时返回 nil
+func WrapC(err error, code int, format string, args ...interface{}) error {
+	if err == nil {
+		return nil
+	}
+
+	return &withCode{
+		err:   fmt.Errorf(format, args...),
+		code:  code,
+		cause: err,
+		stack: callers(),
+	}
+}
+
+func (w *withCode) Error() string {
+	if w.cause != nil {
+		return w.err.Error() + ": " + w.cause.Error()
+	}
+
+	return w.err.Error()
+}
+
+func (w *withCode) Cause() error { return w.cause }
+
+func (w *withCode) Unwrap() error { return w.cause }
+
+func (w *withCode) Format(s fmt.State, verb rune) {
+	switch verb {
+	case 'v':
+		if s.Flag('+') {
+			if w.cause != nil {
+				_, _ = fmt.Fprintf(s, "%+v\n", w.cause)
+			}
+			_, _ = fmt.Fprintf(s, "code: %d, %s", w.code, w.err.Error())
+			w.stack.Format(s, verb)
+			return
+		}
+		fallthrough
+	case 's':
+		_, _ = io.WriteString(s, w.Error())
+	case 'q':
+		_, _ = fmt.Fprintf(s, "%q", w.Error())
+	}
+}
+
+// Cause 返回最底层的错误
+func Cause(err error) error {
+	type causer interface {
+		Cause() error
+	}
+
+	for err != nil {
+		cause, ok := err.(causer)
+		if !ok || cause.Cause() == nil {
+			break
+		}
+		err = cause.Cause()
+	}
+
+	return err
+}
+
+// Is 同标准库 errors.Is
+func Is(err, target error) bool { return stderrors.Is(err, target) }
+
+// As 同标准库 errors.As
+func As(err error, target interface{}) bool { return stderrors.As(err, target) }
+
+// Unwrap 同标准库 errors.Unwrap
+func Unwrap(err error) error { return stderrors.Unwrap(err) }
@@ -0,0 +1,89 @@
+package errors
+
+import (
+	stderrors "errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+const (
+	testCodeNotFound = 900001
+	testCodeDatabase = 900002
+)
+
+func init() {
+	Register(defaultCoder{testCodeNotFound, http.StatusNotFound, "Not found", "https://example.com/404"})
+	Register(defaultCoder{testCodeDatabase, http.StatusInternalServerError, "Database error", ""})
+}
+
+func TestWithCode(t *testing.T) {
+	err := WithCode(testCodeNotFound, "user %s not found", "colin")
+	assert.EqualError(t, err, "user colin not found")
+
+	coder := ParseCoder(err)
+	assert.Equal(t, testCodeNotFound, coder.Code())
+	assert.Equal(t, http.StatusNotFound, coder.HTTPStatus())
+	assert.Equal(t, "https://example.com/404", coder.Reference())
+	assert.Equal(t, "user colin not found", Message(err))
+	assert.True(t, IsCode(err, testCodeNotFound))
+	assert.False(t, IsCode(err, testCodeDatabase))
+}
+
+func TestWrapC(t *testing.T) {
+	assert.Nil(t, WrapC(nil, testCodeDatabase, "query failed"))
+
+	err := WrapC(io.EOF, testCodeDatabase, "query %s failed", "user")
+	assert.EqualError(t, err, "query user failed: EOF")
+	assert.Equal(t, "query user failed", Message(err))
+	assert.True(t, Is(err, io.EOF))
+	assert.Equal(t, io.EOF, Cause(err))
+
+	// 外层的错误码优先
+	outer := WrapC(err, testCodeNotFound, "not found")
+	assert.Equal(t, testCodeNotFound, ParseCoder(outer).Code())
+	assert.True(t, IsCode(outer, testCodeDatabase))
+}
+
+func TestWrap(t *testing.T) {
+	assert.Nil(t, Wrap(nil, "message"))
+	assert.Nil(t, Wrapf(nil, "message %d", 1))
+
+	err := Wrapf(WithCode(testCodeNotFound, "not found"), "get %s", "colin")
+	assert.EqualError(t, err, "get colin: not found")
+	assert.Equal(t, testCodeNotFound, ParseCoder(err).Code())
+
+	var w *withCode
+	assert.True(t, As(err, &w))
+	assert.Equal(t, testCodeNotFound, w.code)
+
+	// 标准库的 errors.Is 同样可以使用
+	err = Wrap(io.ErrUnexpectedEOF, "read body")
+	assert.True(t, stderrors.Is(err, io.ErrUnexpectedEOF))
+}
+
+func TestParseCoder(t *testing.T) {
+	assert.Nil(t, ParseCoder(nil))
+	assert.Equal(t, unknownCoder, ParseCoder(New("plain")))
+	assert.Equal(t, unknownCoder, ParseCoder(WithCode(-1, "unregistered")))
+	assert.Equal(t, "", Message(New("plain")))
+}
+
+func TestStack(t *testing.T) {
+	for _, err := range []error{
+		New("new"),
+		Errorf("errorf %d", 1),
+		Wrap(io.EOF, "wrap"),
+		WithCode(testCodeNotFound, "with code"),
+		WrapC(io.EOF, testCodeDatabase, "wrap code"),
+	} {
+		verbose := fmt.Sprintf("%+v", err)
+		assert.True(t, strings.Contains(verbose, "errors_test.go"), verbose)
+		assert.True(t, strings.Contains(verbose, "TestStack"), verbose)
+		assert.Equal(t, err.Error(), fmt.Sprintf("%v", err))
+	}
+}
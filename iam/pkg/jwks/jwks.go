@@ -0,0 +1,334 @@
+package jwks
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"github.com/golang-jwt/jwt/v4"
+	"math/big"
+	"os"
+)
+
+const minRSABits = 2048
+
+var (
+	ErrMissingKID    = errors.New("invalid token format: missing kid field in header")
+	ErrUnknownKID    = errors.New("unknown kid")
+	ErrNoSigningKey  = errors.New("no signing key configured")
+	ErrNotPrivateKey = errors.New("key can not be used for signing")
+)
+
+// Key 签名/验签密钥, 算法由密钥类型决定: RSA -> RS256, P-256 -> ES256, Ed25519 -> EdDSA, []byte -> HS256
+type Key struct {
+	ID     string
+	Method jwt.SigningMethod
+
+	private interface{} // 为空时仅用于验签
+	public  interface{} // HMAC 时与 private 相同
+}
+
+// KeyFile 从 PEM 文件加载的密钥, 只配置公钥时该密钥仅用于验签
+type KeyFile struct {
+	ID             string
+	PrivateKeyFile string
+	PublicKeyFile  string
+}
+
+// KeySet 按 kid 管理多个密钥, 轮换期间新旧密钥同时用于验签, 只使用一个密钥签发
+type KeySet struct {
+	signing *Key
+	keys    map[string]*Key
+	ordered []*Key
+}
+
+// NewKey 通过私钥或公钥创建密钥, 支持 *rsa.PrivateKey *ecdsa.PrivateKey ed25519.PrivateKey 及对应的公钥, 以及 HMAC 的 []byte
+func NewKey(id string, key interface{}) (*Key, error) {
+	k := &Key{ID: id}
+
+	switch v := key.(type) {
+	case []byte:
+		if len(v) == 0 {
+			return nil, fmt.Errorf("key %s: empty hmac secret", id)
+		}
+		k.private, k.public = v, v
+	case *rsa.PrivateKey, *ecdsa.PrivateKey, ed25519.PrivateKey:
+		k.private, k.public = v, v.(crypto.Signer).Public()
+	default:
+		k.public = v
+	}
+
+	method, err := signingMethod(k.public)
+	if err != nil {
+		return nil, fmt.Errorf("key %s: %w", id, err)
+	}
+	k.Method = method
+
+	return k, nil
+}
+
+// LoadKey 从 PEM 文件加载密钥, 同时配置私钥和公钥时校验二者是否匹配
+func LoadKey(file KeyFile) (*Key, error) {
+	if file.PrivateKeyFile == "" && file.PublicKeyFile == "" {
+		return nil, fmt.Errorf("key %s: private or public key file must be set", file.ID)
+	}
+
+	var private, public interface{}
+	if file.PrivateKeyFile != "" {
+		data, err := os.ReadFile(file.PrivateKeyFile)
+		if err != nil {
+			return nil, fmt.Errorf("key %s: %w", file.ID, err)
+		}
+		if private, err = ParsePrivateKeyPEM(data); err != nil {
+			return nil, fmt.Errorf("key %s: parse %s failed: %w", file.ID, file.PrivateKeyFile, err)
+		}
+	}
+	if file.PublicKeyFile != "" {
+		data, err := os.ReadFile(file.PublicKeyFile)
+		if err != nil {
+			return nil, fmt.Errorf("key %s: %w", file.ID, err)
+		}
+		if public, err = ParsePublicKeyPEM(data); err != nil {
+			return nil, fmt.Errorf("key %s: parse %s failed: %w", file.ID, file.PublicKeyFile, err)
+		}
+	}
+
+	if private == nil {
+		return NewKey(file.ID, public)
+	}
+
+	key, err := NewKey(file.ID, private)
+	if err != nil {
+		return nil, err
+	}
+	if public != nil {
+		if eq, ok := key.public.(interface{ Equal(crypto.PublicKey) bool }); !ok || !eq.Equal(public) {
+			return nil, fmt.Errorf("key %s: public key does not match the private key", file.ID)
+		}
+	}
+
+	return key, nil
+}
+
+// Load 加载多个 PEM 密钥, signingKid 为空时使用第一个带私钥的密钥签发
+func Load(signingKid string, files []KeyFile) (*KeySet, error) {
+	keys := make([]*Key, 0, len(files))
+	for _, file := range files {
+		key, err := LoadKey(file)
+		if err != nil {
+			return nil, err
+		}
+		keys = append(keys, key)
+	}
+
+	return New(signingKid, keys...)
+}
+
+// New 通过内存中的密钥创建 KeySet
+func New(signingKid string, keys ...*Key) (*KeySet, error) {
+	ks := &KeySet{keys: make(map[string]*Key, len(keys))}
+
+	for _, key := range keys {
+		if _, ok := ks.keys[key.ID]; ok {
+			return nil, fmt.Errorf("duplicate kid %q", key.ID)
+		}
+		ks.keys[key.ID] = key
+		ks.ordered = append(ks.ordered, key)
+
+		if ks.signing == nil && signingKid == "" && key.private != nil {
+			ks.signing = key
+		}
+	}
+
+	if signingKid != "" {
+		key, ok := ks.keys[signingKid]
+		if !ok {
+			return nil, fmt.Errorf("signing kid %q: %w", signingKid, ErrUnknownKID)
+		}
+		if key.private == nil {
+			return nil, fmt.Errorf("signing kid %q: %w", signingKid, ErrNotPrivateKey)
+		}
+		ks.signing = key
+	}
+
+	return ks, nil
+}
+
+// NewHMAC 只包含一个 HMAC 密钥的 KeySet, 签发的 token 不带 kid, 兼容未配置非对称密钥的部署
+func NewHMAC(secret []byte) (*KeySet, error) {
+	key, err := NewKey("", secret)
+	if err != nil {
+		return nil, err
+	}
+
+	return New("", key)
+}
+
+// SigningKey 返回用于签发的密钥, 未配置私钥时为 nil
+func (ks *KeySet) SigningKey() *Key {
+	return ks.signing
+}
+
+// Sign 使用签发密钥签名, 非空的 kid 写入 header
+func (ks *KeySet) Sign(claims jwt.Claims) (string, error) {
+	if ks.signing == nil {
+		return "", ErrNoSigningKey
+	}
+
+	token := jwt.NewWithClaims(ks.signing.Method, claims)
+	if ks.signing.ID != "" {
+		token.Header["kid"] = ks.signing.ID
+	}
+
+	return token.SignedString(ks.signing.private)
+}
+
+// Keyfunc 按 header 中的 kid 选择验签密钥, 并校验 token 的算法与密钥一致, 避免算法混淆
+func (ks *KeySet) Keyfunc(token *jwt.Token) (interface{}, error) {
+	kid, _ := token.Header["kid"].(string)
+
+	key, ok := ks.keys[kid]
+	if !ok {
+		if kid == "" {
+			return nil, ErrMissingKID
+		}
+		return nil, fmt.Errorf("%w: %s", ErrUnknownKID, kid)
+	}
+
+	if token.Method.Alg() != key.Method.Alg() {
+		return nil, fmt.Errorf("unexpected signing method %s for kid %q", token.Method.Alg(), kid)
+	}
+
+	return key.public, nil
+}
+
+// JSONWebKey RFC 7517 中的公钥
+type JSONWebKey struct {
+	Kty string `json:"kty"`
+	Kid string `json:"kid,omitempty"`
+	Use string `json:"use"`
+	Alg string `json:"alg"`
+	N   string `json:"n,omitempty"`
+	E   string `json:"e,omitempty"`
+	Crv string `json:"crv,omitempty"`
+	X   string `json:"x,omitempty"`
+	Y   string `json:"y,omitempty"`
+}
+
+type JSONWebKeySet struct {
+	Keys []JSONWebKey `json:"keys"`
+}
+
+// JWKS 返回所有非对称密钥的公钥, HMAC 密钥不会对外暴露
+func (ks *KeySet) JWKS() JSONWebKeySet {
+	set := JSONWebKeySet{Keys: make([]JSONWebKey, 0, len(ks.ordered))}
+
+	for _, key := range ks.ordered {
+		jwk := JSONWebKey{Kid: key.ID, Use: "sig", Alg: key.Method.Alg()}
+
+		switch pub := key.public.(type) {
+		case *rsa.PublicKey:
+			jwk.Kty = "RSA"
+			jwk.N = encode(pub.N.Bytes())
+			jwk.E = encode(big.NewInt(int64(pub.E)).Bytes())
+		case *ecdsa.PublicKey:
+			size := (pub.Curve.Params().BitSize + 7) / 8
+			jwk.Kty = "EC"
+			jwk.Crv = pub.Curve.Params().Name
+			jwk.X = encode(pub.X.FillBytes(make([]byte, size)))
+			jwk.Y = encode(pub.Y.FillBytes(make([]byte, size)))
+		case ed25519.PublicKey:
+			jwk.Kty = "OKP"
+			jwk.Crv = "Ed25519"
+			jwk.X = encode(pub)
+		default:
+			continue
+		}
+
+		set.Keys = append(set.Keys, jwk)
+	}
+
+	return set
+}
+
+func encode(b []byte) string {
+	return base64.RawURLEncoding.EncodeToString(b)
+}
+
+// signingMethod 根据公钥类型得到签名算法
+func signingMethod(public interface{}) (jwt.SigningMethod, error) {
+	switch pub := public.(type) {
+	case []byte:
+		return jwt.SigningMethodHS256, nil
+	case *rsa.PublicKey:
+		if pub.N.BitLen() < minRSABits {
+			return nil, fmt.Errorf("rsa key must be at least %d bits", minRSABits)
+		}
+		return jwt.SigningMethodRS256, nil
+	case *ecdsa.PublicKey:
+		switch pub.Curve {
+		case elliptic.P256():
+			return jwt.SigningMethodES256, nil
+		case elliptic.P384():
+			return jwt.SigningMethodES384, nil
+		case elliptic.P521():
+			return jwt.SigningMethodES512, nil
+		}
+		return nil, fmt.Errorf("unsupported ecdsa curve %s", pub.Curve.Params().Name)
+	case ed25519.PublicKey:
+		return jwt.SigningMethodEdDSA, nil
+	}
+
+	return nil, fmt.Errorf("unsupported key type %T", public)
+}
+
+// ParsePrivateKeyPEM 解析 PKCS#8, PKCS#1 和 SEC 1 格式的私钥
+func ParsePrivateKeyPEM(data []byte) (interface{}, error) {
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, errors.New("no pem block found")
+	}
+
+	if key, err := x509.ParsePKCS8PrivateKey(block.Bytes); err == nil {
+		return key, nil
+	}
+	if key, err := x509.ParsePKCS1PrivateKey(block.Bytes); err == nil {
+		return key, nil
+	}
+	if key, err := x509.ParseECPrivateKey(block.Bytes); err == nil {
+		return key, nil
+	}
+
+	return nil, fmt.Errorf("unsupported private key type %s", block.Type)
+}
+
+// ParsePublicKeyPEM 解析 PKIX, PKCS#1 格式的公钥或证书
+func ParsePublicKeyPEM(data []byte) (interface{}, error) {
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, errors.New("no pem block found")
+	}
+
+	if block.Type == "CERTIFICATE" {
+		cert, err := x509.ParseCertificate(block.Bytes)
+		if err != nil {
+			return nil, err
+		}
+		return cert.PublicKey, nil
+	}
+
+	if key, err := x509.ParsePKIXPublicKey(block.Bytes); err == nil {
+		return key, nil
+	}
+	if key, err := x509.ParsePKCS1PublicKey(block.Bytes); err == nil {
+		return key, nil
+	}
+
+	return nil, fmt.Errorf("unsupported public key type %s", block.Type)
+}
@@ -0,0 +1,132 @@
+package jwks
+
+import (
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/pem"
+	"github.com/golang-jwt/jwt/v4"
+	"github.com/stretchr/testify/assert"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func writePEM(t *testing.T, dir, name, typ string, der []byte) string {
+	path := filepath.Join(dir, name)
+	assert.NoError(t, os.WriteFile(path, pem.EncodeToMemory(&pem.Block{Type: typ, Bytes: der}), 0o600))
+
+	return path
+}
+
+func TestLoad(t *testing.T) {
+	dir := t.TempDir()
+
+	rsaKey, _ := rsa.GenerateKey(rand.Reader, 2048)
+	ecKey, _ := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	_, edKey, _ := ed25519.GenerateKey(rand.Reader)
+	otherKey, _ := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+
+	ecDer, _ := x509.MarshalECPrivateKey(ecKey)
+	edDer, _ := x509.MarshalPKCS8PrivateKey(edKey)
+	ecPubDer, _ := x509.MarshalPKIXPublicKey(&ecKey.PublicKey)
+	otherPubDer, _ := x509.MarshalPKIXPublicKey(&otherKey.PublicKey)
+
+	rsaFile := writePEM(t, dir, "rsa.pem", "RSA PRIVATE KEY", x509.MarshalPKCS1PrivateKey(rsaKey))
+	ecFile := writePEM(t, dir, "ec.pem", "EC PRIVATE KEY", ecDer)
+	ecPubFile := writePEM(t, dir, "ec.pub", "PUBLIC KEY", ecPubDer)
+	edFile := writePEM(t, dir, "ed.pem", "PRIVATE KEY", edDer)
+	otherPubFile := writePEM(t, dir, "other.pub", "PUBLIC KEY", otherPubDer)
+
+	ks, err := Load("ed", []KeyFile{
+		{ID: "rsa", PrivateKeyFile: rsaFile},
+		{ID: "ec", PrivateKeyFile: ecFile, PublicKeyFile: ecPubFile},
+		{ID: "ed", PrivateKeyFile: edFile},
+		{ID: "verify-only", PublicKeyFile: otherPubFile},
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, "ed", ks.SigningKey().ID)
+	assert.Equal(t, "EdDSA", ks.SigningKey().Method.Alg())
+
+	set := ks.JWKS()
+	assert.Len(t, set.Keys, 4)
+	assert.Equal(t, JSONWebKey{Kty: "RSA", Kid: "rsa", Use: "sig", Alg: "RS256", N: set.Keys[0].N, E: "AQAB"}, set.Keys[0])
+	assert.Equal(t, "ES256", set.Keys[1].Alg)
+	assert.Equal(t, "P-256", set.Keys[1].Crv)
+	assert.Len(t, set.Keys[1].X, 43) // 32 字节 base64url
+	assert.Equal(t, "OKP", set.Keys[2].Kty)
+	assert.Equal(t, "Ed25519", set.Keys[2].Crv)
+
+	// 公私钥不匹配
+	_, err = Load("", []KeyFile{{ID: "ec", PrivateKeyFile: ecFile, PublicKeyFile: otherPubFile}})
+	assert.Error(t, err)
+
+	// 只有公钥的密钥不能用于签发
+	_, err = Load("verify-only", []KeyFile{{ID: "verify-only", PublicKeyFile: otherPubFile}})
+	assert.ErrorIs(t, err, ErrNotPrivateKey)
+
+	_, err = Load("", []KeyFile{{ID: "a", PrivateKeyFile: ecFile}, {ID: "a", PrivateKeyFile: edFile}})
+	assert.Error(t, err)
+}
+
+func TestKeyfunc(t *testing.T) {
+	rsaKey, _ := rsa.GenerateKey(rand.Reader, 2048)
+	ecKey, _ := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+
+	oldKey, _ := NewKey("old", rsaKey)
+	newKey, _ := NewKey("new", ecKey)
+
+	before, _ := New("old", oldKey, newKey)
+	after, _ := New("new", oldKey, newKey)
+
+	claims := jwt.MapClaims{"sub": "colin", "exp": time.Now().Add(time.Hour).Unix()}
+	oldToken, err := before.Sign(claims)
+	assert.NoError(t, err)
+	newToken, err := after.Sign(claims)
+	assert.NoError(t, err)
+
+	for _, token := range []string{oldToken, newToken} {
+		parsed, err := jwt.Parse(token, after.Keyfunc)
+		assert.NoError(t, err)
+		assert.True(t, parsed.Valid)
+	}
+
+	// 使用公钥作为 HMAC 密钥伪造的 token
+	pubDer, _ := x509.MarshalPKIXPublicKey(&rsaKey.PublicKey)
+	forged := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
+	forged.Header["kid"] = "old"
+	forgedStr, _ := forged.SignedString(pubDer)
+	_, err = jwt.Parse(forgedStr, after.Keyfunc)
+	assert.Error(t, err)
+
+	// 未知 kid 和缺少 kid
+	unknown := jwt.NewWithClaims(jwt.SigningMethodES256, claims)
+	unknown.Header["kid"] = "gone"
+	unknownStr, _ := unknown.SignedString(ecKey)
+	_, err = jwt.Parse(unknownStr, after.Keyfunc)
+	assert.ErrorIs(t, err, ErrUnknownKID)
+
+	missing, _ := jwt.NewWithClaims(jwt.SigningMethodES256, claims).SignedString(ecKey)
+	_, err = jwt.Parse(missing, after.Keyfunc)
+	assert.ErrorIs(t, err, ErrMissingKID)
+}
+
+func TestNewHMAC(t *testing.T) {
+	ks, err := NewHMAC([]byte("secret"))
+	assert.NoError(t, err)
+	assert.Empty(t, ks.JWKS().Keys)
+
+	token, err := ks.Sign(jwt.MapClaims{"sub": "colin"})
+	assert.NoError(t, err)
+
+	parsed, err := jwt.Parse(token, ks.Keyfunc)
+	assert.NoError(t, err)
+	assert.Nil(t, parsed.Header["kid"])
+
+	_, err = NewHMAC(nil)
+	assert.Error(t, err)
+}
@@ -0,0 +1,199 @@
+package jwks
+
+import (
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/golang-jwt/jwt/v4"
+	"io"
+	"math/big"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// 上游轮换密钥时会出现未知的 kid, 两次重新获取之间至少间隔该时间, 避免伪造的 kid 打满上游
+const minRefreshInterval = 30 * time.Second
+
+// PublicKey 将 JWK 解析为公钥, 支持 RSA, EC(P-256/384/521) 和 OKP(Ed25519)
+func (k JSONWebKey) PublicKey() (interface{}, error) {
+	switch k.Kty {
+	case "RSA":
+		n, err := decodeBigInt(k.N)
+		if err != nil {
+			return nil, fmt.Errorf("invalid n: %w", err)
+		}
+		e, err := decodeBigInt(k.E)
+		if err != nil || !e.IsInt64() || e.Int64() > 1<<31-1 {
+			return nil, fmt.Errorf("invalid e")
+		}
+		return &rsa.PublicKey{N: n, E: int(e.Int64())}, nil
+	case "EC":
+		var curve elliptic.Curve
+		switch k.Crv {
+		case "P-256":
+			curve = elliptic.P256()
+		case "P-384":
+			curve = elliptic.P384()
+		case "P-521":
+			curve = elliptic.P521()
+		default:
+			return nil, fmt.Errorf("unsupported curve %s", k.Crv)
+		}
+		x, errX := decodeBigInt(k.X)
+		y, errY := decodeBigInt(k.Y)
+		if errX != nil || errY != nil || !curve.IsOnCurve(x, y) {
+			return nil, errors.New("invalid ec point")
+		}
+		return &ecdsa.PublicKey{Curve: curve, X: x, Y: y}, nil
+	case "OKP":
+		x, err := decode(k.X)
+		if k.Crv != "Ed25519" || err != nil || len(x) != ed25519.PublicKeySize {
+			return nil, fmt.Errorf("unsupported okp key %s", k.Crv)
+		}
+		return ed25519.PublicKey(x), nil
+	}
+
+	return nil, fmt.Errorf("unsupported kty %s", k.Kty)
+}
+
+// ParseJWKS 解析 JWKS 为仅用于验签的 KeySet, 跳过加密用途和不支持的密钥
+func ParseJWKS(data []byte) (*KeySet, error) {
+	var set JSONWebKeySet
+	if err := json.Unmarshal(data, &set); err != nil {
+		return nil, err
+	}
+
+	keys := make([]*Key, 0, len(set.Keys))
+	for _, jwk := range set.Keys {
+		if jwk.Use != "" && jwk.Use != "sig" {
+			continue
+		}
+
+		public, err := jwk.PublicKey()
+		if err != nil {
+			continue
+		}
+		key, err := NewKey(jwk.Kid, public)
+		if err != nil {
+			continue
+		}
+		// alg 为可选字段, 设置时必须与密钥类型一致
+		if jwk.Alg != "" && jwk.Alg != key.Method.Alg() {
+			continue
+		}
+		keys = append(keys, key)
+	}
+
+	if len(keys) == 0 {
+		return nil, errors.New("no usable signing key in jwks")
+	}
+
+	return New("", keys...)
+}
+
+// Remote 从 jwks_uri 获取的验签密钥, 首次使用时获取, 遇到未知 kid 时重新获取以支持上游轮换,
+// 超过 refreshInterval 后也会重新获取, 上游删除的密钥不再被信任
+type Remote struct {
+	url             string
+	client          *http.Client
+	refreshInterval time.Duration
+
+	mu        sync.Mutex
+	keys      *KeySet
+	fetchedAt time.Time // 上次获取成功的时间
+	checkedAt time.Time // 上次尝试获取的时间, 用于限制获取频率
+}
+
+func NewRemote(url string, client *http.Client, refreshInterval time.Duration) *Remote {
+	return &Remote{url: url, client: client, refreshInterval: refreshInterval}
+}
+
+// Keyfunc 用于 jwt.Parse, 按 kid 选择上游的公钥
+func (r *Remote) Keyfunc(token *jwt.Token) (interface{}, error) {
+	keys, err := r.get(false)
+	if err != nil {
+		return nil, err
+	}
+
+	key, err := keys.Keyfunc(token)
+	if !errors.Is(err, ErrUnknownKID) {
+		return key, err
+	}
+
+	if keys, err = r.get(true); err != nil {
+		return nil, err
+	}
+
+	return keys.Keyfunc(token)
+}
+
+// get 返回缓存的密钥, refresh 为 true 或密钥已超过 refreshInterval 时在间隔允许的情况下重新获取
+func (r *Remote) get(refresh bool) (*KeySet, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.keys != nil {
+		stale := time.Since(r.fetchedAt) >= r.refreshInterval
+		if (!refresh && !stale) || time.Since(r.checkedAt) < minRefreshInterval {
+			return r.keys, nil
+		}
+	}
+
+	r.checkedAt = time.Now()
+	keys, err := r.fetch()
+	if err != nil {
+		if r.keys != nil {
+			return r.keys, nil // 上游暂时不可用时继续使用旧的密钥
+		}
+		return nil, err
+	}
+	r.keys, r.fetchedAt = keys, r.checkedAt
+
+	return keys, nil
+}
+
+func (r *Remote) fetch() (*KeySet, error) {
+	rsp, err := r.client.Get(r.url)
+	if err != nil {
+		return nil, fmt.Errorf("fetch jwks %s: %w", r.url, err)
+	}
+	defer rsp.Body.Close()
+
+	if rsp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("fetch jwks %s: unexpected status %d", r.url, rsp.StatusCode)
+	}
+
+	data, err := io.ReadAll(io.LimitReader(rsp.Body, 1<<20))
+	if err != nil {
+		return nil, fmt.Errorf("fetch jwks %s: %w", r.url, err)
+	}
+
+	keys, err := ParseJWKS(data)
+	if err != nil {
+		return nil, fmt.Errorf("parse jwks %s: %w", r.url, err)
+	}
+
+	return keys, nil
+}
+
+func decode(s string) ([]byte, error) {
+	return base64.RawURLEncoding.DecodeString(s)
+}
+
+func decodeBigInt(s string) (*big.Int, error) {
+	b, err := decode(s)
+	if err != nil {
+		return nil, err
+	}
+	if len(b) == 0 {
+		return nil, errors.New("empty value")
+	}
+
+	return new(big.Int).SetBytes(b), nil
+}
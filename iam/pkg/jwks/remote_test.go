@@ -0,0 +1,117 @@
+package jwks
+
+import (
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/json"
+	"github.com/golang-jwt/jwt/v4"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestParseJWKS(t *testing.T) {
+	rsaKey, _ := rsa.GenerateKey(rand.Reader, 2048)
+	ecKey, _ := ecdsa.GenerateKey(elliptic.P384(), rand.Reader)
+	_, edKey, _ := ed25519.GenerateKey(rand.Reader)
+
+	var signers []*Key
+	for kid, private := range map[string]interface{}{"rsa": rsaKey, "ec": ecKey, "ed": edKey} {
+		key, err := NewKey(kid, private)
+		require.NoError(t, err)
+		signers = append(signers, key)
+	}
+	ks, err := New("rsa", signers...)
+	require.NoError(t, err)
+
+	set := ks.JWKS()
+	// 加密用途, alg 不一致和不支持的密钥被忽略
+	set.Keys = append(set.Keys,
+		JSONWebKey{Kty: "RSA", Kid: "enc", Use: "enc", N: set.Keys[0].N, E: set.Keys[0].E},
+		JSONWebKey{Kty: "RSA", Kid: "alg", Alg: "ES256", N: set.Keys[0].N, E: set.Keys[0].E},
+		JSONWebKey{Kty: "oct", Kid: "oct"},
+	)
+	data, _ := json.Marshal(set)
+
+	parsed, err := ParseJWKS(data)
+	require.NoError(t, err)
+	assert.Len(t, parsed.JWKS().Keys, 3)
+
+	for _, signer := range signers {
+		token := jwt.NewWithClaims(signer.Method, jwt.MapClaims{"sub": "colin"})
+		token.Header["kid"] = signer.ID
+		signed, err := token.SignedString(signer.private)
+		require.NoError(t, err)
+
+		_, err = jwt.Parse(signed, parsed.Keyfunc)
+		assert.NoError(t, err, signer.ID)
+	}
+
+	_, err = ParseJWKS([]byte(`{"keys":[{"kty":"oct","kid":"oct"}]}`))
+	assert.Error(t, err)
+}
+
+func TestRemoteRotation(t *testing.T) {
+	newKeySet := func(kid string) *KeySet {
+		private, _ := rsa.GenerateKey(rand.Reader, 2048)
+		key, _ := NewKey(kid, private)
+		ks, _ := New(kid, key)
+		return ks
+	}
+
+	var fetches int32
+	current := newKeySet("k1")
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&fetches, 1)
+		_ = json.NewEncoder(w).Encode(current.JWKS())
+	}))
+	defer server.Close()
+
+	remote := NewRemote(server.URL, server.Client(), time.Hour)
+	elapse := func(d time.Duration) {
+		remote.fetchedAt, remote.checkedAt = remote.fetchedAt.Add(-d), remote.checkedAt.Add(-d)
+	}
+	verify := func(ks *KeySet) error {
+		token, err := ks.Sign(jwt.MapClaims{"sub": "colin"})
+		require.NoError(t, err)
+		_, err = jwt.Parse(token, remote.Keyfunc)
+		return err
+	}
+
+	assert.NoError(t, verify(current))
+	assert.NoError(t, verify(current))
+	assert.EqualValues(t, 1, atomic.LoadInt32(&fetches))
+
+	// 间隔内遇到未知 kid 不会重新获取
+	rotated := newKeySet("k2")
+	assert.Error(t, verify(rotated))
+	assert.EqualValues(t, 1, atomic.LoadInt32(&fetches))
+
+	current = rotated
+	elapse(minRefreshInterval)
+	assert.NoError(t, verify(rotated))
+	assert.EqualValues(t, 2, atomic.LoadInt32(&fetches))
+
+	// 超过 refreshInterval 后即使 kid 已知也重新获取, 上游删除的密钥不再有效
+	current = newKeySet("k3")
+	elapse(time.Hour)
+	assert.Error(t, verify(rotated))
+	assert.EqualValues(t, 3, atomic.LoadInt32(&fetches))
+	assert.NoError(t, verify(current))
+	assert.EqualValues(t, 3, atomic.LoadInt32(&fetches))
+
+	// 上游不可用时继续使用旧的密钥, 并且不会每次都重新获取
+	server.Close()
+	elapse(time.Hour)
+	assert.NoError(t, verify(current))
+	assert.NoError(t, verify(current))
+	assert.EqualValues(t, 3, atomic.LoadInt32(&fetches))
+	assert.Error(t, verify(newKeySet("k4")))
+}